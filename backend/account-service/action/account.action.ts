@@ -1,7 +1,7 @@
 import {prisma} from "../utils/db"
 import { kafkaService } from "../utils/kafka"
 import { Prisma } from '@prisma/client'
-import { hashMaker } from '../../auth-service/hash_generator'
+import { transferThroughCore } from '../utils/core'
 
 export class AccountService {
   private generateAccountNumber(): string {
@@ -102,62 +102,11 @@ export class AccountService {
     return updatedAccount
   }
 
-  async transfer(fromAccountId: string, toAccountId: string, amount: number, description?: string) {
-    return await prisma.$transaction(async (tx) => {
-      const fromAccount = await tx.account.findUnique({ where: { id: fromAccountId } })
-      const toAccount = await tx.account.findUnique({ where: { id: toAccountId } })
-
-      if (!fromAccount || !toAccount) {
-        throw new Error('Account not found')
-      }
-
-      if (fromAccount.balance.lt(amount)) {
-        throw new Error('Insufficient balance')
-      }
-
-      const newFromBalance = fromAccount.balance.sub(amount)
-      const newToBalance = toAccount.balance.add(amount)
-
-      await tx.account.update({
-        where: { id: fromAccountId },
-        data: { balance: newFromBalance }
-      })
-
-      await tx.account.update({
-        where: { id: toAccountId },
-        data: { balance: newToBalance }
-      })
-
-      const reference = `TXN${Date.now()}`
-
-      const inputs = fromAccount.accountNumber + toAccount.accountNumber; 
-
-      const id = hashMaker(inputs) 
-
-      const transaction = await tx.transaction.create({
-        data: {
-          id: id,  
-          type: 'TRANSFER',
-          amount,
-          description: description || 'Account transfer',
-          status: 'COMPLETED',
-          reference,
-          fromAccount: fromAccount.accountNumber,
-          toAccount: toAccount.accountNumber
-        }
-      })
-
-      await kafkaService.publishMessage('transfer.completed', {
-        transactionId: transaction.id,
-        fromAccountId,
-        toAccountId,
-        amount,
-        reference,
-        timestamp: new Date()
-      })
-
-      return transaction
-    })
+  // Transfers go through core so that the transfer.completed event carries
+  // core's signature; consumers drop anything unsigned. amount is in major
+  // units here and minor units in core.
+  async transfer(fromAccountId: string, toAccountId: string, amount: number, description?: string, idempotencyKey?: string) {
+    return transferThroughCore(fromAccountId, toAccountId, Math.round(amount * 100), description, idempotencyKey)
   }
 
   async freezeAccount(accountId: string) {
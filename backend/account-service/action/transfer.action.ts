@@ -0,0 +1,21 @@
+import { prisma } from '../utils/db'
+import { kafkaService } from '../utils/kafka'
+import type { TransferEvent } from '../../shared/events'
+
+// handleTransferCompleted announces the new balances of both sides of a
+// transfer core has signed for.
+export async function handleTransferCompleted(event: TransferEvent) {
+  const accounts = await prisma.account.findMany({
+    where: { id: { in: [event.fromAccountId, event.toAccountId] } }
+  })
+  for (const account of accounts) {
+    await kafkaService.publishMessage('account.balance.updated', {
+      accountId: account.id,
+      newBalance: account.balance,
+      amount: event.amount,
+      type: account.id === event.fromAccountId ? 'DEBIT' : 'CREDIT',
+      transactionId: event.transactionId,
+      timestamp: new Date()
+    })
+  }
+}
@@ -36,7 +36,8 @@ export class AccountController {
       fromAccountId,
       toAccountId,
       amount,
-      description
+      description,
+      req.get('Idempotency-Key')
     )
     if (!transaction) throw new AppError('Transfer failed', 400)
     res.json({ success: true, data: transaction })
@@ -2,6 +2,9 @@ import crypto from "crypto"
 import type { NextFunction, Request, Response } from "express"
 import express from "express"
 import router from "./routes/account.routes"
+import { kafkaService } from "./utils/kafka"
+import { verifiedTransferHandler } from "../shared/events"
+import { handleTransferCompleted } from "./action/transfer.action"
 
 const app = express()
 app.use(express.json())
@@ -58,4 +61,10 @@ app.use("/status", (_req,res) => {
 
 app.use("/api/account", router)
 
+await kafkaService.subscribeToTopic(
+  "transfer.completed",
+  "account-service",
+  verifiedTransferHandler(handleTransferCompleted)
+)
+
 app.listen(3000, () => console.log("Account Service running with encrypted request/response"))
@@ -0,0 +1,42 @@
+const CORE_HTTP_URL = (process.env.CORE_HTTP_URL || '').replace(/\/$/, '')
+// Mint with `core-server -issue-token account-service`.
+const CORE_SERVICE_TOKEN = process.env.CORE_SERVICE_TOKEN || ''
+
+export type CoreTransfer = {
+  transactionId: string
+  reference: string
+  status: string
+  amount: number
+  createdAt: string
+  description: string
+  fromAccount: string
+  toAccount: string
+  riskReasons: string[]
+}
+
+// transferThroughCore moves money with the core engine, which records the
+// transfer in the chain and publishes the signed transfer.completed event.
+// amount is in minor units.
+export async function transferThroughCore(
+  fromAccountId: string,
+  toAccountId: string,
+  amount: number,
+  description?: string,
+  idempotencyKey?: string
+): Promise<CoreTransfer> {
+  if (!CORE_HTTP_URL) throw new Error('CORE_HTTP_URL not configured')
+  const res = await fetch(`${CORE_HTTP_URL}/v1/transfers`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+      Authorization: `Bearer ${CORE_SERVICE_TOKEN}`,
+      ...(idempotencyKey ? { 'Idempotency-Key': idempotencyKey } : {})
+    },
+    body: JSON.stringify({ fromAccountId, toAccountId, amount, description: description || '' })
+  })
+  if (!res.ok) {
+    const problem = (await res.json().catch(() => ({}))) as { detail?: string }
+    throw new Error(problem.detail || `core transfer failed: ${res.status}`)
+  }
+  return (await res.json()) as CoreTransfer
+}
@@ -2,29 +2,22 @@ import { prisma } from '@bank/database'
 import { kafkaService } from '@bank/kafka'
 import { Prisma } from '@prisma/client'
 import { hashMaker } from '../../auth-service/hash_generator'
+import { issueCard, reissueCvv } from '../utils/core'
 
 export class CardService {
-  private generateCardNumber(): string {
-    return '4' + Math.random().toString().slice(2, 16).padStart(15, '0')
-  }
-
-  private generateCVV(): string {
-    return Math.floor(100 + Math.random() * 900).toString()
-  }
-
-  async createCard(data: Omit<Prisma.CardCreateInput, 'cardNumber' | 'cvv'>) {
-    const cardNumber = this.generateCardNumber()
-    const cvv = this.generateCVV()
+  async createCard(data: Omit<Prisma.CardCreateInput, 'cardNumber' | 'cardNumberEnc' | 'cardNumberHash' | 'cardLast4' | 'cvv'>) {
     const expiryDate = new Date()
     expiryDate.setFullYear(expiryDate.getFullYear() + 4)
+    const issued = await issueCard(expiryDate.getMonth() + 1, expiryDate.getFullYear())
 
     const card = await prisma.card.create({
       data: {
         ...data,
-        cardNumber,
-        cvv,
-        expiryMonth: expiryDate.getMonth() + 1,
-        expiryYear: expiryDate.getFullYear()
+        cardNumberEnc: issued.cardNumberEnc,
+        cardNumberHash: issued.cardNumberHash,
+        cardLast4: issued.cardLast4,
+        expiryMonth: issued.expiryMonth,
+        expiryYear: issued.expiryYear
       },
       include: {
         user: true,
@@ -34,17 +27,36 @@ export class CardService {
 
     await kafkaService.publishMessage('card.created', {
       cardId: card.id,
-      cardNumber: card.cardNumber.slice(-4),
+      cardNumber: card.cardLast4,
       userId: card.userId,
       accountId: card.accountId,
       cardType: card.cardType,
       timestamp: new Date()
     })
 
+    // The only time the CVV is shown.
     return {
       ...card,
-      cardNumber: card.cardNumber.slice(-4),
-      cvv: '***'
+      cardNumber: card.cardLast4,
+      cvv: issued.cvv
+    }
+  }
+
+  async reissueCvv(cardId: string) {
+    const issued = await reissueCvv(cardId)
+
+    await kafkaService.publishMessage('card.cvv.reissued', {
+      cardId,
+      cardNumber: issued.cardLast4,
+      timestamp: new Date()
+    })
+
+    return {
+      cardId,
+      cardNumber: issued.cardLast4,
+      expiryMonth: issued.expiryMonth,
+      expiryYear: issued.expiryYear,
+      cvv: issued.cvv
     }
   }
 
@@ -64,7 +76,7 @@ export class CardService {
     if (card) {
       return {
         ...card,
-        cardNumber: card.cardNumber.slice(-4),
+        cardNumber: card.cardLast4 ?? card.cardNumber?.slice(-4),
         cvv: '***'
       }
     }
@@ -86,7 +98,7 @@ export class CardService {
 
     return cards.map(card => ({
       ...card,
-      cardNumber: card.cardNumber.slice(-4),
+      cardNumber: card.cardLast4 ?? card.cardNumber?.slice(-4),
       cvv: '***'
     }))
   }
@@ -107,7 +119,7 @@ export class CardService {
 
     return {
       ...card,
-      cardNumber: card.cardNumber.slice(-4),
+      cardNumber: card.cardLast4 ?? card.cardNumber?.slice(-4),
       cvv: '***'
     }
   }
@@ -132,7 +144,7 @@ export class CardService {
 
     return {
       ...card,
-      cardNumber: card.cardNumber.slice(-4),
+      cardNumber: card.cardLast4 ?? card.cardNumber?.slice(-4),
       cvv: '***'
     }
   }
@@ -36,6 +36,12 @@ export class CardController {
     res.json({ success: true, data: card })
   }
 
+  reissueCvv = async (req: Request, res: Response) => {
+    const { id } = req.params
+    const card = await this.cardService.reissueCvv(id)
+    res.json({ success: true, data: card })
+  }
+
   updateLimits = async (req: Request, res: Response) => {
     const { id } = req.params
     const { dailyLimit, monthlyLimit } = req.body
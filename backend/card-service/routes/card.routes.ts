@@ -10,6 +10,7 @@ router.get('/cards/:id', asyncHandler(cardController.getCard))
 router.get('/users/:userId/cards', asyncHandler(cardController.getUserCards))
 router.patch('/cards/:id/block', asyncHandler(cardController.blockCard))
 router.patch('/cards/:id/limits', asyncHandler(cardController.updateLimits))
+router.post('/cards/:id/cvv', asyncHandler(cardController.reissueCvv))
 router.post('/cards/transaction', asyncHandler(cardController.processTransaction))
 
 export default router
\ No newline at end of file
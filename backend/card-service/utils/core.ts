@@ -0,0 +1,40 @@
+const CORE_HTTP_URL = (process.env.CORE_HTTP_URL || '').replace(/\/$/, '')
+// Mint with `core-server -issue-token card-service`.
+const CORE_SERVICE_TOKEN = process.env.CORE_SERVICE_TOKEN || ''
+
+export type IssuedCard = {
+  cardNumberEnc: string
+  cardNumberHash: string
+  cardLast4: string
+  masked: string
+  cvv: string
+  expiryMonth: number
+  expiryYear: number
+}
+
+async function corePost(path: string, body: unknown): Promise<IssuedCard> {
+  if (!CORE_HTTP_URL) throw new Error('CORE_HTTP_URL not configured')
+  const res = await fetch(`${CORE_HTTP_URL}${path}`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+      Authorization: `Bearer ${CORE_SERVICE_TOKEN}`
+    },
+    body: JSON.stringify(body)
+  })
+  if (!res.ok) throw new Error(`core ${path} failed: ${res.status}`)
+  return (await res.json()) as IssuedCard
+}
+
+// issueCard asks the core vault for a new card. Only the encrypted number,
+// its blind index and last four digits may be stored; the CVV is shown to
+// the cardholder once and never persisted.
+export function issueCard(expiryMonth: number, expiryYear: number): Promise<IssuedCard> {
+  return corePost('/v1/cards', { expiryMonth, expiryYear })
+}
+
+// reissueCvv replaces a card's stored legacy CVV with its derived one. The
+// returned CVV goes to the cardholder with the reissued card.
+export function reissueCvv(cardId: string): Promise<IssuedCard> {
+  return corePost(`/v1/cards/${encodeURIComponent(cardId)}/cvv`, {})
+}
@@ -0,0 +1,25 @@
+import { prisma } from '../utils/db'
+import { sendTransactionAlertEmail } from './notification.action'
+import type { TransferEvent } from '../../shared/events'
+
+// Core amounts are in minor units.
+const formatAmount = (minor: number) => (minor / 100).toFixed(2)
+
+export async function handleTransferCompleted(event: TransferEvent) {
+  const accounts = await prisma.account.findMany({
+    where: { id: { in: [event.fromAccountId, event.toAccountId] } },
+    include: { user: true }
+  })
+  const at = new Date(event.timestamp)
+  for (const account of accounts) {
+    await sendTransactionAlertEmail(account.user.email, {
+      recipientName: `${account.user.firstName} ${account.user.lastName}`,
+      accountNumber: account.accountNumber,
+      transactionAmount: formatAmount(event.amount),
+      transactionType: account.id === event.fromAccountId ? 'debit' : 'credit',
+      merchantName: event.reference,
+      transactionDate: at.toLocaleDateString(),
+      transactionTime: at.toLocaleTimeString()
+    })
+  }
+}
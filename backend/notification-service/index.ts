@@ -1 +1,11 @@
-console.log("Hello via Bun!");
\ No newline at end of file
+import { kafkaService } from './utils/kafka'
+import { verifiedTransferHandler } from '../shared/events'
+import { handleTransferCompleted } from './action/transfer.action'
+
+await kafkaService.subscribeToTopic(
+  'transfer.completed',
+  'notification-service',
+  verifiedTransferHandler(handleTransferCompleted)
+)
+
+console.log('Notification Service consuming transfer.completed')
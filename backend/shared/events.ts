@@ -0,0 +1,60 @@
+import crypto from 'crypto'
+
+const CORE_JWKS_URL = process.env.CORE_JWKS_URL || ''
+const JWKS_TTL_MS = Number(process.env.CORE_JWKS_TTL_MS || '300000')
+
+type Jwk = { kty: string; crv: string; x: string; kid: string; alg: string }
+
+let cachedKeys: Map<string, crypto.KeyObject> = new Map()
+let fetchedAt = 0
+
+async function loadKeys(force = false) {
+  if (!force && Date.now() - fetchedAt < JWKS_TTL_MS) return cachedKeys
+  if (!CORE_JWKS_URL) throw new Error('CORE_JWKS_URL not configured')
+  const res = await fetch(CORE_JWKS_URL)
+  if (!res.ok) throw new Error(`jwks fetch failed: ${res.status}`)
+  const body = (await res.json()) as { keys: Jwk[] }
+  const keys = new Map<string, crypto.KeyObject>()
+  for (const jwk of body.keys || []) {
+    if (jwk.kty !== 'OKP' || jwk.crv !== 'Ed25519') continue
+    keys.set(jwk.kid, crypto.createPublicKey({ key: jwk, format: 'jwk' }))
+  }
+  cachedKeys = keys
+  fetchedAt = Date.now()
+  return cachedKeys
+}
+
+export type TransferEvent = {
+  transactionId: string
+  fromAccountId: string
+  toAccountId: string
+  amount: number
+  reference: string
+  timestamp: string
+}
+
+// Shared by every service that consumes core transfer events. A missing or
+// unreachable JWKS throws, so the message is retried rather than trusted.
+export async function verifyTransferEvent(event: any): Promise<boolean> {
+  if (event?.alg !== 'EdDSA' || !event.kid || !event.sig) return false
+  let keys = await loadKeys()
+  if (!keys.has(event.kid)) keys = await loadKeys(true)
+  const key = keys.get(event.kid)
+  if (!key) return false
+  const payload = Buffer.from(
+    `${event.transactionId}|${event.fromAccountId}|${event.toAccountId}|${event.amount}|${event.reference}`
+  )
+  return crypto.verify(null, payload, key, Buffer.from(event.sig, 'base64url'))
+}
+
+// verifiedTransferHandler wraps a topic handler so it only ever sees events
+// signed by core; anything else is logged and dropped.
+export function verifiedTransferHandler(handler: (event: TransferEvent) => Promise<void>) {
+  return async (event: any) => {
+    if (!(await verifyTransferEvent(event))) {
+      console.warn(`dropping unverified transfer event ${event?.transactionId}`)
+      return
+    }
+    await handler(event as TransferEvent)
+  }
+}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const MaxBalanceAccounts = 100
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	ErrInvalidHold     = errors.New("invalid hold")
+	ErrHoldNotFound    = errors.New("hold not found")
+)
+
+var holdSchema = []string{
+	`CREATE TABLE IF NOT EXISTS account_holds (
+		id          BIGSERIAL PRIMARY KEY,
+		account_id  TEXT NOT NULL,
+		amount      BIGINT NOT NULL CHECK (amount > 0),
+		reason      TEXT NOT NULL,
+		created_at  TIMESTAMP NOT NULL DEFAULT now(),
+		expires_at  TIMESTAMP,
+		released_at TIMESTAMP
+	)`,
+	"CREATE INDEX IF NOT EXISTS account_holds_active_idx ON account_holds (account_id) WHERE released_at IS NULL",
+}
+
+// activeHolds is the held amount per account; expired holds no longer count.
+const activeHolds = `
+	SELECT account_id, SUM(amount) AS held FROM account_holds
+	WHERE released_at IS NULL AND (expires_at IS NULL OR expires_at > now() AT TIME ZONE 'UTC')
+	GROUP BY account_id`
+
+type AccountBalance struct {
+	AccountID         string
+	AccountNumber     string
+	Ledger            int64
+	Held              int64
+	Currency          string
+	AsOfTransactionID string
+	AsOfSeq           int64
+	FromReplica       bool
+	Staleness         time.Duration
+}
+
+func (b AccountBalance) Available() int64 {
+	return b.Ledger - b.Held
+}
+
+func EnsureHoldSchema(ctx context.Context, db *sql.DB) error {
+	for _, s := range holdSchema {
+		if _, err := db.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlaceHold reserves amount on an account. The account row is locked so a
+// hold and a transfer cannot both spend the same available balance.
+func PlaceHold(ctx context.Context, tx *sql.Tx, accountID string, amount int64, reason string, expiresAt time.Time) (int64, error) {
+	if amount <= 0 || reason == "" {
+		return 0, ErrInvalidHold
+	}
+	var balance string
+	err := tx.QueryRowContext(ctx, "SELECT balance::text FROM accounts WHERE id=$1 FOR UPDATE", accountID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrAccountNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	ledger, err := parseMinorUnits(balance)
+	if err != nil {
+		return 0, err
+	}
+	held, err := heldAmount(ctx, tx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	if ledger-held < amount {
+		return 0, ErrInsufficientBalance
+	}
+	var expires sql.NullTime
+	if !expiresAt.IsZero() {
+		expires = sql.NullTime{Time: expiresAt.UTC(), Valid: true}
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO account_holds (account_id, amount, reason, expires_at) VALUES ($1,$2,$3,$4) RETURNING id",
+		accountID, amount, reason, expires,
+	).Scan(&id)
+	return id, err
+}
+
+func ReleaseHold(ctx context.Context, tx *sql.Tx, id int64) error {
+	res, err := tx.ExecContext(ctx, "UPDATE account_holds SET released_at = now() AT TIME ZONE 'UTC' WHERE id=$1 AND released_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrHoldNotFound
+	}
+	return nil
+}
+
+func heldAmount(ctx context.Context, tx *sql.Tx, accountID string) (int64, error) {
+	var held int64
+	err := tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(held), 0) FROM ("+activeHolds+") h WHERE account_id=$1", accountID).Scan(&held)
+	return held, err
+}
+
+// ReadBalances returns balances for the accounts that exist, in no
+// particular order. With a replica and a positive maxStaleness the replica
+// is tried first; its answer is used only if its replay lag, measured in the
+// same snapshot as the balances, is within maxStaleness. Otherwise, or if
+// the replica fails, the primary answers.
+func ReadBalances(ctx context.Context, primary, replica *sql.DB, ids []string, maxStaleness time.Duration) ([]AccountBalance, error) {
+	if replica != nil && maxStaleness > 0 {
+		balances, lag, err := readBalances(ctx, replica, ids, true)
+		if err == nil && lag <= maxStaleness {
+			for i := range balances {
+				balances[i].FromReplica = true
+				balances[i].Staleness = lag
+			}
+			return balances, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	balances, _, err := readBalances(ctx, primary, ids, false)
+	return balances, err
+}
+
+func readBalances(ctx context.Context, db *sql.DB, ids []string, measureLag bool) ([]AccountBalance, time.Duration, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var lag time.Duration
+	if measureLag {
+		// A standby that has replayed everything it received is as fresh as
+		// the last message from the primary, which sends keepalives while
+		// idle; otherwise the lag is the age of the last replayed commit.
+		// Without a streaming WAL receiver (disconnected, or statistics not
+		// visible to this role: it needs pg_read_all_stats) the lag is
+		// unknown and reported as huge, so the primary answers.
+		var seconds float64
+		err := tx.QueryRowContext(ctx, `
+			SELECT CASE
+				WHEN NOT pg_is_in_recovery() THEN 0
+				WHEN r.status IS DISTINCT FROM 'streaming' THEN 1e9
+				WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn()
+					THEN COALESCE(EXTRACT(EPOCH FROM now() - r.last_msg_receipt_time), 1e9)
+				ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 1e9)
+			END
+			FROM (SELECT 1) one
+			LEFT JOIN pg_stat_wal_receiver r ON true`).Scan(&seconds)
+		if err != nil {
+			return nil, 0, err
+		}
+		lag = time.Duration(seconds * float64(time.Second))
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT a.id, a.account_number, a.balance::text, a.currency, COALESCE(h.held, 0),
+			COALESCE(l.seq, 0), COALESCE(l.transaction_id, '')
+		FROM accounts a
+		LEFT JOIN (`+activeHolds+`) h ON h.account_id = a.id
+		LEFT JOIN LATERAL (
+			SELECT c.seq, c.transaction_id
+			FROM transaction_chain c
+			JOIN transactions t ON t.id = c.transaction_id
+			WHERE t.from_account = a.account_number OR t.to_account = a.account_number
+			ORDER BY c.seq DESC
+			LIMIT 1
+		) l ON true
+		WHERE a.id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var balances []AccountBalance
+	for rows.Next() {
+		var b AccountBalance
+		var ledger string
+		if err := rows.Scan(&b.AccountID, &b.AccountNumber, &ledger, &b.Currency, &b.Held, &b.AsOfSeq, &b.AsOfTransactionID); err != nil {
+			return nil, 0, err
+		}
+		if b.Ledger, err = parseMinorUnits(ledger); err != nil {
+			return nil, 0, err
+		}
+		balances = append(balances, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return balances, lag, nil
+}
@@ -0,0 +1,295 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPAN    = errors.New("invalid card number")
+	ErrInvalidCVV    = errors.New("invalid cvv")
+	ErrCardUnknown   = errors.New("card not found")
+	ErrInvalidExpiry = errors.New("invalid card expiry")
+	ErrCardInactive  = errors.New("card inactive")
+	// ErrCardNotMigrated means the card still has a plain number; run
+	// migrate-cards first.
+	ErrCardNotMigrated = errors.New("card number is not encrypted")
+)
+
+// IssuerPrefix starts every PAN IssueCard generates.
+const IssuerPrefix = "4"
+
+const issuedPANLength = 16
+
+type TokenizedCard struct {
+	Ciphertext string
+	BlindIndex string
+	Last4      string
+	Masked     string
+}
+
+// IssuedCard is a tokenized card with the CVV to give its holder.
+type IssuedCard struct {
+	TokenizedCard
+	CVV         string
+	ExpiryMonth int
+	ExpiryYear  int
+}
+
+type CardVault struct {
+	km KeyManager
+}
+
+func NewCardVault(km KeyManager) *CardVault {
+	return &CardVault{km: km}
+}
+
+func NormalizePAN(pan string) (string, error) {
+	var b strings.Builder
+	for _, r := range pan {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-':
+		default:
+			return "", ErrInvalidPAN
+		}
+	}
+	out := b.String()
+	if len(out) < 12 || len(out) > 19 {
+		return "", ErrInvalidPAN
+	}
+	return out, nil
+}
+
+func MaskPAN(pan string) string {
+	if len(pan) < 4 {
+		return "****"
+	}
+	return "**** " + pan[len(pan)-4:]
+}
+
+func (v *CardVault) Tokenize(pan string) (*TokenizedCard, error) {
+	norm, err := NormalizePAN(pan)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := Encrypt([]byte(norm), v.km)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := v.blindIndex(norm)
+	if err != nil {
+		return nil, err
+	}
+	last4 := norm[len(norm)-4:]
+	return &TokenizedCard{Ciphertext: ct, BlindIndex: idx, Last4: last4, Masked: MaskPAN(norm)}, nil
+}
+
+// IssueCard generates a random PAN, tokenizes it and derives its CVV. The
+// plain PAN is dropped here; the CVV is returned for the cardholder once.
+func (v *CardVault) IssueCard(expiryMonth, expiryYear int) (*IssuedCard, error) {
+	if expiryMonth < 1 || expiryMonth > 12 || expiryYear < time.Now().Year() || expiryYear > 9999 {
+		return nil, ErrInvalidExpiry
+	}
+	pan, err := randomPAN(IssuerPrefix, issuedPANLength)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := v.Tokenize(pan)
+	if err != nil {
+		return nil, err
+	}
+	cvv, err := v.DeriveCVV(pan, expiryMonth, expiryYear)
+	if err != nil {
+		return nil, err
+	}
+	return &IssuedCard{TokenizedCard: *tok, CVV: cvv, ExpiryMonth: expiryMonth, ExpiryYear: expiryYear}, nil
+}
+
+// randomPAN fills prefix with random digits up to length, ending in a Luhn
+// check digit.
+func randomPAN(prefix string, length int) (string, error) {
+	digits := []byte(prefix)
+	for len(digits) < length-1 {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits = append(digits, byte('0'+n.Int64()))
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return string(append(digits, byte('0'+(10-sum%10)%10))), nil
+}
+
+func (v *CardVault) Detokenize(ciphertext string) (string, error) {
+	plain, err := Decrypt(ciphertext, v.km)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func (v *CardVault) BlindIndex(pan string) (string, error) {
+	norm, err := NormalizePAN(pan)
+	if err != nil {
+		return "", err
+	}
+	return v.blindIndex(norm)
+}
+
+func (v *CardVault) blindIndex(norm string) (string, error) {
+	sum, err := keyedDigest(v.km, "card-pan-blind-index", []byte(norm))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// CVVs are derived from the PAN and expiry with a dedicated key, the way
+// issuers compute them, so they never have to be stored to be verified.
+func (v *CardVault) DeriveCVV(pan string, expiryMonth, expiryYear int) (string, error) {
+	norm, err := NormalizePAN(pan)
+	if err != nil {
+		return "", err
+	}
+	sum, err := keyedDigest(v.km, "card-cvv", []byte(fmt.Sprintf("%s|%02d|%04d", norm, expiryMonth, expiryYear)))
+	if err != nil {
+		return "", err
+	}
+	n := binary.BigEndian.Uint32(sum[:4]) % 1000
+	return fmt.Sprintf("%03d", n), nil
+}
+
+func (v *CardVault) VerifyCVV(pan string, expiryMonth, expiryYear int, cvv string) error {
+	expected, err := v.DeriveCVV(pan, expiryMonth, expiryYear)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(cvv)) != 1 {
+		return ErrInvalidCVV
+	}
+	return nil
+}
+
+func (v *CardVault) FindCardID(ctx context.Context, db *sql.DB, pan string) (string, error) {
+	idx, err := v.BlindIndex(pan)
+	if err != nil {
+		return "", err
+	}
+	var id string
+	err = db.QueryRowContext(ctx, "SELECT id FROM cards WHERE card_number_hash=$1", idx).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCardUnknown
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// LegacyCVVDigest keys a CVV issued before CVVs were derived to its card, so
+// migrate-cards can replace the stored plain value with something that is
+// only useful to AuthorizeCard.
+func (v *CardVault) LegacyCVVDigest(cardID, cvv string) (string, error) {
+	sum, err := keyedDigest(v.km, "card-legacy-cvv", []byte(cardID+"|"+cvv))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// AuthorizeCard checks a presented PAN and CVV against the stored card. The
+// CVV only lives for the duration of this call. Cards issued before CVVs
+// were derived keep a digest of their random CVV in cvv_digest, and it is
+// checked instead until ReissueCVV clears it.
+func (v *CardVault) AuthorizeCard(ctx context.Context, db *sql.DB, pan, cvv string) (string, error) {
+	idx, err := v.BlindIndex(pan)
+	if err != nil {
+		return "", err
+	}
+	var id string
+	var month, year int
+	var active bool
+	var legacy sql.NullString
+	err = db.QueryRowContext(ctx, `SELECT id, "expiryMonth", "expiryYear", "isActive", cvv_digest FROM cards WHERE card_number_hash=$1`, idx).Scan(&id, &month, &year, &active, &legacy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCardUnknown
+	}
+	if err != nil {
+		return "", err
+	}
+	if !active {
+		return "", ErrCardInactive
+	}
+	if legacy.Valid {
+		got, err := v.LegacyCVVDigest(id, cvv)
+		if err != nil {
+			return "", err
+		}
+		if subtle.ConstantTimeCompare([]byte(legacy.String), []byte(got)) != 1 {
+			return "", ErrInvalidCVV
+		}
+		return id, nil
+	}
+	if err := v.VerifyCVV(pan, month, year, cvv); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ReissueCVV clears the legacy CVV digest of a card and returns its derived
+// CVV, which from then on is the only one AuthorizeCard accepts.
+func (v *CardVault) ReissueCVV(ctx context.Context, db *sql.DB, cardID string) (*IssuedCard, error) {
+	var enc, hash sql.NullString
+	var month, year int
+	err := db.QueryRowContext(ctx, `SELECT card_number_enc, card_number_hash, "expiryMonth", "expiryYear" FROM cards WHERE id=$1`, cardID).Scan(&enc, &hash, &month, &year)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCardUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !enc.Valid {
+		return nil, ErrCardNotMigrated
+	}
+	pan, err := v.Detokenize(enc.String)
+	if err != nil {
+		return nil, err
+	}
+	cvv, err := v.DeriveCVV(pan, month, year)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE cards SET "cvv"=NULL, cvv_digest=NULL, "updatedAt"=$1 WHERE id=$2`, time.Now(), cardID); err != nil {
+		return nil, err
+	}
+	tok := TokenizedCard{Ciphertext: enc.String, BlindIndex: hash.String, Last4: pan[len(pan)-4:], Masked: MaskPAN(pan)}
+	return &IssuedCard{TokenizedCard: tok, CVV: cvv, ExpiryMonth: month, ExpiryYear: year}, nil
+}
+
+func (v *CardVault) MaskedPAN(ciphertext string) (string, error) {
+	pan, err := v.Detokenize(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return MaskPAN(pan), nil
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "payments-core/generated"
+)
+
+// CardVaultServer issues cards for the card service, which stores only the
+// encrypted PAN, its blind index and last four digits.
+type CardVaultServer struct {
+	pb.UnimplementedCardVaultServiceServer
+	vault *CardVault
+	db    *sql.DB
+}
+
+func NewCardVaultServer(vault *CardVault, db *sql.DB) *CardVaultServer {
+	return &CardVaultServer{vault: vault, db: db}
+}
+
+func (s *CardVaultServer) IssueCard(ctx context.Context, req *pb.IssueCardRequest) (*pb.IssuedCard, error) {
+	card, err := s.vault.IssueCard(int(req.GetExpiryMonth()), int(req.GetExpiryYear()))
+	if err != nil {
+		return nil, StatusFromError(err).Err()
+	}
+	return issuedCardResponse(card), nil
+}
+
+func (s *CardVaultServer) ReissueCVV(ctx context.Context, req *pb.ReissueCVVRequest) (*pb.IssuedCard, error) {
+	if req.GetCardId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "card_id is required")
+	}
+	card, err := s.vault.ReissueCVV(ctx, s.db, req.GetCardId())
+	if err != nil {
+		return nil, StatusFromError(err).Err()
+	}
+	return issuedCardResponse(card), nil
+}
+
+func issuedCardResponse(c *IssuedCard) *pb.IssuedCard {
+	return &pb.IssuedCard{
+		CardNumberEnc:  c.Ciphertext,
+		CardNumberHash: c.BlindIndex,
+		CardLast4:      c.Last4,
+		Masked:         c.Masked,
+		Cvv:            c.CVV,
+		ExpiryMonth:    int32(c.ExpiryMonth),
+		ExpiryYear:     int32(c.ExpiryYear),
+	}
+}
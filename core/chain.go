@@ -0,0 +1,303 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const chainLockKey = 0x6c65646765720001
+
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+var chainSchema = []string{
+	`CREATE TABLE IF NOT EXISTS transaction_chain (
+		seq            BIGINT PRIMARY KEY,
+		transaction_id TEXT NOT NULL,
+		content_hash   TEXT NOT NULL,
+		prev_hash      TEXT NOT NULL,
+		hash           TEXT NOT NULL,
+		signature      TEXT NOT NULL,
+		created_at     TIMESTAMP NOT NULL
+	)`,
+	"CREATE INDEX IF NOT EXISTS transaction_chain_transaction_id_idx ON transaction_chain (transaction_id)",
+	// status is the transaction status the link was written for, so the feed
+	// can replay held and reviewed transfers as they were. Links written
+	// before the column existed leave it NULL.
+	"ALTER TABLE transaction_chain ADD COLUMN IF NOT EXISTS status TEXT",
+	`CREATE TABLE IF NOT EXISTS transaction_chain_checkpoints (
+		seq        BIGINT PRIMARY KEY,
+		hash       TEXT NOT NULL,
+		signature  TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`,
+}
+
+// ChainBreak names the first problem found. Seq is 0 for a transaction that
+// has no link at all.
+type ChainBreak struct {
+	Seq           int64
+	TransactionID string
+	Reason        string
+}
+
+type ChainReport struct {
+	FromSeq int64
+	Checked int64
+	HeadSeq int64
+	Head    string
+	Break   *ChainBreak
+}
+
+type ChainCheckpoint struct {
+	Seq       int64
+	Hash      string
+	Signature string
+	CreatedAt time.Time
+}
+
+func EnsureChainSchema(ctx context.Context, db *sql.DB) error {
+	for _, s := range chainSchema {
+		if _, err := db.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func canonicalTransaction(t *Transaction) []byte {
+	fields := []string{
+		t.ID, t.Type, fmt.Sprintf("%d", t.Amount), t.Description, t.Status, t.Reference,
+		t.FromAccount, t.ToAccount, t.CreatedAt.UTC().Truncate(time.Millisecond).Format(time.RFC3339Nano),
+	}
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%d:%s;", len(f), f)
+	}
+	return []byte(b.String())
+}
+
+func contentHash(t *Transaction) string {
+	sum := sha256.Sum256(canonicalTransaction(t))
+	return hex.EncodeToString(sum[:])
+}
+
+func linkHash(prev string, seq int64, transactionID, content string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s|%s", prev, seq, transactionID, content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// AppendChainLink records the current contents of t as the next link in the
+// chain, along with its status at that point. Call it inside the same database transaction that writes t; the
+// advisory lock serializes writers so every link has exactly one parent.
+func AppendChainLink(ctx context.Context, tx *sql.Tx, km KeyManager, t *Transaction) error {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", int64(chainLockKey)); err != nil {
+		return err
+	}
+	var seq int64
+	prev := genesisHash
+	err := tx.QueryRowContext(ctx, "SELECT seq, hash FROM transaction_chain ORDER BY seq DESC LIMIT 1").Scan(&seq, &prev)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	seq++
+	content := contentHash(t)
+	hash := linkHash(prev, seq, t.ID, content)
+	sig, err := SignPayload([]byte(hash), km)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transaction_chain (seq, transaction_id, content_hash, prev_hash, hash, signature, created_at, status)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`,
+		seq, t.ID, content, prev, hash, sig, time.Now().UTC(), t.Status,
+	)
+	return err
+}
+
+// CreateChainCheckpoint signs the head of a clean verification report, so a
+// checkpoint never vouches for links appended after the replay.
+func CreateChainCheckpoint(ctx context.Context, db *sql.DB, km KeyManager, report *ChainReport) (*ChainCheckpoint, error) {
+	if report.Break != nil {
+		return nil, errors.New("chain is broken")
+	}
+	if report.HeadSeq == 0 {
+		return nil, errors.New("chain is empty")
+	}
+	cp := &ChainCheckpoint{Seq: report.HeadSeq, Hash: report.Head, CreatedAt: time.Now().UTC().Truncate(time.Millisecond)}
+	var err error
+	cp.Signature, err = SignPayload(checkpointPayload(cp), km)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO transaction_chain_checkpoints (seq, hash, signature, created_at)
+		VALUES ($1,$2,$3,$4) ON CONFLICT (seq) DO NOTHING`,
+		cp.Seq, cp.Hash, cp.Signature, cp.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func checkpointPayload(cp *ChainCheckpoint) []byte {
+	return []byte(fmt.Sprintf("checkpoint|%d|%s|%s", cp.Seq, cp.Hash, cp.CreatedAt.UTC().Format(time.RFC3339Nano)))
+}
+
+// VerifyChain replays every link from genesis and returns a report naming the
+// first broken link, or the first transaction without a link, if any. The returned error is reserved for failures to
+// read the chain at all.
+func VerifyChain(ctx context.Context, db *sql.DB, km KeyManager) (*ChainReport, error) {
+	return verifyChainFrom(ctx, db, km, 0, genesisHash)
+}
+
+// VerifyChainSinceCheckpoint trusts the latest checkpoint whose signature and
+// anchor link still check out, and replays only the links after it.
+func VerifyChainSinceCheckpoint(ctx context.Context, db *sql.DB, km KeyManager) (*ChainReport, error) {
+	cp := &ChainCheckpoint{}
+	err := db.QueryRowContext(ctx, "SELECT seq, hash, signature, created_at FROM transaction_chain_checkpoints ORDER BY seq DESC LIMIT 1").Scan(&cp.Seq, &cp.Hash, &cp.Signature, &cp.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return VerifyChain(ctx, db, km)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := VerifySignature(checkpointPayload(cp), cp.Signature, km)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &ChainReport{FromSeq: cp.Seq, Break: &ChainBreak{Seq: cp.Seq, Reason: "checkpoint signature invalid"}}, nil
+	}
+	var anchor string
+	err = db.QueryRowContext(ctx, "SELECT hash FROM transaction_chain WHERE seq=$1", cp.Seq).Scan(&anchor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &ChainReport{FromSeq: cp.Seq, Break: &ChainBreak{Seq: cp.Seq, Reason: "checkpoint link missing"}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != cp.Hash {
+		return &ChainReport{FromSeq: cp.Seq, Break: &ChainBreak{Seq: cp.Seq, Reason: "checkpoint hash mismatch"}}, nil
+	}
+	return verifyChainFrom(ctx, db, km, cp.Seq, cp.Hash)
+}
+
+func verifyChainFrom(ctx context.Context, db *sql.DB, km KeyManager, fromSeq int64, prev string) (*ChainReport, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.seq, c.transaction_id, c.content_hash, c.prev_hash, c.hash, c.signature, c.latest, c.status,
+		       t.id, t.type, t.amount::text, t.description, t.status, t.reference,
+		       COALESCE(t.from_account, ''), COALESCE(t.to_account, ''), t.created_at
+		FROM (
+			SELECT *, seq = MAX(seq) OVER (PARTITION BY transaction_id) AS latest
+			FROM transaction_chain
+		) c
+		LEFT JOIN transactions t ON t.id = c.transaction_id
+		WHERE c.seq > $1
+		ORDER BY c.seq`, fromSeq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &ChainReport{FromSeq: fromSeq, HeadSeq: fromSeq, Head: prev}
+	expectSeq := fromSeq + 1
+	for rows.Next() {
+		var (
+			seq                                int64
+			txID, content, prevHash, hash, sig string
+			latest                             bool
+			linkStatus                         sql.NullString
+			rowID, typ, amount, desc, st, ref  sql.NullString
+			from, to                           string
+			createdAt                          sql.NullTime
+		)
+		if err := rows.Scan(&seq, &txID, &content, &prevHash, &hash, &sig, &latest, &linkStatus,
+			&rowID, &typ, &amount, &desc, &st, &ref, &from, &to, &createdAt); err != nil {
+			return nil, err
+		}
+		broken := func(reason string) (*ChainReport, error) {
+			report.Break = &ChainBreak{Seq: seq, TransactionID: txID, Reason: reason}
+			return report, nil
+		}
+		if seq != expectSeq {
+			return broken(fmt.Sprintf("sequence gap: expected %d", expectSeq))
+		}
+		if prevHash != prev {
+			return broken("previous hash mismatch")
+		}
+		if linkHash(prevHash, seq, txID, content) != hash {
+			return broken("link hash mismatch")
+		}
+		ok, err := VerifySignature([]byte(hash), sig, km)
+		if err != nil || !ok {
+			return broken("link signature invalid")
+		}
+		if latest && !rowID.Valid {
+			return broken("transaction row missing")
+		}
+		// Only the status changes between the links of a transaction, so with
+		// the status snapshot earlier links can be checked against the row too.
+		if rowID.Valid && (latest || linkStatus.Valid) {
+			if latest && linkStatus.Valid && linkStatus.String != st.String {
+				return broken("link status changed")
+			}
+			t := &Transaction{
+				ID: rowID.String, Type: typ.String, Description: desc.String, Status: st.String,
+				Reference: ref.String, FromAccount: from, ToAccount: to, CreatedAt: createdAt.Time,
+			}
+			if linkStatus.Valid {
+				t.Status = linkStatus.String
+			}
+			t.Amount, err = parseMinorUnits(amount.String)
+			if err != nil || contentHash(t) != content {
+				return broken("transaction contents changed")
+			}
+		}
+		prev = hash
+		expectSeq++
+		report.Checked++
+		report.HeadSeq = seq
+		report.Head = hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Links only vouch for rows that have them; a transaction written
+	// around AppendChainLink shows up here instead.
+	var unlinked string
+	err = db.QueryRowContext(ctx, `
+		SELECT t.id FROM transactions t
+		WHERE NOT EXISTS (SELECT 1 FROM transaction_chain c WHERE c.transaction_id = t.id)
+		ORDER BY t.created_at, t.id LIMIT 1`).Scan(&unlinked)
+	if err == nil {
+		report.Break = &ChainBreak{TransactionID: unlinked, Reason: "transaction not in chain"}
+		return report, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// parseMinorUnits accepts the text form of a numeric column, which may carry
+// trailing fractional zeros, and rejects anything that is not a whole number.
+func parseMinorUnits(s string) (int64, error) {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		if strings.Trim(s[i+1:], "0") != "" {
+			return 0, errors.New("fractional amount")
+		}
+		s = s[:i]
+	}
+	var n int64
+	_, err := fmt.Sscan(s, &n)
+	return n, err
+}
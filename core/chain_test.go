@@ -0,0 +1,173 @@
+package core_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	core "payments-core"
+)
+
+var chainWalkCols = []string{
+	"seq", "transaction_id", "content_hash", "prev_hash", "hash", "signature", "latest", "link_status",
+	"id", "type", "amount", "description", "status", "reference", "from_account", "to_account", "created_at",
+}
+
+// appendLink runs AppendChainLink on top of the links in f and returns the row
+// VerifyChain would read back for tr.
+func appendLink(t *testing.T, f *fakeDB, km core.KeyManager, tr *core.Transaction) []driver.Value {
+	t.Helper()
+	db, link := newFakeDB(t)
+	link.on("SELECT seq, hash FROM transaction_chain ORDER BY", []string{"seq", "hash"}, f.head()...)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := core.AppendChainLink(context.Background(), tx, km, tr); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	ins := link.execsMatching("INSERT INTO transaction_chain")
+	if len(ins) != 1 {
+		t.Fatalf("got %d chain inserts, want 1", len(ins))
+	}
+	a := ins[0].args
+	return []driver.Value{
+		a[0], a[1], a[2], a[3], a[4], a[5], true, a[7],
+		tr.ID, tr.Type, fmt.Sprint(tr.Amount), tr.Description, tr.Status, tr.Reference,
+		tr.FromAccount, tr.ToAccount, tr.CreatedAt,
+	}
+}
+
+// head returns the last chain link recorded in f as a head query row.
+func (f *fakeDB) head() [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows := f.results["PARTITION BY transaction_id"].rows
+	if len(rows) == 0 {
+		return nil
+	}
+	last := rows[len(rows)-1]
+	return [][]driver.Value{{last[0], last[4]}}
+}
+
+func TestVerifyChain(t *testing.T) {
+	km := newTestKeyManager(t)
+	at := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	transfers := []*core.Transaction{
+		{ID: "t1", Type: "TRANSFER", Amount: 1500, Status: core.StatusCompleted, Reference: "r1", FromAccount: "A", ToAccount: "B", CreatedAt: at},
+		{ID: "t2", Type: "TRANSFER", Amount: 700, Status: core.StatusCompleted, Reference: "r2", FromAccount: "B", ToAccount: "A", CreatedAt: at.Add(time.Minute)},
+	}
+
+	tests := []struct {
+		name     string
+		tamper   func(rows [][]driver.Value)
+		unlinked string
+		want     string
+	}{
+		{name: "intact"},
+		{name: "amount changed", tamper: func(rows [][]driver.Value) { rows[1][10] = "70000" }, want: "transaction contents changed"},
+		{name: "link hash replaced", tamper: func(rows [][]driver.Value) { rows[0][4] = rows[1][4] }, want: "link hash mismatch"},
+		{name: "link removed", tamper: func(rows [][]driver.Value) { rows[0] = rows[1] }, want: "sequence gap: expected 1"},
+		{name: "transaction inserted without a link", unlinked: "t3", want: "transaction not in chain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := newFakeDB(t)
+			f.on("PARTITION BY transaction_id", chainWalkCols)
+			var rows [][]driver.Value
+			for _, tr := range transfers {
+				rows = append(rows, appendLink(t, f, km, tr))
+				f.on("PARTITION BY transaction_id", chainWalkCols, rows...)
+			}
+			if tt.tamper != nil {
+				tt.tamper(rows)
+			}
+			f.on("PARTITION BY transaction_id", chainWalkCols, rows...)
+			if tt.unlinked != "" {
+				f.on("NOT EXISTS", []string{"id"}, []driver.Value{tt.unlinked})
+			} else {
+				f.on("NOT EXISTS", []string{"id"})
+			}
+
+			report, err := core.VerifyChain(context.Background(), db, km)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if report.Break != nil {
+					t.Fatalf("unexpected break: %+v", report.Break)
+				}
+				if report.Checked != 2 || report.HeadSeq != 2 {
+					t.Fatalf("checked %d links up to seq %d, want 2 and 2", report.Checked, report.HeadSeq)
+				}
+				return
+			}
+			if report.Break == nil {
+				t.Fatalf("chain verified, want break %q", tt.want)
+			}
+			if report.Break.Reason != tt.want {
+				t.Fatalf("break %+v, want reason %q", report.Break, tt.want)
+			}
+			if tt.unlinked != "" && report.Break.TransactionID != tt.unlinked {
+				t.Fatalf("break names transaction %s, want %s", report.Break.TransactionID, tt.unlinked)
+			}
+		})
+	}
+}
+
+func TestVerifyChainStatusSnapshots(t *testing.T) {
+	km := newTestKeyManager(t)
+	at := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	held := &core.Transaction{ID: "t1", Type: "TRANSFER", Amount: 90000, Status: core.StatusPendingReview, Reference: "r1", FromAccount: "A", ToAccount: "B", CreatedAt: at}
+	approved := *held
+	approved.Status = core.StatusCompleted
+
+	tests := []struct {
+		name    string
+		tamper  func(rows [][]driver.Value)
+		wantSeq int64
+		want    string
+	}{
+		{name: "intact"},
+		{name: "links written before snapshots", tamper: func(rows [][]driver.Value) { rows[0][7], rows[1][7] = nil, nil }},
+		{name: "earlier snapshot rewritten", tamper: func(rows [][]driver.Value) { rows[0][7] = core.StatusCompleted }, wantSeq: 1, want: "transaction contents changed"},
+		{name: "latest snapshot rewritten", tamper: func(rows [][]driver.Value) { rows[1][7] = core.StatusRejected }, wantSeq: 2, want: "link status changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := newFakeDB(t)
+			f.on("PARTITION BY transaction_id", chainWalkCols)
+			first := appendLink(t, f, km, held)
+			f.on("PARTITION BY transaction_id", chainWalkCols, first)
+			second := appendLink(t, f, km, &approved)
+			// The walk joins every link to the row as it is now.
+			copy(first[8:], second[8:])
+			first[6] = false
+			rows := [][]driver.Value{first, second}
+			if tt.tamper != nil {
+				tt.tamper(rows)
+			}
+			f.on("PARTITION BY transaction_id", chainWalkCols, rows...)
+			f.on("NOT EXISTS", []string{"id"})
+
+			report, err := core.VerifyChain(context.Background(), db, km)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if report.Break != nil {
+					t.Fatalf("unexpected break: %+v", report.Break)
+				}
+				return
+			}
+			if report.Break == nil || report.Break.Seq != tt.wantSeq || report.Break.Reason != tt.want {
+				t.Fatalf("break %+v, want %q at seq %d", report.Break, tt.want, tt.wantSeq)
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+// Package client wraps the generated TransferService client with deadlines,
+// conservative retries, hedged reads, optional connection pooling and typed
+// errors.
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	core "payments-core"
+	pb "payments-core/generated"
+)
+
+// ServiceConfig lets grpc-go retry UNAVAILABLE answers to the read methods
+// on its own. grpc-go may replay an RPC that already reached the server, so
+// the methods that move money are left out; Transfer is retried by the
+// client with its idempotency key instead. grpc-go does not implement
+// hedgingPolicy, so hedged reads are sent by the client (Config.HedgeDelay).
+const ServiceConfig = `{
+	"loadBalancingConfig": [{"round_robin": {}}],
+	"methodConfig": [{
+		"name": [{"service": "transfer.TransferService"}],
+		"waitForReady": false,
+		"timeout": "10s"
+	}, {
+		"name": [
+			{"service": "transfer.TransferService", "method": "GetTransfer"},
+			{"service": "transfer.TransferService", "method": "GetBalance"},
+			{"service": "transfer.TransferService", "method": "GetBalances"}
+		],
+		"waitForReady": false,
+		"timeout": "10s",
+		"retryPolicy": {
+			"maxAttempts": 3,
+			"initialBackoff": "0.1s",
+			"maxBackoff": "1s",
+			"backoffMultiplier": 2.0,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
+type Config struct {
+	Target      string
+	TLS         *tls.Config
+	Insecure    bool
+	DialOptions []grpc.DialOption
+	Timeout     time.Duration
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	PoolSize    int
+	// HedgeDelay, when set, sends another copy of a read that has not been
+	// answered after that long, up to HedgeAttempts copies in all (default
+	// 2). The first answer wins and the others are cancelled. Only the read
+	// methods are hedged.
+	HedgeDelay    time.Duration
+	HedgeAttempts int
+}
+
+type Client struct {
+	cfg     Config
+	conns   []*grpc.ClientConn
+	clients []pb.TransferServiceClient
+	next    atomic.Uint32
+}
+
+// Error is returned for every failed call. Err is the matching core error
+// when the server sent one, so callers can use errors.Is(err,
+// core.ErrInsufficientBalance) and friends.
+type Error struct {
+	Code    codes.Code
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("transfer service: %s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var (
+	ErrUnavailable      = errors.New("transfer service unavailable")
+	ErrDeadlineExceeded = errors.New("transfer service deadline exceeded")
+	ErrUnauthenticated  = errors.New("transfer service rejected credentials")
+	ErrPermissionDenied = errors.New("transfer service denied the call")
+)
+
+func New(cfg Config) (*Client, error) {
+	if cfg.Target == "" {
+		return nil, errors.New("target is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 50 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Second
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = 1
+	}
+	if cfg.HedgeAttempts <= 0 {
+		cfg.HedgeAttempts = 2
+	}
+	opts := []grpc.DialOption{grpc.WithDefaultServiceConfig(ServiceConfig)}
+	switch {
+	case cfg.TLS != nil:
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(cfg.TLS)))
+	case cfg.Insecure:
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	opts = append(opts, cfg.DialOptions...)
+	c := &Client{cfg: cfg}
+	for i := 0; i < cfg.PoolSize; i++ {
+		conn, err := grpc.NewClient(cfg.Target, opts...)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.conns = append(c.conns, conn)
+		c.clients = append(c.clients, pb.NewTransferServiceClient(conn))
+	}
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	var first error
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (c *Client) pick() pb.TransferServiceClient {
+	n := c.next.Add(1)
+	return c.clients[int(n)%len(c.clients)]
+}
+
+// Transfer sets an idempotency key when req has none and retries
+// UNAVAILABLE answers with that same key, so a retry after the server
+// committed returns the original transfer rather than making a second one.
+func (c *Client) Transfer(ctx context.Context, req *pb.TransferRequest, opts ...grpc.CallOption) (*pb.TransferResponse, error) {
+	if req.GetIdempotencyKey() == "" {
+		key, err := newIdempotencyKey()
+		if err != nil {
+			return nil, err
+		}
+		req = proto.Clone(req).(*pb.TransferRequest)
+		req.IdempotencyKey = key
+	}
+	return invoke(ctx, c, true, func(ctx context.Context, cl pb.TransferServiceClient, opts ...grpc.CallOption) (*pb.TransferResponse, error) {
+		return cl.Transfer(ctx, req, opts...)
+	}, opts...)
+}
+
+func (c *Client) GetTransfer(ctx context.Context, req *pb.GetTransferRequest, opts ...grpc.CallOption) (*pb.TransferResponse, error) {
+	return hedge(ctx, c, func(ctx context.Context, cl pb.TransferServiceClient, opts ...grpc.CallOption) (*pb.TransferResponse, error) {
+		return cl.GetTransfer(ctx, req, opts...)
+	}, opts...)
+}
+
+func (c *Client) ApproveTransfer(ctx context.Context, req *pb.ReviewTransferRequest, opts ...grpc.CallOption) (*pb.TransferResponse, error) {
+	return invoke(ctx, c, false, func(ctx context.Context, cl pb.TransferServiceClient, opts ...grpc.CallOption) (*pb.TransferResponse, error) {
+		return cl.ApproveTransfer(ctx, req, opts...)
+	}, opts...)
+}
+
+func (c *Client) RejectTransfer(ctx context.Context, req *pb.ReviewTransferRequest, opts ...grpc.CallOption) (*pb.TransferResponse, error) {
+	return invoke(ctx, c, false, func(ctx context.Context, cl pb.TransferServiceClient, opts ...grpc.CallOption) (*pb.TransferResponse, error) {
+		return cl.RejectTransfer(ctx, req, opts...)
+	}, opts...)
+}
+
+func (c *Client) GetBalance(ctx context.Context, req *pb.GetBalanceRequest, opts ...grpc.CallOption) (*pb.Balance, error) {
+	return hedge(ctx, c, func(ctx context.Context, cl pb.TransferServiceClient, opts ...grpc.CallOption) (*pb.Balance, error) {
+		return cl.GetBalance(ctx, req, opts...)
+	}, opts...)
+}
+
+func (c *Client) GetBalances(ctx context.Context, req *pb.GetBalancesRequest, opts ...grpc.CallOption) (*pb.GetBalancesResponse, error) {
+	return hedge(ctx, c, func(ctx context.Context, cl pb.TransferServiceClient, opts ...grpc.CallOption) (*pb.GetBalancesResponse, error) {
+		return cl.GetBalances(ctx, req, opts...)
+	}, opts...)
+}
+
+// WatchTransactions delivers feed events to fn until ctx ends or fn returns
+// an error. Streams that drop with UNAVAILABLE are reopened from the cursor
+// of the last event fn accepted, so nothing is skipped or repeated. No
+// default deadline is applied to the stream.
+func (c *Client) WatchTransactions(ctx context.Context, req *pb.WatchTransactionsRequest, fn func(*pb.TransactionEvent) error, opts ...grpc.CallOption) error {
+	req = proto.Clone(req).(*pb.WatchTransactionsRequest)
+	failures := 0
+	for {
+		err := c.watch(ctx, req, fn, &failures, opts...)
+		if _, ok := status.FromError(err); !ok {
+			return err
+		}
+		if status.Code(err) != codes.Unavailable || failures >= c.cfg.MaxAttempts {
+			return toError(err)
+		}
+		t := time.NewTimer(c.backoff(failures))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return toError(err)
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) watch(ctx context.Context, req *pb.WatchTransactionsRequest, fn func(*pb.TransactionEvent) error, failures *int, opts ...grpc.CallOption) error {
+	stream, err := c.pick().WatchTransactions(ctx, req, opts...)
+	if err != nil {
+		*failures++
+		return err
+	}
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			*failures++
+			return err
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+		*failures = 0
+		req.Cursor = ev.GetCursor()
+		req.ReplaySeconds = 0
+	}
+}
+
+// invoke applies the default deadline. With retry it also retries
+// UNAVAILABLE answers, which is only safe for idempotent calls; the read
+// methods are instead retried by grpc-go through ServiceConfig, and
+// approvals not at all, so no call has two retry layers. Hedged copies of a
+// read are separate calls, each with its own grpc-go retries.
+func invoke[T any](ctx context.Context, c *Client, retry bool, call func(context.Context, pb.TransferServiceClient, ...grpc.CallOption) (T, error), opts ...grpc.CallOption) (T, error) {
+	var zero T
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+	attempts := 1
+	if retry {
+		attempts = c.cfg.MaxAttempts
+	}
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			t := time.NewTimer(c.backoff(attempt))
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return zero, toError(lastErr)
+			case <-t.C:
+			}
+		}
+		out, err := call(ctx, c.pick(), opts...)
+		if err == nil {
+			return out, nil
+		}
+		lastErr = err
+		if status.Code(err) != codes.Unavailable {
+			break
+		}
+	}
+	return zero, toError(lastErr)
+}
+
+// hedge runs an idempotent read like invoke, and with HedgeDelay set sends
+// another copy each time that delay passes without an answer, or at once
+// when a copy fails with UNAVAILABLE. Any other answer, success or not, is
+// final.
+func hedge[T any](ctx context.Context, c *Client, call func(context.Context, pb.TransferServiceClient, ...grpc.CallOption) (T, error), opts ...grpc.CallOption) (T, error) {
+	if c.cfg.HedgeDelay <= 0 {
+		return invoke(ctx, c, false, call, opts...)
+	}
+	var zero T
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		out T
+		err error
+	}
+	results := make(chan result, c.cfg.HedgeAttempts)
+	sent, pending := 0, 0
+	send := func() {
+		sent++
+		pending++
+		cl := c.pick()
+		go func() {
+			out, err := call(ctx, cl, opts...)
+			results <- result{out, err}
+		}()
+	}
+	send()
+	timer := time.NewTimer(c.cfg.HedgeDelay)
+	defer timer.Stop()
+	var lastErr error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.out, nil
+			}
+			lastErr = r.err
+			if status.Code(r.err) != codes.Unavailable {
+				return zero, toError(r.err)
+			}
+			if sent < c.cfg.HedgeAttempts && ctx.Err() == nil {
+				send()
+				timer.Reset(c.cfg.HedgeDelay)
+			}
+		case <-timer.C:
+			if sent < c.cfg.HedgeAttempts {
+				send()
+				timer.Reset(c.cfg.HedgeDelay)
+			}
+		}
+	}
+	return zero, toError(lastErr)
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.BaseBackoff << (attempt - 1)
+	if d > c.cfg.MaxBackoff || d <= 0 {
+		d = c.cfg.MaxBackoff
+	}
+	return d/2 + mrand.N(d/2+1)
+}
+
+func toError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	e := &Error{Code: st.Code(), Message: st.Message(), Err: core.ErrorFromStatus(st)}
+	if e.Err == nil {
+		switch st.Code() {
+		case codes.Unavailable:
+			e.Err = ErrUnavailable
+		case codes.DeadlineExceeded:
+			e.Err = ErrDeadlineExceeded
+		case codes.Unauthenticated:
+			e.Err = ErrUnauthenticated
+		case codes.PermissionDenied:
+			e.Err = ErrPermissionDenied
+		}
+	}
+	return e
+}
@@ -0,0 +1,279 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	core "payments-core"
+	"payments-core/client"
+	pb "payments-core/generated"
+)
+
+// fakeServer answers with whatever its handler funcs return and records the
+// requests it saw.
+type fakeServer struct {
+	pb.UnimplementedTransferServiceServer
+
+	mu        sync.Mutex
+	transfers []*pb.TransferRequest
+	watches   []*pb.WatchTransactionsRequest
+	reads     int
+
+	transfer func(n int) (*pb.TransferResponse, error)
+	balance  func(ctx context.Context, n int) (*pb.Balance, error)
+	watch    func(n int, req *pb.WatchTransactionsRequest, send func(*pb.TransactionEvent) error) error
+}
+
+func (s *fakeServer) Transfer(ctx context.Context, req *pb.TransferRequest) (*pb.TransferResponse, error) {
+	s.mu.Lock()
+	s.transfers = append(s.transfers, req)
+	n := len(s.transfers)
+	s.mu.Unlock()
+	return s.transfer(n)
+}
+
+func (s *fakeServer) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb.Balance, error) {
+	s.mu.Lock()
+	s.reads++
+	n := s.reads
+	s.mu.Unlock()
+	return s.balance(ctx, n)
+}
+
+func (s *fakeServer) WatchTransactions(req *pb.WatchTransactionsRequest, stream grpc.ServerStreamingServer[pb.TransactionEvent]) error {
+	s.mu.Lock()
+	s.watches = append(s.watches, req)
+	n := len(s.watches)
+	s.mu.Unlock()
+	return s.watch(n, req, stream.Send)
+}
+
+func newTestClient(t *testing.T, srv *fakeServer, cfg client.Config) *client.Client {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	pb.RegisterTransferServiceServer(gs, srv)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	cfg.Target = "passthrough:///bufnet"
+	cfg.Insecure = true
+	cfg.BaseBackoff = time.Millisecond
+	cfg.MaxBackoff = 5 * time.Millisecond
+	cfg.DialOptions = append(cfg.DialOptions, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))
+	c, err := client.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestTransferRetriesUnavailableWithOneKey(t *testing.T) {
+	srv := &fakeServer{transfer: func(n int) (*pb.TransferResponse, error) {
+		if n < 3 {
+			return nil, status.Error(codes.Unavailable, "database unavailable")
+		}
+		return &pb.TransferResponse{TransactionId: "t1", Status: core.StatusCompleted}, nil
+	}}
+	c := newTestClient(t, srv, client.Config{MaxAttempts: 3})
+
+	resp, err := c.Transfer(context.Background(), &pb.TransferRequest{FromAccountId: "a", ToAccountId: "b", Amount: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetTransactionId() != "t1" {
+		t.Fatalf("got transaction %q, want t1", resp.GetTransactionId())
+	}
+	if len(srv.transfers) != 3 {
+		t.Fatalf("server saw %d attempts, want 3", len(srv.transfers))
+	}
+	key := srv.transfers[0].GetIdempotencyKey()
+	if key == "" {
+		t.Fatal("no idempotency key was set")
+	}
+	for i, req := range srv.transfers {
+		if req.GetIdempotencyKey() != key {
+			t.Fatalf("attempt %d used key %q, want %q", i+1, req.GetIdempotencyKey(), key)
+		}
+	}
+}
+
+func TestTransferGivesUpAfterMaxAttempts(t *testing.T) {
+	srv := &fakeServer{transfer: func(int) (*pb.TransferResponse, error) {
+		return nil, status.Error(codes.Unavailable, "database unavailable")
+	}}
+	c := newTestClient(t, srv, client.Config{MaxAttempts: 2})
+
+	_, err := c.Transfer(context.Background(), &pb.TransferRequest{FromAccountId: "a", ToAccountId: "b", Amount: 100})
+	if !errors.Is(err, client.ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+	if len(srv.transfers) != 2 {
+		t.Fatalf("server saw %d attempts, want 2", len(srv.transfers))
+	}
+}
+
+func TestTransferDoesNotRetryAnswers(t *testing.T) {
+	srv := &fakeServer{transfer: func(int) (*pb.TransferResponse, error) {
+		return nil, core.StatusFromError(core.ErrInsufficientBalance).Err()
+	}}
+	c := newTestClient(t, srv, client.Config{MaxAttempts: 3})
+
+	_, err := c.Transfer(context.Background(), &pb.TransferRequest{FromAccountId: "a", ToAccountId: "b", Amount: 100})
+	if !errors.Is(err, core.ErrInsufficientBalance) {
+		t.Fatalf("got %v, want ErrInsufficientBalance", err)
+	}
+	var ce *client.Error
+	if !errors.As(err, &ce) || ce.Code != codes.FailedPrecondition {
+		t.Fatalf("got %#v, want a FailedPrecondition client.Error", err)
+	}
+	if len(srv.transfers) != 1 {
+		t.Fatalf("server saw %d attempts, want 1", len(srv.transfers))
+	}
+}
+
+func TestReadsAreRetriedByServiceConfig(t *testing.T) {
+	srv := &fakeServer{balance: func(_ context.Context, n int) (*pb.Balance, error) {
+		if n == 1 {
+			return nil, status.Error(codes.Unavailable, "replica unavailable")
+		}
+		return &pb.Balance{AccountId: "a", LedgerBalance: 500}, nil
+	}}
+	c := newTestClient(t, srv, client.Config{})
+
+	b, err := c.GetBalance(context.Background(), &pb.GetBalanceRequest{AccountId: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.GetLedgerBalance() != 500 || srv.reads != 2 {
+		t.Fatalf("got balance %d after %d reads, want 500 after 2", b.GetLedgerBalance(), srv.reads)
+	}
+}
+
+func TestHedgedReadTakesFirstAnswer(t *testing.T) {
+	abandoned := make(chan struct{})
+	srv := &fakeServer{balance: func(ctx context.Context, n int) (*pb.Balance, error) {
+		if n == 1 {
+			<-ctx.Done()
+			close(abandoned)
+			return nil, ctx.Err()
+		}
+		return &pb.Balance{AccountId: "a", LedgerBalance: 500}, nil
+	}}
+	c := newTestClient(t, srv, client.Config{HedgeDelay: 20 * time.Millisecond, Timeout: 5 * time.Second})
+
+	start := time.Now()
+	b, err := c.GetBalance(context.Background(), &pb.GetBalanceRequest{AccountId: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.GetLedgerBalance() != 500 {
+		t.Fatalf("got balance %d, want 500", b.GetLedgerBalance())
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("hedged read took %s", d)
+	}
+	select {
+	case <-abandoned:
+	case <-time.After(time.Second):
+		t.Fatal("the slow copy was not cancelled")
+	}
+}
+
+func TestHedgedReadReturnsDefinitiveError(t *testing.T) {
+	srv := &fakeServer{balance: func(context.Context, int) (*pb.Balance, error) {
+		return nil, core.StatusFromError(core.ErrAccountNotFound).Err()
+	}}
+	c := newTestClient(t, srv, client.Config{HedgeDelay: 20 * time.Millisecond})
+
+	_, err := c.GetBalance(context.Background(), &pb.GetBalanceRequest{AccountId: "a"})
+	if !errors.Is(err, core.ErrAccountNotFound) {
+		t.Fatalf("got %v, want ErrAccountNotFound", err)
+	}
+	if srv.reads != 1 {
+		t.Fatalf("server saw %d reads, want 1", srv.reads)
+	}
+}
+
+func TestWatchTransactionsResumesFromLastCursor(t *testing.T) {
+	event := func(seq int) *pb.TransactionEvent {
+		return &pb.TransactionEvent{Cursor: strconv.Itoa(seq), TransactionId: "t" + strconv.Itoa(seq)}
+	}
+	srv := &fakeServer{watch: func(n int, req *pb.WatchTransactionsRequest, send func(*pb.TransactionEvent) error) error {
+		from := 1
+		if req.GetCursor() != "" {
+			c, _ := strconv.Atoi(req.GetCursor())
+			from = c + 1
+		}
+		// Each stream delivers two events and then drops.
+		for seq := from; seq < from+2; seq++ {
+			if err := send(event(seq)); err != nil {
+				return err
+			}
+		}
+		if n == 1 {
+			return status.Error(codes.Unavailable, "feed restarting")
+		}
+		return nil
+	}}
+	c := newTestClient(t, srv, client.Config{MaxAttempts: 2})
+
+	stop := errors.New("stop")
+	var got []string
+	err := c.WatchTransactions(context.Background(), &pb.WatchTransactionsRequest{AccountIds: []string{"a"}, ReplaySeconds: 60}, func(ev *pb.TransactionEvent) error {
+		got = append(got, ev.GetTransactionId())
+		if len(got) == 4 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("got %v, want the callback's error", err)
+	}
+	want := []string{"t1", "t2", "t3", "t4"}
+	if len(got) != len(want) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got events %v, want %v", got, want)
+		}
+	}
+	if len(srv.watches) != 2 {
+		t.Fatalf("server saw %d streams, want 2", len(srv.watches))
+	}
+	if first := srv.watches[0]; first.GetCursor() != "" || first.GetReplaySeconds() != 60 {
+		t.Fatalf("first stream asked for cursor %q replay %d", first.GetCursor(), first.GetReplaySeconds())
+	}
+	if resumed := srv.watches[1]; resumed.GetCursor() != "2" || resumed.GetReplaySeconds() != 0 {
+		t.Fatalf("resumed stream asked for cursor %q replay %d, want 2 and 0", resumed.GetCursor(), resumed.GetReplaySeconds())
+	}
+}
+
+func TestWatchTransactionsStopsOnOtherErrors(t *testing.T) {
+	srv := &fakeServer{watch: func(int, *pb.WatchTransactionsRequest, func(*pb.TransactionEvent) error) error {
+		return status.Error(codes.PermissionDenied, "notification-service may not call WatchTransactions")
+	}}
+	c := newTestClient(t, srv, client.Config{MaxAttempts: 3})
+
+	err := c.WatchTransactions(context.Background(), &pb.WatchTransactionsRequest{AccountIds: []string{"a"}}, func(*pb.TransactionEvent) error { return nil })
+	if !errors.Is(err, client.ErrPermissionDenied) {
+		t.Fatalf("got %v, want ErrPermissionDenied", err)
+	}
+	if len(srv.watches) != 1 {
+		t.Fatalf("server saw %d streams, want 1", len(srv.watches))
+	}
+}
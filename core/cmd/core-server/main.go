@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	_ "github.com/lib/pq"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	core "payments-core"
+	pb "payments-core/generated"
+)
+
+// defaultPolicy gives each service only the methods it uses. Methods not
+// listed, and principals not named, are denied; set -allow (CORE_ALLOW) to
+// change it. Server reflection is off by default; with -reflection, allow
+// /grpc.reflection.v1.ServerReflection/ServerReflectionInfo (and the v1alpha
+// name for older tools) to whoever may use it.
+const defaultPolicy = "Transfer=account-service," +
+	"GetTransfer=account-service|manager-server," +
+	"GetBalance=account-service|manager-server," +
+	"GetBalances=account-service|manager-server," +
+	"WatchTransactions=account-service|notification-service," +
+	"ApproveTransfer=manager-server," +
+	"RejectTransfer=manager-server," +
+	"/transfer.CardVaultService/IssueCard=card-service," +
+	"/transfer.CardVaultService/ReissueCVV=card-service"
+
+func main() {
+	listen := flag.String("listen", envOr("CORE_LISTEN_ADDR", ":50051"), "")
+	dsn := flag.String("db", os.Getenv("DATABASE_URL"), "")
+	riskRules := flag.String("risk-rules", os.Getenv("CORE_RISK_RULES"), "")
+	replicaDSN := flag.String("replica-db", os.Getenv("REPLICA_DATABASE_URL"), "")
+	keys := core.KeyManagerFlags{RequireEventKey: true}
+	keys.Register(flag.CommandLine)
+	allow := flag.String("allow", envOr("CORE_ALLOW", defaultPolicy), "")
+	tlsCert := flag.String("tls-cert", os.Getenv("CORE_TLS_CERT"), "")
+	tlsKey := flag.String("tls-key", os.Getenv("CORE_TLS_KEY"), "")
+	clientCA := flag.String("tls-client-ca", os.Getenv("CORE_TLS_CLIENT_CA"), "")
+	httpListen := flag.String("http-listen", os.Getenv("CORE_HTTP_ADDR"), "")
+	httpPrefix := flag.String("http-prefix", os.Getenv("CORE_HTTP_PREFIX"), "")
+	reflect := flag.Bool("reflection", envOr("CORE_REFLECTION", "false") == "true", "")
+	// -issue-token prints a service token for the given subject, such as
+	// card-service for CORE_SERVICE_TOKEN, and exits.
+	issueToken := flag.String("issue-token", "", "")
+	tokenTTL := flag.Duration("token-ttl", 90*24*time.Hour, "")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	if *issueToken != "" {
+		keys.RequireEventKey = false
+		km, closeKeys, err := keys.Open()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		token, err := core.IssueServiceToken(km, *issueToken, *tokenTTL)
+		closeKeys()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(token)
+		return
+	}
+	if *dsn == "" {
+		fmt.Fprintln(os.Stderr, "DATABASE_URL not provided")
+		os.Exit(2)
+	}
+	km, closeKeys, err := keys.Open()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer closeKeys()
+	policy, err := parsePolicy(*allow)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer db.Close()
+	for _, ensure := range []func(context.Context, *sql.DB) error{core.EnsureChainSchema, core.EnsureHoldSchema, core.EnsureReviewSchema, core.EnsureIdempotencySchema} {
+		if err := ensure(context.Background(), db); err != nil {
+			fmt.Fprintln(os.Stderr, "schema:", err)
+			os.Exit(2)
+		}
+	}
+
+	opts := core.ServerInterceptors(core.InterceptorConfig{
+		KeyManager:      km,
+		Policy:          policy,
+		Logger:          logger,
+		Unauthenticated: core.HealthMethods,
+	})
+	var tlsCfg, httpTLSCfg *tls.Config
+	if *tlsCert != "" {
+		tlsCfg, err = core.ServerTLSConfig(*tlsCert, *tlsKey, *clientCA)
+		if err == nil {
+			httpTLSCfg, err = core.HTTPServerTLSConfig(*tlsCert, *tlsKey, *clientCA)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "tls:", err)
+			os.Exit(2)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	srv := grpc.NewServer(opts...)
+	kafka := &core.KafkaService{}
+	transfers := core.NewTransferServer(db, kafka, km)
+	var risk *core.RuleEvaluator
+	if *riskRules != "" {
+		risk, err = core.LoadRuleEvaluator(*riskRules)
+	} else {
+		logger.Info("no risk rules file configured; using the built-in rules")
+		risk, err = core.DefaultRuleEvaluator()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "risk rules:", err)
+		os.Exit(2)
+	}
+	transfers.Risk = risk
+	if *replicaDSN != "" {
+		replica, err := sql.Open("postgres", *replicaDSN)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		defer replica.Close()
+		transfers.Replica = replica
+	}
+	pb.RegisterTransferServiceServer(srv, transfers)
+	cards := core.NewCardVaultServer(core.NewCardVault(km), db)
+	pb.RegisterCardVaultServiceServer(srv, cards)
+
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	monitor := core.NewHealthMonitor(hs,
+		core.DBHealthCheck(db),
+		core.KeyManagerHealthCheck(km),
+		core.PublisherHealthCheck(kafka),
+	)
+	monitor.Logger = logger
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go monitor.Run(ctx)
+	if *reflect {
+		reflection.Register(srv)
+	}
+
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	go func() {
+		logger.Info("core listening", slog.String("addr", *listen))
+		if err := srv.Serve(lis); err != nil {
+			logger.Error("serve failed", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}()
+
+	var httpSrv *http.Server
+	if *httpListen != "" {
+		var handler http.Handler = core.NewRESTHandler(transfers, core.RESTConfig{KeyManager: km, Policy: policy, Logger: logger, Cards: cards})
+		if *httpPrefix != "" {
+			handler = http.StripPrefix(strings.TrimRight(*httpPrefix, "/"), handler)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("GET /.well-known/jwks.json", core.JWKSHandler(km))
+		mux.Handle("/", handler)
+		httpSrv = &http.Server{Addr: *httpListen, Handler: mux, TLSConfig: httpTLSCfg, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			logger.Info("core http listening", slog.String("addr", *httpListen))
+			var err error
+			if httpTLSCfg != nil {
+				err = httpSrv.ListenAndServeTLS("", "")
+			} else {
+				err = httpSrv.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("http serve failed", slog.Any("error", err))
+				os.Exit(1)
+			}
+		}()
+	} else {
+		logger.Warn("no http listener; event consumers cannot fetch the JWKS")
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	cancel()
+	hs.Shutdown()
+	if httpSrv != nil {
+		shutdownCtx, done := context.WithTimeout(context.Background(), 10*time.Second)
+		httpSrv.Shutdown(shutdownCtx)
+		done()
+	}
+	srv.GracefulStop()
+}
+
+// parsePolicy reads "Transfer=account-service|gateway,*=admin"; short method
+// names are expanded to the TransferService full method name.
+func parsePolicy(s string) (core.MethodPolicy, error) {
+	policy := core.MethodPolicy{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		method, principals, ok := strings.Cut(entry, "=")
+		if !ok || method == "" || principals == "" {
+			return nil, fmt.Errorf("invalid policy entry %q", entry)
+		}
+		if method != "*" && !strings.HasPrefix(method, "/") {
+			method = "/" + pb.TransferService_ServiceDesc.ServiceName + "/" + method
+		}
+		policy[method] = append(policy[method], strings.Split(principals, "|")...)
+	}
+	return policy, nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
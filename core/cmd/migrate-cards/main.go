@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	core "payments-core"
+)
+
+type cardRow struct {
+	id     string
+	number sql.NullString
+	enc    sql.NullString
+	cvv    sql.NullString
+	month  int
+	year   int
+}
+
+func main() {
+	dsn := flag.String("db", os.Getenv("DATABASE_URL"), "")
+	var keys core.KeyManagerFlags
+	keys.Register(flag.CommandLine)
+	batch := flag.Int("batch", 500, "")
+	// Plain CVVs never survive the migration. Those that match the derived
+	// value are dropped; others are replaced by a keyed digest, which keeps
+	// being accepted until the card is reissued through
+	// CardVaultService.ReissueCVV. -drop-cvv drops them too, which
+	// invalidates those cards immediately.
+	dropCVV := flag.Bool("drop-cvv", false, "")
+	dryRun := flag.Bool("dry-run", false, "")
+	flag.Parse()
+
+	if *dsn == "" {
+		fmt.Fprintln(os.Stderr, "DATABASE_URL not provided")
+		os.Exit(2)
+	}
+	km, closeKeys, err := keys.Open()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer closeKeys()
+	vault := core.NewCardVault(km)
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := prepareSchema(ctx, db); err != nil {
+		fmt.Fprintln(os.Stderr, "schema:", err)
+		os.Exit(1)
+	}
+
+	var migrated, reissue int
+	for {
+		n, r, err := migrateBatch(ctx, db, vault, *batch, *dropCVV, *dryRun)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		migrated += n
+		reissue += r
+		if n < *batch || *dryRun {
+			break
+		}
+	}
+	fmt.Printf("migrated %d cards, %d need cvv reissue\n", migrated, reissue)
+	if reissue > 0 && !*dropCVV {
+		fmt.Println(`cards needing reissue: SELECT id FROM cards WHERE cvv_digest IS NOT NULL`)
+	}
+}
+
+func prepareSchema(ctx context.Context, db *sql.DB) error {
+	stmts := []string{
+		"ALTER TABLE cards ADD COLUMN IF NOT EXISTS card_number_enc TEXT",
+		"ALTER TABLE cards ADD COLUMN IF NOT EXISTS card_number_hash TEXT",
+		"ALTER TABLE cards ADD COLUMN IF NOT EXISTS card_last4 TEXT",
+		"ALTER TABLE cards ADD COLUMN IF NOT EXISTS cvv_digest TEXT",
+		"CREATE UNIQUE INDEX IF NOT EXISTS cards_card_number_hash_key ON cards (card_number_hash)",
+		`ALTER TABLE cards ALTER COLUMN "cardNumber" DROP NOT NULL`,
+		`ALTER TABLE cards ALTER COLUMN "cvv" DROP NOT NULL`,
+	}
+	for _, s := range stmts {
+		if _, err := db.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateBatch(ctx context.Context, db *sql.DB, vault *core.CardVault, limit int, dropCVV, dryRun bool) (int, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// Cards migrated by an earlier run may still hold a plain CVV; they are
+	// picked up again and only have it replaced.
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, "cardNumber", card_number_enc, "cvv", "expiryMonth", "expiryYear" FROM cards
+		WHERE (card_number_enc IS NULL AND "cardNumber" IS NOT NULL) OR "cvv" IS NOT NULL
+		ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	var batch []cardRow
+	for rows.Next() {
+		var r cardRow
+		if err := rows.Scan(&r.id, &r.number, &r.enc, &r.cvv, &r.month, &r.year); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	reissue := 0
+	for _, r := range batch {
+		var pan string
+		var tok *core.TokenizedCard
+		if r.enc.Valid {
+			if pan, err = vault.Detokenize(r.enc.String); err != nil {
+				return 0, 0, fmt.Errorf("card %s: %w", r.id, err)
+			}
+		} else {
+			pan = r.number.String
+			if tok, err = vault.Tokenize(pan); err != nil {
+				return 0, 0, fmt.Errorf("card %s: %w", r.id, err)
+			}
+		}
+		var digest sql.NullString
+		if r.cvv.Valid && vault.VerifyCVV(pan, r.month, r.year, r.cvv.String) != nil && !dropCVV {
+			d, err := vault.LegacyCVVDigest(r.id, r.cvv.String)
+			if err != nil {
+				return 0, 0, fmt.Errorf("card %s: %w", r.id, err)
+			}
+			digest = sql.NullString{String: d, Valid: true}
+			reissue++
+		}
+		if dryRun {
+			continue
+		}
+		if tok != nil {
+			_, err = tx.ExecContext(ctx, `UPDATE cards SET card_number_enc=$1, card_number_hash=$2, card_last4=$3, "cardNumber"=NULL, "cvv"=NULL, cvv_digest=$4, "updatedAt"=$5 WHERE id=$6`,
+				tok.Ciphertext, tok.BlindIndex, tok.Last4, digest, time.Now(), r.id)
+		} else {
+			_, err = tx.ExecContext(ctx, `UPDATE cards SET "cvv"=NULL, cvv_digest=$1, "updatedAt"=$2 WHERE id=$3`, digest, time.Now(), r.id)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("card %s: %w", r.id, err)
+		}
+	}
+	if dryRun {
+		return len(batch), reissue, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return len(batch), reissue, nil
+}
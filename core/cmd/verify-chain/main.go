@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	_ "github.com/lib/pq"
+
+	core "payments-core"
+)
+
+func main() {
+	dsn := flag.String("db", os.Getenv("DATABASE_URL"), "")
+	var keys core.KeyManagerFlags
+	keys.Register(flag.CommandLine)
+	full := flag.Bool("full", false, "")
+	checkpoint := flag.Bool("checkpoint", false, "")
+	flag.Parse()
+
+	if *dsn == "" {
+		fmt.Fprintln(os.Stderr, "DATABASE_URL not provided")
+		os.Exit(2)
+	}
+	km, closeKeys, err := keys.Open()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer closeKeys()
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	var report *core.ChainReport
+	if *full {
+		report, err = core.VerifyChain(ctx, db, km)
+	} else {
+		report, err = core.VerifyChainSinceCheckpoint(ctx, db, km)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if report.Break != nil {
+		fmt.Printf("chain broken at seq %d (transaction %s): %s\n", report.Break.Seq, report.Break.TransactionID, report.Break.Reason)
+		os.Exit(1)
+	}
+	fmt.Printf("verified %d links after seq %d, head %s\n", report.Checked, report.FromSeq, report.Head)
+
+	if *checkpoint && report.Checked > 0 {
+		cp, err := core.CreateChainCheckpoint(ctx, db, km, report)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("checkpoint at seq %d\n", cp.Seq)
+	}
+}
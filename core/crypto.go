@@ -1,16 +1,15 @@
 package core
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
-	"time"
+	"sync"
 )
 
 type KeyManager interface {
@@ -18,16 +17,48 @@ type KeyManager interface {
 	GetSigningKey() ([]byte, error)
 }
 
+// RemoteCrypto is implemented by key managers that keep keys outside the
+// process and perform operations on the caller's behalf. Encrypt, Decrypt,
+// SignPayload and VerifySignature route through it when available.
+type RemoteCrypto interface {
+	RemoteEncrypt(plaintext []byte) (string, error)
+	RemoteDecrypt(ciphertext string) ([]byte, error)
+	RemoteSign(payload []byte) (string, error)
+	RemoteVerify(payload []byte, sig string) (bool, error)
+	RemoteDigest(data []byte) ([]byte, error)
+	GenerateDataKey() ([]byte, string, error)
+	UnwrapDataKey(wrapped string) ([]byte, error)
+}
+
 type InMemoryKeyManager struct {
-	encKey  []byte
-	signKey []byte
+	// keyMu guards encKey and signKey, which Destroy wipes.
+	keyMu     sync.RWMutex
+	encKey    []byte
+	signKey   []byte
+	asym      asymKeys
+	cache     primitiveCache
+	destroyed bool
 }
 
 func NewInMemoryKeyManager(encKey, signKey []byte) *InMemoryKeyManager {
 	return &InMemoryKeyManager{encKey: cloneBytes(encKey), signKey: cloneBytes(signKey)}
 }
 
+func NewInMemoryKeyManagerFromBase64(encKey, signKey string) (*InMemoryKeyManager, error) {
+	enc, err := base64.StdEncoding.DecodeString(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	sign, err := base64.StdEncoding.DecodeString(signKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	return NewInMemoryKeyManager(enc, sign), nil
+}
+
 func (m *InMemoryKeyManager) GetEncryptionKey() ([]byte, error) {
+	m.keyMu.RLock()
+	defer m.keyMu.RUnlock()
 	if len(m.encKey) != 32 {
 		return nil, errors.New("invalid encryption key")
 	}
@@ -35,6 +66,8 @@ func (m *InMemoryKeyManager) GetEncryptionKey() ([]byte, error) {
 }
 
 func (m *InMemoryKeyManager) GetSigningKey() ([]byte, error) {
+	m.keyMu.RLock()
+	defer m.keyMu.RUnlock()
 	if len(m.signKey) == 0 {
 		return nil, errors.New("invalid signing key")
 	}
@@ -54,42 +87,31 @@ func GenerateRandomKey(n int) ([]byte, error) {
 }
 
 func Encrypt(plaintext []byte, km KeyManager) (string, error) {
-	key, err := km.GetEncryptionKey()
-	if err != nil {
-		return "", err
+	if rc, ok := km.(RemoteCrypto); ok {
+		return rc.RemoteEncrypt(plaintext)
 	}
-	block, err := aes.NewCipher(key)
+	gcm, err := encryptionAEAD(km)
 	if err != nil {
 		return "", err
 	}
-	gcm, err := cipher.NewGCM(block)
+	out := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	_, err = io.ReadFull(rand.Reader, out)
 	if err != nil {
 		return "", err
 	}
-	nonce := make([]byte, gcm.NonceSize())
-	_, err = io.ReadFull(rand.Reader, nonce)
-	if err != nil {
-		return "", err
-	}
-	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
-	out := append(nonce, ciphertext...)
+	out = gcm.Seal(out, out, plaintext, nil)
 	return base64.StdEncoding.EncodeToString(out), nil
 }
 
 func Decrypt(enc string, km KeyManager) ([]byte, error) {
-	key, err := km.GetEncryptionKey()
-	if err != nil {
-		return nil, err
+	if rc, ok := km.(RemoteCrypto); ok {
+		return rc.RemoteDecrypt(enc)
 	}
 	raw, err := base64.StdEncoding.DecodeString(enc)
 	if err != nil {
 		return nil, err
 	}
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := encryptionAEAD(km)
 	if err != nil {
 		return nil, err
 	}
@@ -107,30 +129,34 @@ func Decrypt(enc string, km KeyManager) ([]byte, error) {
 }
 
 func SignPayload(payload []byte, km KeyManager) (string, error) {
-	key, err := km.GetSigningKey()
-	if err != nil {
-		return "", err
+	if rc, ok := km.(RemoteCrypto); ok {
+		return rc.RemoteSign(payload)
 	}
-	mac := hmac.New(sha256.New, key)
-	_, err = mac.Write(payload)
+	var sum []byte
+	err := withSigningMAC(km, func(mac hash.Hash) error {
+		mac.Write(payload)
+		sum = mac.Sum(nil)
+		return nil
+	})
 	if err != nil {
 		return "", err
 	}
-	sum := mac.Sum(nil)
 	return hex.EncodeToString(sum), nil
 }
 
 func VerifySignature(payload []byte, sigHex string, km KeyManager) (bool, error) {
-	key, err := km.GetSigningKey()
-	if err != nil {
-		return false, err
+	if rc, ok := km.(RemoteCrypto); ok {
+		return rc.RemoteVerify(payload, sigHex)
 	}
-	expected := hmac.New(sha256.New, key)
-	_, err = expected.Write(payload)
+	var expectedSum []byte
+	err := withSigningMAC(km, func(mac hash.Hash) error {
+		mac.Write(payload)
+		expectedSum = mac.Sum(nil)
+		return nil
+	})
 	if err != nil {
 		return false, err
 	}
-	expectedSum := expected.Sum(nil)
 	got, err := hex.DecodeString(sigHex)
 	if err != nil {
 		return false, err
@@ -142,30 +168,6 @@ func VerifySignature(payload []byte, sigHex string, km KeyManager) (bool, error)
 	return result, nil
 }
 
-func GenerateReference(km KeyManager) (string, error) {
-	u := make([]byte, 16)
-	_, err := io.ReadFull(rand.Reader, u)
-	if err != nil {
-		return "", err
-	}
-	u[6] = (u[6] & 0x0f) | 0x40
-	u[8] = (u[8] & 0x3f) | 0x80
-	timeBytes := []byte(time.Now().UTC().Format(time.RFC3339Nano))
-	data := append(u, timeBytes...)
-	key, err := km.GetSigningKey()
-	if err != nil {
-		return "", err
-	}
-	mac := hmac.New(sha256.New, key)
-	_, err = mac.Write(data)
-	if err != nil {
-		return "", err
-	}
-	sum := mac.Sum(nil)
-	ref := hex.EncodeToString(append(u, sum[:12]...))
-	return ref, nil
-}
-
 func cloneBytes(b []byte) []byte {
 	if b == nil {
 		return nil
@@ -0,0 +1,97 @@
+package core_test
+
+import (
+	"testing"
+
+	core "payments-core"
+)
+
+// uncached exposes only the KeyManager methods, so the core helpers fall
+// back to fetching a key copy and rebuilding the cipher on every call.
+type uncached struct {
+	km core.KeyManager
+}
+
+func (u uncached) GetEncryptionKey() ([]byte, error) { return u.km.GetEncryptionKey() }
+func (u uncached) GetSigningKey() ([]byte, error)    { return u.km.GetSigningKey() }
+
+func benchManagers(b *testing.B) []struct {
+	name string
+	km   core.KeyManager
+} {
+	enc, err := core.GenerateRandomKey(32)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sign, err := core.GenerateRandomKey(32)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cached := core.NewInMemoryKeyManager(enc, sign)
+	b.Cleanup(cached.Destroy)
+	return []struct {
+		name string
+		km   core.KeyManager
+	}{
+		{"uncached", uncached{cached}},
+		{"cached", cached},
+	}
+}
+
+var benchPlain = make([]byte, 256)
+
+func BenchmarkEncrypt(b *testing.B) {
+	for _, m := range benchManagers(b) {
+		b.Run(m.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := core.Encrypt(benchPlain, m.km); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	for _, m := range benchManagers(b) {
+		ct, err := core.Encrypt(benchPlain, m.km)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(m.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := core.Decrypt(ct, m.km); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSignPayload(b *testing.B) {
+	for _, m := range benchManagers(b) {
+		b.Run(m.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := core.SignPayload(benchPlain, m.km); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSignPayloadParallel(b *testing.B) {
+	for _, m := range benchManagers(b) {
+		b.Run(m.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					core.SignPayload(benchPlain, m.km)
+				}
+			})
+		})
+	}
+}
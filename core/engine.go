@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 )
 
@@ -24,74 +25,138 @@ type Transaction struct {
 	FromAccount string
 	ToAccount   string
 	CreatedAt   time.Time
+	// Risk is set when a RiskEvaluator ran for this transfer.
+	Risk *RiskResult
 }
 
+var (
+	ErrFromAccountNotFound = errors.New("from account not found")
+	ErrToAccountNotFound   = errors.New("to account not found")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrInvalidAmount       = errors.New("invalid amount")
+	ErrSameAccount         = errors.New("cannot transfer to the same account")
+	ErrTransferNotFound    = errors.New("transfer not found")
+)
+
+const (
+	StatusCompleted     = "COMPLETED"
+	StatusPendingReview = "PENDING_REVIEW"
+	StatusRejected      = "REJECTED"
+)
+
 type KafkaService struct{}
 
 func (k *KafkaService) PublishMessage(topic string, payload map[string]interface{}) error {
 	return nil
 }
 
-func Transfer(ctx context.Context, db *sql.DB, kafka *KafkaService, km KeyManager, fromAccountId, toAccountId string, amount int64, description string) (*Transaction, error) {
+// Ping reports whether the publisher can reach its brokers. The current
+// publisher drops messages locally, so it is always reachable.
+func (k *KafkaService) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Transfer moves amount between two accounts. With a non-nil risk evaluator,
+// a review decision records the transfer as PENDING_REVIEW and holds the
+// amount on the sender instead of moving it; see ApproveTransfer. A repeated
+// non-empty idempotencyKey returns the transfer first made with it.
+// initiator is the user asking for the transfer, who may not review it.
+func Transfer(ctx context.Context, db *sql.DB, kafka *KafkaService, km KeyManager, risk RiskEvaluator, fromAccountId, toAccountId string, amount int64, description, idempotencyKey, initiator string) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if fromAccountId == toAccountId {
+		return nil, ErrSameAccount
+	}
+
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	if idempotencyKey != "" {
+		existing, err := claimIdempotencyKey(ctx, tx, idempotencyKey, transferRequestHash(fromAccountId, toAccountId, amount, description))
+		if err != nil {
+			return nil, err
+		}
+		if existing != "" {
+			return getTransaction(ctx, tx, existing, "")
+		}
+	}
+
 	var fromAcc, toAcc Account
 	err = tx.QueryRowContext(ctx, "SELECT id, account_number, balance FROM accounts WHERE id=$1 FOR UPDATE", fromAccountId).Scan(&fromAcc.ID, &fromAcc.AccountNumber, &fromAcc.Balance)
 	if err != nil {
-		return nil, errors.New("from account not found")
+		return nil, ErrFromAccountNotFound
 	}
 	err = tx.QueryRowContext(ctx, "SELECT id, account_number, balance FROM accounts WHERE id=$1 FOR UPDATE", toAccountId).Scan(&toAcc.ID, &toAcc.AccountNumber, &toAcc.Balance)
 	if err != nil {
-		return nil, errors.New("to account not found")
+		return nil, ErrToAccountNotFound
+	}
+	held, err := heldAmount(ctx, tx, fromAccountId)
+	if err != nil {
+		return nil, err
+	}
+	if fromAcc.Balance-held < amount {
+		return nil, ErrInsufficientBalance
 	}
-	if fromAcc.Balance < amount {
-		return nil, errors.New("insufficient balance")
+
+	status := StatusCompleted
+	var result *RiskResult
+	if risk != nil {
+		in, err := buildRiskInput(ctx, tx, fromAcc, toAcc, amount, fromAcc.Balance-held)
+		if err != nil {
+			return nil, err
+		}
+		res, err := risk.Evaluate(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		switch res.Decision {
+		case RiskDeny:
+			return nil, &RiskDeniedError{Reasons: res.Reasons}
+		case RiskReview:
+			status = StatusPendingReview
+		}
+		result = &res
 	}
 
-	newFromBal := fromAcc.Balance - amount
-	newToBal := toAcc.Balance + amount
+	if status == StatusCompleted {
+		newFromBal := fromAcc.Balance - amount
+		newToBal := toAcc.Balance + amount
 
-	errCh := make(chan error, 2)
+		errCh := make(chan error, 2)
 
-	go func() {
-		_, e := tx.ExecContext(ctx, "UPDATE accounts SET balance=$1 WHERE id=$2", newFromBal, fromAccountId)
-		errCh <- e
-	}()
-	go func() {
-		_, e := tx.ExecContext(ctx, "UPDATE accounts SET balance=$1 WHERE id=$2", newToBal, toAccountId)
-		errCh <- e
-	}()
+		go func() {
+			_, e := tx.ExecContext(ctx, "UPDATE accounts SET balance=$1 WHERE id=$2", newFromBal, fromAccountId)
+			errCh <- e
+		}()
+		go func() {
+			_, e := tx.ExecContext(ctx, "UPDATE accounts SET balance=$1 WHERE id=$2", newToBal, toAccountId)
+			errCh <- e
+		}()
 
-	for i := 0; i < 2; i++ {
-		if e := <-errCh; e != nil {
-			return nil, e
+		for i := 0; i < 2; i++ {
+			if e := <-errCh; e != nil {
+				return nil, e
+			}
 		}
 	}
 
-	ref, err := GenerateReference(km)
-	if err != nil {
-		return nil, err
-	}
 	if description == "" {
 		description = "Account transfer"
 	}
 
-	var transactionID string
-	err = tx.QueryRowContext(ctx, `
-		INSERT INTO transactions (type, amount, description, status, reference, from_account, to_account, created_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id`,
-		"TRANSFER", amount, description, "COMPLETED", ref, fromAcc.AccountNumber, toAcc.AccountNumber, time.Now(),
-	).Scan(&transactionID)
+	createdAt := time.Now().UTC().Truncate(time.Millisecond)
+	transactionID, ref, err := insertTransferRecord(ctx, tx, km, amount, description, status, fromAcc.AccountNumber, toAcc.AccountNumber, createdAt)
 	if err != nil {
 		return nil, err
 	}
-
-	if err := tx.Commit(); err != nil {
-		return nil, err
+	if idempotencyKey != "" {
+		if err := recordIdempotencyKey(ctx, tx, idempotencyKey, transactionID); err != nil {
+			return nil, err
+		}
 	}
 
 	t := &Transaction{
@@ -99,29 +164,112 @@ func Transfer(ctx context.Context, db *sql.DB, kafka *KafkaService, km KeyManage
 		Type:        "TRANSFER",
 		Amount:      amount,
 		Description: description,
-		Status:      "COMPLETED",
+		Status:      status,
 		Reference:   ref,
 		FromAccount: fromAcc.AccountNumber,
 		ToAccount:   toAcc.AccountNumber,
-		CreatedAt:   time.Now(),
+		CreatedAt:   createdAt,
+		Risk:        result,
+	}
+
+	if status == StatusPendingReview {
+		if err := openReview(ctx, tx, t, fromAccountId, toAccountId, initiator); err != nil {
+			return nil, err
+		}
 	}
 
+	if err := AppendChainLink(ctx, tx, km, t); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if status == StatusCompleted {
+		publishTransferCompleted(kafka, km, t, fromAccountId, toAccountId)
+	}
+
+	return t, nil
+}
+
+func publishTransferCompleted(kafka *KafkaService, km KeyManager, t *Transaction, fromAccountId, toAccountId string) {
 	kafkaPayload := map[string]interface{}{
-		"transactionId": transactionID,
+		"transactionId": t.ID,
 		"fromAccountId": fromAccountId,
 		"toAccountId":   toAccountId,
-		"amount":        amount,
-		"reference":     ref,
+		"amount":        t.Amount,
+		"reference":     t.Reference,
 		"timestamp":     time.Now().UTC().Format(time.RFC3339Nano),
 	}
 
-	payloadBytes := []byte(fmt.Sprintf("%s|%s|%s|%d|%s", transactionID, fromAccountId, toAccountId, amount, ref))
-	sig, err := SignPayload(payloadBytes, km)
-	if err == nil {
-		kafkaPayload["sig"] = sig
+	payloadBytes := []byte(fmt.Sprintf("%s|%s|%s|%d|%s", t.ID, fromAccountId, toAccountId, t.Amount, t.Reference))
+	sig, err := SignEvent(payloadBytes, km)
+	if err != nil {
+		// Consumers drop unsigned events, so publishing one would only hide
+		// the misconfiguration.
+		slog.Default().Error("transfer event not signed", slog.String("transaction_id", t.ID), slog.Any("error", err))
+		return
 	}
+	kafkaPayload["sig"] = sig.Sig
+	kafkaPayload["alg"] = sig.Alg
+	kafkaPayload["kid"] = sig.Kid
 
 	go kafka.PublishMessage("transfer.completed", kafkaPayload)
+}
+
+func GetTransaction(ctx context.Context, db *sql.DB, id string) (*Transaction, error) {
+	return getTransaction(ctx, db, id, "")
+}
 
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getTransaction(ctx context.Context, q rowQuerier, id, suffix string) (*Transaction, error) {
+	t := &Transaction{}
+	var amount string
+	err := q.QueryRowContext(ctx, `
+		SELECT id, type, amount::text, description, status, reference,
+			COALESCE(from_account, ''), COALESCE(to_account, ''), created_at
+		FROM transactions WHERE id=$1 `+suffix, id,
+	).Scan(&t.ID, &t.Type, &amount, &t.Description, &t.Status, &t.Reference, &t.FromAccount, &t.ToAccount, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTransferNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Amount, err = parseMinorUnits(amount); err != nil {
+		return nil, err
+	}
 	return t, nil
 }
+
+const maxReferenceAttempts = 5
+
+// insertTransferRecord relies on the unique index on reference: a colliding
+// insert is skipped by ON CONFLICT and retried with a fresh reference.
+func insertTransferRecord(ctx context.Context, tx *sql.Tx, km KeyManager, amount int64, description, status, fromNumber, toNumber string, createdAt time.Time) (string, string, error) {
+	for attempt := 0; attempt < maxReferenceAttempts; attempt++ {
+		ref, err := GenerateReference(km)
+		if err != nil {
+			return "", "", err
+		}
+		var transactionID string
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO transactions (type, amount, description, status, reference, from_account, to_account, created_at)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+			ON CONFLICT (reference) DO NOTHING RETURNING id`,
+			"TRANSFER", amount, description, status, ref, fromNumber, toNumber, createdAt,
+		).Scan(&transactionID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return transactionID, ref, nil
+	}
+	return "", "", errors.New("could not allocate unique reference")
+}
@@ -0,0 +1,153 @@
+package core_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB is a database/sql driver that answers queries with canned rows,
+// picked by a fragment of the query text, and records every query and Exec. It stands
+// in for Postgres in tests of code whose logic sits around its queries.
+type fakeDB struct {
+	mu      sync.Mutex
+	results map[string]fakeResult
+	queries []string
+	execs   []fakeExec
+}
+
+type fakeResult struct {
+	cols []string
+	rows [][]driver.Value
+	err  error
+}
+
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+func newFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	t.Helper()
+	f := &fakeDB{results: map[string]fakeResult{}}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return db, f
+}
+
+// on answers every query containing fragment with rows. A result with no
+// rows makes QueryRow return sql.ErrNoRows.
+func (f *fakeDB) on(fragment string, cols []string, rows ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[fragment] = fakeResult{cols: cols, rows: rows}
+}
+
+func (f *fakeDB) fail(fragment string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[fragment] = fakeResult{err: err}
+}
+
+// execsMatching returns the recorded Execs whose query contains fragment.
+func (f *fakeDB) execsMatching(fragment string) []fakeExec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeExec
+	for _, e := range f.execs {
+		if strings.Contains(e.query, fragment) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// queriesMatching counts the recorded queries that contain fragment.
+func (f *fakeDB) queriesMatching(fragment string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, q := range f.queries {
+		if strings.Contains(q, fragment) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakedb: open through sql.OpenDB")
+}
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.queries = append(c.f.queries, query)
+	for fragment, res := range c.f.results {
+		if !strings.Contains(query, fragment) {
+			continue
+		}
+		if res.err != nil {
+			return nil, res.err
+		}
+		return &fakeRows{cols: res.cols, rows: res.rows}, nil
+	}
+	return nil, fmt.Errorf("fakedb: unexpected query %q", query)
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	for fragment, res := range c.f.results {
+		if res.err != nil && strings.Contains(query, fragment) {
+			return nil, res.err
+		}
+	}
+	e := fakeExec{query: query}
+	for _, a := range args {
+		e.args = append(e.args, a.Value)
+	}
+	c.f.execs = append(c.f.execs, e)
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	MaxWatchedAccounts      = 100
+	MaxReplayWindow         = 24 * time.Hour
+	DefaultFeedPollInterval = time.Second
+	feedBatchSize           = 256
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// FeedEvent is a transaction as seen at one link of the transaction chain:
+// the status is the one the link was written for, so a held transfer that
+// was later approved replays as held and then completed. Links written
+// before statuses were recorded on the chain carry the current status.
+// The chain sequence is assigned under the chain lock and committed with the
+// transfer, so it only grows in commit order and doubles as the resume cursor.
+type FeedEvent struct {
+	Seq int64
+	Transaction
+}
+
+func FormatCursor(seq int64) string {
+	return strconv.FormatInt(seq, 10)
+}
+
+func ParseCursor(s string) (int64, error) {
+	seq, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return seq, nil
+}
+
+// FeedNotifier wakes watchers as soon as this process commits a transfer.
+// Transfers committed by other instances are picked up by a FeedPoller.
+type FeedNotifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func NewFeedNotifier() *FeedNotifier {
+	return &FeedNotifier{ch: make(chan struct{})}
+}
+
+func (n *FeedNotifier) Wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ch
+}
+
+func (n *FeedNotifier) Broadcast() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
+
+// FeedPoller watches the chain head on behalf of every watcher of one
+// server and wakes them through the notifier when it moves, so the database
+// sees one head query per interval however many streams are open. It only
+// polls while at least one watcher is subscribed.
+type FeedPoller struct {
+	db       *sql.DB
+	notifier *FeedNotifier
+
+	mu       sync.Mutex
+	watchers int
+	cancel   context.CancelFunc
+	head     int64
+}
+
+func NewFeedPoller(db *sql.DB, notifier *FeedNotifier) *FeedPoller {
+	return &FeedPoller{db: db, notifier: notifier}
+}
+
+// Subscribe registers a watcher, starting the poll loop for the first one.
+// The returned func unregisters it and stops the loop after the last one.
+func (p *FeedPoller) Subscribe(interval time.Duration) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.watchers++
+	if p.watchers == 1 {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel = cancel
+		go p.run(ctx, interval)
+	}
+	return sync.OnceFunc(func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.watchers--
+		if p.watchers == 0 {
+			p.cancel()
+		}
+	})
+}
+
+// Head returns the last chain head the poller read. Every link up to it had
+// committed by then, so watchers can skip past it.
+func (p *FeedPoller) Head() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.head
+}
+
+func (p *FeedPoller) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := int64(-1)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		head, err := FeedStart(ctx, p.db, time.Time{})
+		if err != nil {
+			// Watchers keep their cursors; the next tick tries again.
+			continue
+		}
+		p.mu.Lock()
+		p.head = max(p.head, head)
+		p.mu.Unlock()
+		if head != last {
+			last = head
+			p.notifier.Broadcast()
+		}
+	}
+}
+
+// AccountNumbers resolves account IDs to the account numbers stored on
+// transactions. Unknown IDs are ignored.
+func AccountNumbers(ctx context.Context, db *sql.DB, ids []string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT account_number FROM accounts WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var numbers []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, rows.Err()
+}
+
+// FeedStart returns the cursor just before the first link created at or
+// after since, or the current head when since is zero.
+func FeedStart(ctx context.Context, db *sql.DB, since time.Time) (int64, error) {
+	var seq int64
+	var err error
+	if since.IsZero() {
+		err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(seq), 0) FROM transaction_chain").Scan(&seq)
+	} else {
+		err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(seq), 0) FROM transaction_chain WHERE created_at < $1", since.UTC()).Scan(&seq)
+	}
+	return seq, err
+}
+
+func FetchFeedEvents(ctx context.Context, db *sql.DB, accountNumbers []string, after int64, limit int) ([]FeedEvent, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.seq, t.id, t.type, t.amount::text, t.description, COALESCE(c.status, t.status), t.reference,
+			COALESCE(t.from_account, ''), COALESCE(t.to_account, ''), t.created_at
+		FROM transaction_chain c
+		JOIN transactions t ON t.id = c.transaction_id
+		WHERE c.seq > $1 AND (t.from_account = ANY($2) OR t.to_account = ANY($2))
+		ORDER BY c.seq
+		LIMIT $3`, after, pq.Array(accountNumbers), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []FeedEvent
+	for rows.Next() {
+		var e FeedEvent
+		var amount string
+		if err := rows.Scan(&e.Seq, &e.ID, &e.Type, &amount, &e.Description, &e.Status, &e.Reference,
+			&e.FromAccount, &e.ToAccount, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.Amount, err = parseMinorUnits(amount); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
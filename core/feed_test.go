@@ -0,0 +1,79 @@
+package core_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	core "payments-core"
+)
+
+const headQuery = "SELECT COALESCE(MAX(seq), 0) FROM transaction_chain"
+
+func waitWake(t *testing.T, wake <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-wake:
+	case <-time.After(time.Second):
+		t.Fatal("watchers were not woken")
+	}
+}
+
+func TestFeedPollerWakesWatchersWhenHeadMoves(t *testing.T) {
+	db, f := newFakeDB(t)
+	f.on(headQuery, []string{"max"}, []driver.Value{int64(5)})
+	n := core.NewFeedNotifier()
+	p := core.NewFeedPoller(db, n)
+
+	wake := n.Wait()
+	first := p.Subscribe(time.Millisecond)
+	second := p.Subscribe(time.Millisecond)
+	waitWake(t, wake)
+	if got := p.Head(); got != 5 {
+		t.Fatalf("head %d, want 5", got)
+	}
+
+	wake = n.Wait()
+	f.on(headQuery, []string{"max"}, []driver.Value{int64(7)})
+	waitWake(t, wake)
+	if got := p.Head(); got != 7 {
+		t.Fatalf("head %d, want 7", got)
+	}
+
+	first()
+	first()
+	before := f.queriesMatching(headQuery)
+	time.Sleep(20 * time.Millisecond)
+	if f.queriesMatching(headQuery) == before {
+		t.Fatal("poller stopped while a watcher was still subscribed")
+	}
+	second()
+	time.Sleep(5 * time.Millisecond)
+	before = f.queriesMatching(headQuery)
+	time.Sleep(20 * time.Millisecond)
+	if after := f.queriesMatching(headQuery); after != before {
+		t.Fatalf("poller ran %d more queries after the last watcher left", after-before)
+	}
+}
+
+func TestFetchFeedEventsReadsLinkStatus(t *testing.T) {
+	db, f := newFakeDB(t)
+	at := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	// Only a query that prefers the link's status is answered.
+	f.on("COALESCE(c.status, t.status)",
+		[]string{"seq", "id", "type", "amount", "description", "status", "reference", "from_account", "to_account", "created_at"},
+		[]driver.Value{int64(1), "t1", "TRANSFER", "90000.00", "", core.StatusPendingReview, "r1", "A", "B", at},
+		[]driver.Value{int64(2), "t1", "TRANSFER", "90000.00", "", core.StatusCompleted, "r1", "A", "B", at},
+	)
+	events, err := core.FetchFeedEvents(context.Background(), db, []string{"A"}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Status != core.StatusPendingReview || events[1].Status != core.StatusCompleted {
+		t.Fatalf("got %+v, want the held link and then the approved one", events)
+	}
+	if events[0].Amount != 90000 {
+		t.Fatalf("amount %d, want 90000", events[0].Amount)
+	}
+}
@@ -27,6 +27,12 @@ type TransferRequest struct {
 	ToAccountId   string                 `protobuf:"bytes,2,opt,name=to_account_id,json=toAccountId,proto3" json:"to_account_id,omitempty"`
 	Amount        int64                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
 	Description   string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	// Optional. A retry carrying the same key returns the transfer the first
+	// call made instead of moving money again.
+	IdempotencyKey string `protobuf:"bytes,5,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	// The user who asked for the transfer, as authenticated by the calling
+	// service. A transfer held for review cannot be decided by this user.
+	InitiatedBy   string `protobuf:"bytes,6,opt,name=initiated_by,json=initiatedBy,proto3" json:"initiated_by,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -89,6 +95,20 @@ func (x *TransferRequest) GetDescription() string {
 	return ""
 }
 
+func (x *TransferRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *TransferRequest) GetInitiatedBy() string {
+	if x != nil {
+		return x.InitiatedBy
+	}
+	return ""
+}
+
 type TransferResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	TransactionId string                 `protobuf:"bytes,1,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
@@ -96,6 +116,11 @@ type TransferResponse struct {
 	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
 	Amount        int64                  `protobuf:"varint,4,opt,name=amount,proto3" json:"amount,omitempty"`
 	CreatedAt     string                 `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Description   string                 `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
+	FromAccount   string                 `protobuf:"bytes,7,opt,name=from_account,json=fromAccount,proto3" json:"from_account,omitempty"`
+	ToAccount     string                 `protobuf:"bytes,8,opt,name=to_account,json=toAccount,proto3" json:"to_account,omitempty"`
+	// Rules that sent the transfer to review.
+	RiskReasons   []string `protobuf:"bytes,9,rep,name=risk_reasons,json=riskReasons,proto3" json:"risk_reasons,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -165,25 +190,889 @@ func (x *TransferResponse) GetCreatedAt() string {
 	return ""
 }
 
-var File_proto_txn_proto protoreflect.FileDescriptor
+func (x *TransferResponse) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
 
-const file_proto_txn_proto_rawDesc = "" +
-	"\n" +
-	"\x0fproto/txn.proto\x12\btransfer\"\x97\x01\n" +
-	"\x0fTransferRequest\x12&\n" +
-	"\x0ffrom_account_id\x18\x01 \x01(\tR\rfromAccountId\x12\"\n" +
-	"\rto_account_id\x18\x02 \x01(\tR\vtoAccountId\x12\x16\n" +
-	"\x06amount\x18\x03 \x01(\x03R\x06amount\x12 \n" +
-	"\vdescription\x18\x04 \x01(\tR\vdescription\"\xa6\x01\n" +
-	"\x10TransferResponse\x12%\n" +
-	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x12\x1c\n" +
-	"\treference\x18\x02 \x01(\tR\treference\x12\x16\n" +
-	"\x06status\x18\x03 \x01(\tR\x06status\x12\x16\n" +
-	"\x06amount\x18\x04 \x01(\x03R\x06amount\x12\x1d\n" +
-	"\n" +
-	"created_at\x18\x05 \x01(\tR\tcreatedAt2T\n" +
-	"\x0fTransferService\x12A\n" +
-	"\bTransfer\x12\x19.transfer.TransferRequest\x1a\x1a.transfer.TransferResponseB\x18Z\x16payments-core/proto;pbb\x06proto3"
+func (x *TransferResponse) GetFromAccount() string {
+	if x != nil {
+		return x.FromAccount
+	}
+	return ""
+}
+
+func (x *TransferResponse) GetToAccount() string {
+	if x != nil {
+		return x.ToAccount
+	}
+	return ""
+}
+
+func (x *TransferResponse) GetRiskReasons() []string {
+	if x != nil {
+		return x.RiskReasons
+	}
+	return nil
+}
+
+type GetTransferRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransactionId string                 `protobuf:"bytes,1,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransferRequest) Reset() {
+	*x = GetTransferRequest{}
+	mi := &file_proto_txn_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransferRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransferRequest) ProtoMessage() {}
+
+func (x *GetTransferRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransferRequest.ProtoReflect.Descriptor instead.
+func (*GetTransferRequest) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetTransferRequest) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+type ReviewTransferRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransactionId string                 `protobuf:"bytes,1,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	Note          string                 `protobuf:"bytes,2,opt,name=note,proto3" json:"note,omitempty"`
+	// Required. The user deciding, as authenticated by the calling service.
+	Reviewer      string `protobuf:"bytes,3,opt,name=reviewer,proto3" json:"reviewer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReviewTransferRequest) Reset() {
+	*x = ReviewTransferRequest{}
+	mi := &file_proto_txn_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReviewTransferRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReviewTransferRequest) ProtoMessage() {}
+
+func (x *ReviewTransferRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReviewTransferRequest.ProtoReflect.Descriptor instead.
+func (*ReviewTransferRequest) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ReviewTransferRequest) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *ReviewTransferRequest) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+func (x *ReviewTransferRequest) GetReviewer() string {
+	if x != nil {
+		return x.Reviewer
+	}
+	return ""
+}
+
+type WatchTransactionsRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	AccountIds []string               `protobuf:"bytes,1,rep,name=account_ids,json=accountIds,proto3" json:"account_ids,omitempty"`
+	// Resume after this cursor, as returned on a previously received event.
+	Cursor string `protobuf:"bytes,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// Without a cursor, first replay transactions from the last N seconds.
+	ReplaySeconds int32 `protobuf:"varint,3,opt,name=replay_seconds,json=replaySeconds,proto3" json:"replay_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchTransactionsRequest) Reset() {
+	*x = WatchTransactionsRequest{}
+	mi := &file_proto_txn_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchTransactionsRequest) ProtoMessage() {}
+
+func (x *WatchTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*WatchTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *WatchTransactionsRequest) GetAccountIds() []string {
+	if x != nil {
+		return x.AccountIds
+	}
+	return nil
+}
+
+func (x *WatchTransactionsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *WatchTransactionsRequest) GetReplaySeconds() int32 {
+	if x != nil {
+		return x.ReplaySeconds
+	}
+	return 0
+}
+
+type TransactionEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cursor        string                 `protobuf:"bytes,1,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	TransactionId string                 `protobuf:"bytes,2,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	Reference     string                 `protobuf:"bytes,3,opt,name=reference,proto3" json:"reference,omitempty"`
+	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Status        string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	Amount        int64                  `protobuf:"varint,6,opt,name=amount,proto3" json:"amount,omitempty"`
+	Description   string                 `protobuf:"bytes,7,opt,name=description,proto3" json:"description,omitempty"`
+	FromAccount   string                 `protobuf:"bytes,8,opt,name=from_account,json=fromAccount,proto3" json:"from_account,omitempty"`
+	ToAccount     string                 `protobuf:"bytes,9,opt,name=to_account,json=toAccount,proto3" json:"to_account,omitempty"`
+	CreatedAt     string                 `protobuf:"bytes,10,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionEvent) Reset() {
+	*x = TransactionEvent{}
+	mi := &file_proto_txn_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionEvent) ProtoMessage() {}
+
+func (x *TransactionEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionEvent.ProtoReflect.Descriptor instead.
+func (*TransactionEvent) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TransactionEvent) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *TransactionEvent) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *TransactionEvent) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+func (x *TransactionEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *TransactionEvent) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *TransactionEvent) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *TransactionEvent) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *TransactionEvent) GetFromAccount() string {
+	if x != nil {
+		return x.FromAccount
+	}
+	return ""
+}
+
+func (x *TransactionEvent) GetToAccount() string {
+	if x != nil {
+		return x.ToAccount
+	}
+	return ""
+}
+
+func (x *TransactionEvent) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+type GetBalanceRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	AccountId string                 `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	// Allow serving from a replica no further behind the primary than this.
+	// Zero always reads the primary.
+	MaxStalenessMs int32 `protobuf:"varint,2,opt,name=max_staleness_ms,json=maxStalenessMs,proto3" json:"max_staleness_ms,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetBalanceRequest) Reset() {
+	*x = GetBalanceRequest{}
+	mi := &file_proto_txn_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBalanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBalanceRequest) ProtoMessage() {}
+
+func (x *GetBalanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBalanceRequest.ProtoReflect.Descriptor instead.
+func (*GetBalanceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetBalanceRequest) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *GetBalanceRequest) GetMaxStalenessMs() int32 {
+	if x != nil {
+		return x.MaxStalenessMs
+	}
+	return 0
+}
+
+type GetBalancesRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	AccountIds     []string               `protobuf:"bytes,1,rep,name=account_ids,json=accountIds,proto3" json:"account_ids,omitempty"`
+	MaxStalenessMs int32                  `protobuf:"varint,2,opt,name=max_staleness_ms,json=maxStalenessMs,proto3" json:"max_staleness_ms,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetBalancesRequest) Reset() {
+	*x = GetBalancesRequest{}
+	mi := &file_proto_txn_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBalancesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBalancesRequest) ProtoMessage() {}
+
+func (x *GetBalancesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBalancesRequest.ProtoReflect.Descriptor instead.
+func (*GetBalancesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetBalancesRequest) GetAccountIds() []string {
+	if x != nil {
+		return x.AccountIds
+	}
+	return nil
+}
+
+func (x *GetBalancesRequest) GetMaxStalenessMs() int32 {
+	if x != nil {
+		return x.MaxStalenessMs
+	}
+	return 0
+}
+
+type Balance struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	AccountId        string                 `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	AccountNumber    string                 `protobuf:"bytes,2,opt,name=account_number,json=accountNumber,proto3" json:"account_number,omitempty"`
+	LedgerBalance    int64                  `protobuf:"varint,3,opt,name=ledger_balance,json=ledgerBalance,proto3" json:"ledger_balance,omitempty"`
+	AvailableBalance int64                  `protobuf:"varint,4,opt,name=available_balance,json=availableBalance,proto3" json:"available_balance,omitempty"`
+	HeldAmount       int64                  `protobuf:"varint,5,opt,name=held_amount,json=heldAmount,proto3" json:"held_amount,omitempty"`
+	Currency         string                 `protobuf:"bytes,6,opt,name=currency,proto3" json:"currency,omitempty"`
+	// Last transaction reflected in the balances, empty if there is none.
+	AsOfTransactionId string `protobuf:"bytes,7,opt,name=as_of_transaction_id,json=asOfTransactionId,proto3" json:"as_of_transaction_id,omitempty"`
+	// Feed cursor of that transaction, usable with WatchTransactions.
+	AsOfCursor    string `protobuf:"bytes,8,opt,name=as_of_cursor,json=asOfCursor,proto3" json:"as_of_cursor,omitempty"`
+	FromReplica   bool   `protobuf:"varint,9,opt,name=from_replica,json=fromReplica,proto3" json:"from_replica,omitempty"`
+	StalenessMs   int64  `protobuf:"varint,10,opt,name=staleness_ms,json=stalenessMs,proto3" json:"staleness_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Balance) Reset() {
+	*x = Balance{}
+	mi := &file_proto_txn_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Balance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Balance) ProtoMessage() {}
+
+func (x *Balance) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Balance.ProtoReflect.Descriptor instead.
+func (*Balance) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Balance) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *Balance) GetAccountNumber() string {
+	if x != nil {
+		return x.AccountNumber
+	}
+	return ""
+}
+
+func (x *Balance) GetLedgerBalance() int64 {
+	if x != nil {
+		return x.LedgerBalance
+	}
+	return 0
+}
+
+func (x *Balance) GetAvailableBalance() int64 {
+	if x != nil {
+		return x.AvailableBalance
+	}
+	return 0
+}
+
+func (x *Balance) GetHeldAmount() int64 {
+	if x != nil {
+		return x.HeldAmount
+	}
+	return 0
+}
+
+func (x *Balance) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Balance) GetAsOfTransactionId() string {
+	if x != nil {
+		return x.AsOfTransactionId
+	}
+	return ""
+}
+
+func (x *Balance) GetAsOfCursor() string {
+	if x != nil {
+		return x.AsOfCursor
+	}
+	return ""
+}
+
+func (x *Balance) GetFromReplica() bool {
+	if x != nil {
+		return x.FromReplica
+	}
+	return false
+}
+
+func (x *Balance) GetStalenessMs() int64 {
+	if x != nil {
+		return x.StalenessMs
+	}
+	return 0
+}
+
+type GetBalancesResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Balances          []*Balance             `protobuf:"bytes,1,rep,name=balances,proto3" json:"balances,omitempty"`
+	MissingAccountIds []string               `protobuf:"bytes,2,rep,name=missing_account_ids,json=missingAccountIds,proto3" json:"missing_account_ids,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *GetBalancesResponse) Reset() {
+	*x = GetBalancesResponse{}
+	mi := &file_proto_txn_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBalancesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBalancesResponse) ProtoMessage() {}
+
+func (x *GetBalancesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBalancesResponse.ProtoReflect.Descriptor instead.
+func (*GetBalancesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetBalancesResponse) GetBalances() []*Balance {
+	if x != nil {
+		return x.Balances
+	}
+	return nil
+}
+
+func (x *GetBalancesResponse) GetMissingAccountIds() []string {
+	if x != nil {
+		return x.MissingAccountIds
+	}
+	return nil
+}
+
+type IssueCardRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExpiryMonth   int32                  `protobuf:"varint,1,opt,name=expiry_month,json=expiryMonth,proto3" json:"expiry_month,omitempty"`
+	ExpiryYear    int32                  `protobuf:"varint,2,opt,name=expiry_year,json=expiryYear,proto3" json:"expiry_year,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IssueCardRequest) Reset() {
+	*x = IssueCardRequest{}
+	mi := &file_proto_txn_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IssueCardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IssueCardRequest) ProtoMessage() {}
+
+func (x *IssueCardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IssueCardRequest.ProtoReflect.Descriptor instead.
+func (*IssueCardRequest) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *IssueCardRequest) GetExpiryMonth() int32 {
+	if x != nil {
+		return x.ExpiryMonth
+	}
+	return 0
+}
+
+func (x *IssueCardRequest) GetExpiryYear() int32 {
+	if x != nil {
+		return x.ExpiryYear
+	}
+	return 0
+}
+
+// IssuedCard is what a card service stores for a new card. The plain card
+// number never leaves core; the CVV is for the cardholder only and must not
+// be stored.
+type IssuedCard struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	CardNumberEnc  string                 `protobuf:"bytes,1,opt,name=card_number_enc,json=cardNumberEnc,proto3" json:"card_number_enc,omitempty"`
+	CardNumberHash string                 `protobuf:"bytes,2,opt,name=card_number_hash,json=cardNumberHash,proto3" json:"card_number_hash,omitempty"`
+	CardLast4      string                 `protobuf:"bytes,3,opt,name=card_last4,json=cardLast4,proto3" json:"card_last4,omitempty"`
+	Masked         string                 `protobuf:"bytes,4,opt,name=masked,proto3" json:"masked,omitempty"`
+	Cvv            string                 `protobuf:"bytes,5,opt,name=cvv,proto3" json:"cvv,omitempty"`
+	ExpiryMonth    int32                  `protobuf:"varint,6,opt,name=expiry_month,json=expiryMonth,proto3" json:"expiry_month,omitempty"`
+	ExpiryYear     int32                  `protobuf:"varint,7,opt,name=expiry_year,json=expiryYear,proto3" json:"expiry_year,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *IssuedCard) Reset() {
+	*x = IssuedCard{}
+	mi := &file_proto_txn_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IssuedCard) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IssuedCard) ProtoMessage() {}
+
+func (x *IssuedCard) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IssuedCard.ProtoReflect.Descriptor instead.
+func (*IssuedCard) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *IssuedCard) GetCardNumberEnc() string {
+	if x != nil {
+		return x.CardNumberEnc
+	}
+	return ""
+}
+
+func (x *IssuedCard) GetCardNumberHash() string {
+	if x != nil {
+		return x.CardNumberHash
+	}
+	return ""
+}
+
+func (x *IssuedCard) GetCardLast4() string {
+	if x != nil {
+		return x.CardLast4
+	}
+	return ""
+}
+
+func (x *IssuedCard) GetMasked() string {
+	if x != nil {
+		return x.Masked
+	}
+	return ""
+}
+
+func (x *IssuedCard) GetCvv() string {
+	if x != nil {
+		return x.Cvv
+	}
+	return ""
+}
+
+func (x *IssuedCard) GetExpiryMonth() int32 {
+	if x != nil {
+		return x.ExpiryMonth
+	}
+	return 0
+}
+
+func (x *IssuedCard) GetExpiryYear() int32 {
+	if x != nil {
+		return x.ExpiryYear
+	}
+	return 0
+}
+
+type ReissueCVVRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CardId        string                 `protobuf:"bytes,1,opt,name=card_id,json=cardId,proto3" json:"card_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReissueCVVRequest) Reset() {
+	*x = ReissueCVVRequest{}
+	mi := &file_proto_txn_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReissueCVVRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReissueCVVRequest) ProtoMessage() {}
+
+func (x *ReissueCVVRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_txn_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReissueCVVRequest.ProtoReflect.Descriptor instead.
+func (*ReissueCVVRequest) Descriptor() ([]byte, []int) {
+	return file_proto_txn_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ReissueCVVRequest) GetCardId() string {
+	if x != nil {
+		return x.CardId
+	}
+	return ""
+}
+
+var File_proto_txn_proto protoreflect.FileDescriptor
+
+const file_proto_txn_proto_rawDesc = "" +
+	"\n" +
+	"\x0fproto/txn.proto\x12\btransfer\"\xe3\x01\n" +
+	"\x0fTransferRequest\x12&\n" +
+	"\x0ffrom_account_id\x18\x01 \x01(\tR\rfromAccountId\x12\"\n" +
+	"\rto_account_id\x18\x02 \x01(\tR\vtoAccountId\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x03R\x06amount\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12'\n" +
+	"\x0fidempotency_key\x18\x05 \x01(\tR\x0eidempotencyKey\x12!\n" +
+	"\finitiated_by\x18\x06 \x01(\tR\vinitiatedBy\"\xad\x02\n" +
+	"\x10TransferResponse\x12%\n" +
+	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x12\x1c\n" +
+	"\treference\x18\x02 \x01(\tR\treference\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x03R\x06amount\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\tR\tcreatedAt\x12 \n" +
+	"\vdescription\x18\x06 \x01(\tR\vdescription\x12!\n" +
+	"\ffrom_account\x18\a \x01(\tR\vfromAccount\x12\x1d\n" +
+	"\n" +
+	"to_account\x18\b \x01(\tR\ttoAccount\x12!\n" +
+	"\frisk_reasons\x18\t \x03(\tR\vriskReasons\";\n" +
+	"\x12GetTransferRequest\x12%\n" +
+	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\"n\n" +
+	"\x15ReviewTransferRequest\x12%\n" +
+	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x12\x12\n" +
+	"\x04note\x18\x02 \x01(\tR\x04note\x12\x1a\n" +
+	"\breviewer\x18\x03 \x01(\tR\breviewer\"z\n" +
+	"\x18WatchTransactionsRequest\x12\x1f\n" +
+	"\vaccount_ids\x18\x01 \x03(\tR\n" +
+	"accountIds\x12\x16\n" +
+	"\x06cursor\x18\x02 \x01(\tR\x06cursor\x12%\n" +
+	"\x0ereplay_seconds\x18\x03 \x01(\x05R\rreplaySeconds\"\xb6\x02\n" +
+	"\x10TransactionEvent\x12\x16\n" +
+	"\x06cursor\x18\x01 \x01(\tR\x06cursor\x12%\n" +
+	"\x0etransaction_id\x18\x02 \x01(\tR\rtransactionId\x12\x1c\n" +
+	"\treference\x18\x03 \x01(\tR\treference\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x16\n" +
+	"\x06amount\x18\x06 \x01(\x03R\x06amount\x12 \n" +
+	"\vdescription\x18\a \x01(\tR\vdescription\x12!\n" +
+	"\ffrom_account\x18\b \x01(\tR\vfromAccount\x12\x1d\n" +
+	"\n" +
+	"to_account\x18\t \x01(\tR\ttoAccount\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\n" +
+	" \x01(\tR\tcreatedAt\"\\\n" +
+	"\x11GetBalanceRequest\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12(\n" +
+	"\x10max_staleness_ms\x18\x02 \x01(\x05R\x0emaxStalenessMs\"_\n" +
+	"\x12GetBalancesRequest\x12\x1f\n" +
+	"\vaccount_ids\x18\x01 \x03(\tR\n" +
+	"accountIds\x12(\n" +
+	"\x10max_staleness_ms\x18\x02 \x01(\x05R\x0emaxStalenessMs\"\xf9\x02\n" +
+	"\aBalance\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12%\n" +
+	"\x0eaccount_number\x18\x02 \x01(\tR\raccountNumber\x12%\n" +
+	"\x0eledger_balance\x18\x03 \x01(\x03R\rledgerBalance\x12+\n" +
+	"\x11available_balance\x18\x04 \x01(\x03R\x10availableBalance\x12\x1f\n" +
+	"\vheld_amount\x18\x05 \x01(\x03R\n" +
+	"heldAmount\x12\x1a\n" +
+	"\bcurrency\x18\x06 \x01(\tR\bcurrency\x12/\n" +
+	"\x14as_of_transaction_id\x18\a \x01(\tR\x11asOfTransactionId\x12 \n" +
+	"\fas_of_cursor\x18\b \x01(\tR\n" +
+	"asOfCursor\x12!\n" +
+	"\ffrom_replica\x18\t \x01(\bR\vfromReplica\x12!\n" +
+	"\fstaleness_ms\x18\n" +
+	" \x01(\x03R\vstalenessMs\"t\n" +
+	"\x13GetBalancesResponse\x12-\n" +
+	"\bbalances\x18\x01 \x03(\v2\x11.transfer.BalanceR\bbalances\x12.\n" +
+	"\x13missing_account_ids\x18\x02 \x03(\tR\x11missingAccountIds\"V\n" +
+	"\x10IssueCardRequest\x12!\n" +
+	"\fexpiry_month\x18\x01 \x01(\x05R\vexpiryMonth\x12\x1f\n" +
+	"\vexpiry_year\x18\x02 \x01(\x05R\n" +
+	"expiryYear\"\xeb\x01\n" +
+	"\n" +
+	"IssuedCard\x12&\n" +
+	"\x0fcard_number_enc\x18\x01 \x01(\tR\rcardNumberEnc\x12(\n" +
+	"\x10card_number_hash\x18\x02 \x01(\tR\x0ecardNumberHash\x12\x1d\n" +
+	"\n" +
+	"card_last4\x18\x03 \x01(\tR\tcardLast4\x12\x16\n" +
+	"\x06masked\x18\x04 \x01(\tR\x06masked\x12\x10\n" +
+	"\x03cvv\x18\x05 \x01(\tR\x03cvv\x12!\n" +
+	"\fexpiry_month\x18\x06 \x01(\x05R\vexpiryMonth\x12\x1f\n" +
+	"\vexpiry_year\x18\a \x01(\x05R\n" +
+	"expiryYear\",\n" +
+	"\x11ReissueCVVRequest\x12\x17\n" +
+	"\acard_id\x18\x01 \x01(\tR\x06cardId2\x9d\x04\n" +
+	"\x0fTransferService\x12A\n" +
+	"\bTransfer\x12\x19.transfer.TransferRequest\x1a\x1a.transfer.TransferResponse\x12G\n" +
+	"\vGetTransfer\x12\x1c.transfer.GetTransferRequest\x1a\x1a.transfer.TransferResponse\x12N\n" +
+	"\x0fApproveTransfer\x12\x1f.transfer.ReviewTransferRequest\x1a\x1a.transfer.TransferResponse\x12M\n" +
+	"\x0eRejectTransfer\x12\x1f.transfer.ReviewTransferRequest\x1a\x1a.transfer.TransferResponse\x12<\n" +
+	"\n" +
+	"GetBalance\x12\x1b.transfer.GetBalanceRequest\x1a\x11.transfer.Balance\x12J\n" +
+	"\vGetBalances\x12\x1c.transfer.GetBalancesRequest\x1a\x1d.transfer.GetBalancesResponse\x12U\n" +
+	"\x11WatchTransactions\x12\".transfer.WatchTransactionsRequest\x1a\x1a.transfer.TransactionEvent0\x012\x92\x01\n" +
+	"\x10CardVaultService\x12=\n" +
+	"\tIssueCard\x12\x1a.transfer.IssueCardRequest\x1a\x14.transfer.IssuedCard\x12?\n" +
+	"\n" +
+	"ReissueCVV\x12\x1b.transfer.ReissueCVVRequest\x1a\x14.transfer.IssuedCardB\x18Z\x16payments-core/proto;pbb\x06proto3"
 
 var (
 	file_proto_txn_proto_rawDescOnce sync.Once
@@ -197,19 +1086,47 @@ func file_proto_txn_proto_rawDescGZIP() []byte {
 	return file_proto_txn_proto_rawDescData
 }
 
-var file_proto_txn_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_txn_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_proto_txn_proto_goTypes = []any{
-	(*TransferRequest)(nil),  // 0: transfer.TransferRequest
-	(*TransferResponse)(nil), // 1: transfer.TransferResponse
+	(*TransferRequest)(nil),          // 0: transfer.TransferRequest
+	(*TransferResponse)(nil),         // 1: transfer.TransferResponse
+	(*GetTransferRequest)(nil),       // 2: transfer.GetTransferRequest
+	(*ReviewTransferRequest)(nil),    // 3: transfer.ReviewTransferRequest
+	(*WatchTransactionsRequest)(nil), // 4: transfer.WatchTransactionsRequest
+	(*TransactionEvent)(nil),         // 5: transfer.TransactionEvent
+	(*GetBalanceRequest)(nil),        // 6: transfer.GetBalanceRequest
+	(*GetBalancesRequest)(nil),       // 7: transfer.GetBalancesRequest
+	(*Balance)(nil),                  // 8: transfer.Balance
+	(*GetBalancesResponse)(nil),      // 9: transfer.GetBalancesResponse
+	(*IssueCardRequest)(nil),         // 10: transfer.IssueCardRequest
+	(*IssuedCard)(nil),               // 11: transfer.IssuedCard
+	(*ReissueCVVRequest)(nil),        // 12: transfer.ReissueCVVRequest
 }
 var file_proto_txn_proto_depIdxs = []int32{
-	0, // 0: transfer.TransferService.Transfer:input_type -> transfer.TransferRequest
-	1, // 1: transfer.TransferService.Transfer:output_type -> transfer.TransferResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	8,  // 0: transfer.GetBalancesResponse.balances:type_name -> transfer.Balance
+	0,  // 1: transfer.TransferService.Transfer:input_type -> transfer.TransferRequest
+	2,  // 2: transfer.TransferService.GetTransfer:input_type -> transfer.GetTransferRequest
+	3,  // 3: transfer.TransferService.ApproveTransfer:input_type -> transfer.ReviewTransferRequest
+	3,  // 4: transfer.TransferService.RejectTransfer:input_type -> transfer.ReviewTransferRequest
+	6,  // 5: transfer.TransferService.GetBalance:input_type -> transfer.GetBalanceRequest
+	7,  // 6: transfer.TransferService.GetBalances:input_type -> transfer.GetBalancesRequest
+	4,  // 7: transfer.TransferService.WatchTransactions:input_type -> transfer.WatchTransactionsRequest
+	10, // 8: transfer.CardVaultService.IssueCard:input_type -> transfer.IssueCardRequest
+	12, // 9: transfer.CardVaultService.ReissueCVV:input_type -> transfer.ReissueCVVRequest
+	1,  // 10: transfer.TransferService.Transfer:output_type -> transfer.TransferResponse
+	1,  // 11: transfer.TransferService.GetTransfer:output_type -> transfer.TransferResponse
+	1,  // 12: transfer.TransferService.ApproveTransfer:output_type -> transfer.TransferResponse
+	1,  // 13: transfer.TransferService.RejectTransfer:output_type -> transfer.TransferResponse
+	8,  // 14: transfer.TransferService.GetBalance:output_type -> transfer.Balance
+	9,  // 15: transfer.TransferService.GetBalances:output_type -> transfer.GetBalancesResponse
+	5,  // 16: transfer.TransferService.WatchTransactions:output_type -> transfer.TransactionEvent
+	11, // 17: transfer.CardVaultService.IssueCard:output_type -> transfer.IssuedCard
+	11, // 18: transfer.CardVaultService.ReissueCVV:output_type -> transfer.IssuedCard
+	10, // [10:19] is the sub-list for method output_type
+	1,  // [1:10] is the sub-list for method input_type
+	1,  // [1:1] is the sub-list for extension type_name
+	1,  // [1:1] is the sub-list for extension extendee
+	0,  // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_proto_txn_proto_init() }
@@ -223,9 +1140,9 @@ func file_proto_txn_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_txn_proto_rawDesc), len(file_proto_txn_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   13,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_proto_txn_proto_goTypes,
 		DependencyIndexes: file_proto_txn_proto_depIdxs,
@@ -19,7 +19,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	TransferService_Transfer_FullMethodName = "/transfer.TransferService/Transfer"
+	TransferService_Transfer_FullMethodName          = "/transfer.TransferService/Transfer"
+	TransferService_GetTransfer_FullMethodName       = "/transfer.TransferService/GetTransfer"
+	TransferService_ApproveTransfer_FullMethodName   = "/transfer.TransferService/ApproveTransfer"
+	TransferService_RejectTransfer_FullMethodName    = "/transfer.TransferService/RejectTransfer"
+	TransferService_GetBalance_FullMethodName        = "/transfer.TransferService/GetBalance"
+	TransferService_GetBalances_FullMethodName       = "/transfer.TransferService/GetBalances"
+	TransferService_WatchTransactions_FullMethodName = "/transfer.TransferService/WatchTransactions"
 )
 
 // TransferServiceClient is the client API for TransferService service.
@@ -27,6 +33,12 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TransferServiceClient interface {
 	Transfer(ctx context.Context, in *TransferRequest, opts ...grpc.CallOption) (*TransferResponse, error)
+	GetTransfer(ctx context.Context, in *GetTransferRequest, opts ...grpc.CallOption) (*TransferResponse, error)
+	ApproveTransfer(ctx context.Context, in *ReviewTransferRequest, opts ...grpc.CallOption) (*TransferResponse, error)
+	RejectTransfer(ctx context.Context, in *ReviewTransferRequest, opts ...grpc.CallOption) (*TransferResponse, error)
+	GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error)
+	GetBalances(ctx context.Context, in *GetBalancesRequest, opts ...grpc.CallOption) (*GetBalancesResponse, error)
+	WatchTransactions(ctx context.Context, in *WatchTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransactionEvent], error)
 }
 
 type transferServiceClient struct {
@@ -47,11 +59,86 @@ func (c *transferServiceClient) Transfer(ctx context.Context, in *TransferReques
 	return out, nil
 }
 
+func (c *transferServiceClient) GetTransfer(ctx context.Context, in *GetTransferRequest, opts ...grpc.CallOption) (*TransferResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransferResponse)
+	err := c.cc.Invoke(ctx, TransferService_GetTransfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transferServiceClient) ApproveTransfer(ctx context.Context, in *ReviewTransferRequest, opts ...grpc.CallOption) (*TransferResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransferResponse)
+	err := c.cc.Invoke(ctx, TransferService_ApproveTransfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transferServiceClient) RejectTransfer(ctx context.Context, in *ReviewTransferRequest, opts ...grpc.CallOption) (*TransferResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransferResponse)
+	err := c.cc.Invoke(ctx, TransferService_RejectTransfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transferServiceClient) GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Balance)
+	err := c.cc.Invoke(ctx, TransferService_GetBalance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transferServiceClient) GetBalances(ctx context.Context, in *GetBalancesRequest, opts ...grpc.CallOption) (*GetBalancesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetBalancesResponse)
+	err := c.cc.Invoke(ctx, TransferService_GetBalances_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transferServiceClient) WatchTransactions(ctx context.Context, in *WatchTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransactionEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TransferService_ServiceDesc.Streams[0], TransferService_WatchTransactions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchTransactionsRequest, TransactionEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TransferService_WatchTransactionsClient = grpc.ServerStreamingClient[TransactionEvent]
+
 // TransferServiceServer is the server API for TransferService service.
 // All implementations must embed UnimplementedTransferServiceServer
 // for forward compatibility.
 type TransferServiceServer interface {
 	Transfer(context.Context, *TransferRequest) (*TransferResponse, error)
+	GetTransfer(context.Context, *GetTransferRequest) (*TransferResponse, error)
+	ApproveTransfer(context.Context, *ReviewTransferRequest) (*TransferResponse, error)
+	RejectTransfer(context.Context, *ReviewTransferRequest) (*TransferResponse, error)
+	GetBalance(context.Context, *GetBalanceRequest) (*Balance, error)
+	GetBalances(context.Context, *GetBalancesRequest) (*GetBalancesResponse, error)
+	WatchTransactions(*WatchTransactionsRequest, grpc.ServerStreamingServer[TransactionEvent]) error
 	mustEmbedUnimplementedTransferServiceServer()
 }
 
@@ -65,6 +152,24 @@ type UnimplementedTransferServiceServer struct{}
 func (UnimplementedTransferServiceServer) Transfer(context.Context, *TransferRequest) (*TransferResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Transfer not implemented")
 }
+func (UnimplementedTransferServiceServer) GetTransfer(context.Context, *GetTransferRequest) (*TransferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransfer not implemented")
+}
+func (UnimplementedTransferServiceServer) ApproveTransfer(context.Context, *ReviewTransferRequest) (*TransferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApproveTransfer not implemented")
+}
+func (UnimplementedTransferServiceServer) RejectTransfer(context.Context, *ReviewTransferRequest) (*TransferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RejectTransfer not implemented")
+}
+func (UnimplementedTransferServiceServer) GetBalance(context.Context, *GetBalanceRequest) (*Balance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedTransferServiceServer) GetBalances(context.Context, *GetBalancesRequest) (*GetBalancesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalances not implemented")
+}
+func (UnimplementedTransferServiceServer) WatchTransactions(*WatchTransactionsRequest, grpc.ServerStreamingServer[TransactionEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTransactions not implemented")
+}
 func (UnimplementedTransferServiceServer) mustEmbedUnimplementedTransferServiceServer() {}
 func (UnimplementedTransferServiceServer) testEmbeddedByValue()                         {}
 
@@ -104,6 +209,107 @@ func _TransferService_Transfer_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TransferService_GetTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransferServiceServer).GetTransfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransferService_GetTransfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransferServiceServer).GetTransfer(ctx, req.(*GetTransferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransferService_ApproveTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReviewTransferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransferServiceServer).ApproveTransfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransferService_ApproveTransfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransferServiceServer).ApproveTransfer(ctx, req.(*ReviewTransferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransferService_RejectTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReviewTransferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransferServiceServer).RejectTransfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransferService_RejectTransfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransferServiceServer).RejectTransfer(ctx, req.(*ReviewTransferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransferService_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransferServiceServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransferService_GetBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransferServiceServer).GetBalance(ctx, req.(*GetBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransferService_GetBalances_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalancesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransferServiceServer).GetBalances(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransferService_GetBalances_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransferServiceServer).GetBalances(ctx, req.(*GetBalancesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransferService_WatchTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TransferServiceServer).WatchTransactions(m, &grpc.GenericServerStream[WatchTransactionsRequest, TransactionEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TransferService_WatchTransactionsServer = grpc.ServerStreamingServer[TransactionEvent]
+
 // TransferService_ServiceDesc is the grpc.ServiceDesc for TransferService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,6 +321,178 @@ var TransferService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Transfer",
 			Handler:    _TransferService_Transfer_Handler,
 		},
+		{
+			MethodName: "GetTransfer",
+			Handler:    _TransferService_GetTransfer_Handler,
+		},
+		{
+			MethodName: "ApproveTransfer",
+			Handler:    _TransferService_ApproveTransfer_Handler,
+		},
+		{
+			MethodName: "RejectTransfer",
+			Handler:    _TransferService_RejectTransfer_Handler,
+		},
+		{
+			MethodName: "GetBalance",
+			Handler:    _TransferService_GetBalance_Handler,
+		},
+		{
+			MethodName: "GetBalances",
+			Handler:    _TransferService_GetBalances_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTransactions",
+			Handler:       _TransferService_WatchTransactions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/txn.proto",
+}
+
+const (
+	CardVaultService_IssueCard_FullMethodName  = "/transfer.CardVaultService/IssueCard"
+	CardVaultService_ReissueCVV_FullMethodName = "/transfer.CardVaultService/ReissueCVV"
+)
+
+// CardVaultServiceClient is the client API for CardVaultService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CardVaultServiceClient interface {
+	IssueCard(ctx context.Context, in *IssueCardRequest, opts ...grpc.CallOption) (*IssuedCard, error)
+	// ReissueCVV moves a card with a stored legacy CVV to its derived CVV and
+	// clears the stored one. The returned CVV must be delivered to the
+	// cardholder with the reissued card.
+	ReissueCVV(ctx context.Context, in *ReissueCVVRequest, opts ...grpc.CallOption) (*IssuedCard, error)
+}
+
+type cardVaultServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCardVaultServiceClient(cc grpc.ClientConnInterface) CardVaultServiceClient {
+	return &cardVaultServiceClient{cc}
+}
+
+func (c *cardVaultServiceClient) IssueCard(ctx context.Context, in *IssueCardRequest, opts ...grpc.CallOption) (*IssuedCard, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IssuedCard)
+	err := c.cc.Invoke(ctx, CardVaultService_IssueCard_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cardVaultServiceClient) ReissueCVV(ctx context.Context, in *ReissueCVVRequest, opts ...grpc.CallOption) (*IssuedCard, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IssuedCard)
+	err := c.cc.Invoke(ctx, CardVaultService_ReissueCVV_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CardVaultServiceServer is the server API for CardVaultService service.
+// All implementations must embed UnimplementedCardVaultServiceServer
+// for forward compatibility.
+type CardVaultServiceServer interface {
+	IssueCard(context.Context, *IssueCardRequest) (*IssuedCard, error)
+	// ReissueCVV moves a card with a stored legacy CVV to its derived CVV and
+	// clears the stored one. The returned CVV must be delivered to the
+	// cardholder with the reissued card.
+	ReissueCVV(context.Context, *ReissueCVVRequest) (*IssuedCard, error)
+	mustEmbedUnimplementedCardVaultServiceServer()
+}
+
+// UnimplementedCardVaultServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCardVaultServiceServer struct{}
+
+func (UnimplementedCardVaultServiceServer) IssueCard(context.Context, *IssueCardRequest) (*IssuedCard, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IssueCard not implemented")
+}
+func (UnimplementedCardVaultServiceServer) ReissueCVV(context.Context, *ReissueCVVRequest) (*IssuedCard, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReissueCVV not implemented")
+}
+func (UnimplementedCardVaultServiceServer) mustEmbedUnimplementedCardVaultServiceServer() {}
+func (UnimplementedCardVaultServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeCardVaultServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CardVaultServiceServer will
+// result in compilation errors.
+type UnsafeCardVaultServiceServer interface {
+	mustEmbedUnimplementedCardVaultServiceServer()
+}
+
+func RegisterCardVaultServiceServer(s grpc.ServiceRegistrar, srv CardVaultServiceServer) {
+	// If the following call pancis, it indicates UnimplementedCardVaultServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CardVaultService_ServiceDesc, srv)
+}
+
+func _CardVaultService_IssueCard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IssueCardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CardVaultServiceServer).IssueCard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CardVaultService_IssueCard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CardVaultServiceServer).IssueCard(ctx, req.(*IssueCardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CardVaultService_ReissueCVV_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReissueCVVRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CardVaultServiceServer).ReissueCVV(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CardVaultService_ReissueCVV_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CardVaultServiceServer).ReissueCVV(ctx, req.(*ReissueCVVRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CardVaultService_ServiceDesc is the grpc.ServiceDesc for CardVaultService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CardVaultService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "transfer.CardVaultService",
+	HandlerType: (*CardVaultServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "IssueCard",
+			Handler:    _CardVaultService_IssueCard_Handler,
+		},
+		{
+			MethodName: "ReissueCVV",
+			Handler:    _CardVaultService_ReissueCVV_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/txn.proto",
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	pb "payments-core/generated"
+)
+
+const (
+	HealthServiceDB         = "payments-core.db"
+	HealthServiceKeyManager = "payments-core.keymanager"
+	HealthServicePublisher  = "payments-core.publisher"
+
+	DefaultHealthInterval = 5 * time.Second
+	DefaultHealthTimeout  = 2 * time.Second
+)
+
+// HealthMethods are served without authentication so load balancers and
+// orchestrators can probe core.
+var HealthMethods = []string{
+	healthpb.Health_Check_FullMethodName,
+	healthpb.Health_Watch_FullMethodName,
+	healthpb.Health_List_FullMethodName,
+}
+
+type HealthCheck struct {
+	Service string
+	Check   func(ctx context.Context) error
+}
+
+func DBHealthCheck(db *sql.DB) HealthCheck {
+	return HealthCheck{HealthServiceDB, db.PingContext}
+}
+
+// KeyManagerHealthCheck signs and verifies a probe, which exercises a remote
+// key manager end to end rather than just its connection.
+func KeyManagerHealthCheck(km KeyManager) HealthCheck {
+	return HealthCheck{HealthServiceKeyManager, func(context.Context) error {
+		probe := []byte("health-probe")
+		sig, err := SignPayload(probe, km)
+		if err != nil {
+			return err
+		}
+		ok, err := VerifySignature(probe, sig, km)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("probe signature did not verify")
+		}
+		return nil
+	}}
+}
+
+func PublisherHealthCheck(k *KafkaService) HealthCheck {
+	return HealthCheck{HealthServicePublisher, k.Ping}
+}
+
+// HealthMonitor runs every check on an interval and publishes one status per
+// dependency. The overall ("") and TransferService statuses are SERVING only
+// while every dependency is.
+type HealthMonitor struct {
+	server   *health.Server
+	checks   []HealthCheck
+	Interval time.Duration
+	Timeout  time.Duration
+	Logger   *slog.Logger
+
+	mu     sync.Mutex
+	failed map[string]bool
+}
+
+func NewHealthMonitor(server *health.Server, checks ...HealthCheck) *HealthMonitor {
+	m := &HealthMonitor{
+		server:   server,
+		checks:   checks,
+		Interval: DefaultHealthInterval,
+		Timeout:  DefaultHealthTimeout,
+		Logger:   slog.Default(),
+		failed:   map[string]bool{},
+	}
+	for _, c := range checks {
+		server.SetServingStatus(c.Service, healthpb.HealthCheckResponse_UNKNOWN)
+	}
+	server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	server.SetServingStatus(pb.TransferService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	return m
+}
+
+// Run checks immediately and then every Interval until ctx is done.
+func (m *HealthMonitor) Run(ctx context.Context) {
+	t := time.NewTicker(m.Interval)
+	defer t.Stop()
+	for {
+		m.CheckNow(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (m *HealthMonitor) CheckNow(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range m.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, m.Timeout)
+			defer cancel()
+			m.record(c.Service, c.Check(cctx))
+		}()
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	overall := healthpb.HealthCheckResponse_SERVING
+	for _, failed := range m.failed {
+		if failed {
+			overall = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	m.mu.Unlock()
+	m.server.SetServingStatus("", overall)
+	m.server.SetServingStatus(pb.TransferService_ServiceDesc.ServiceName, overall)
+}
+
+func (m *HealthMonitor) record(service string, err error) {
+	st := healthpb.HealthCheckResponse_SERVING
+	if err != nil {
+		st = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	m.mu.Lock()
+	was, seen := m.failed[service]
+	m.failed[service] = err != nil
+	m.mu.Unlock()
+	if !seen || was != (err != nil) {
+		if err != nil {
+			m.Logger.Warn("dependency unhealthy", slog.String("service", service), slog.Any("error", err))
+		} else {
+			m.Logger.Info("dependency healthy", slog.String("service", service))
+		}
+	}
+	m.server.SetServingStatus(service, st)
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different transfer")
+
+var idempotencySchema = []string{
+	`CREATE TABLE IF NOT EXISTS transfer_idempotency (
+		key            TEXT PRIMARY KEY,
+		request_hash   TEXT NOT NULL,
+		transaction_id TEXT,
+		created_at     TIMESTAMP NOT NULL
+	)`,
+}
+
+func EnsureIdempotencySchema(ctx context.Context, db *sql.DB) error {
+	for _, s := range idempotencySchema {
+		if _, err := db.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func transferRequestHash(fromAccountId, toAccountId string, amount int64, description string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s", fromAccountId, toAccountId, amount, description)))
+	return hex.EncodeToString(sum[:])
+}
+
+// claimIdempotencyKey reserves key inside tx. A concurrent claim of the same
+// key blocks until tx ends, so only one transfer is ever made per key. If
+// the key already belongs to a committed transfer, its transaction ID is
+// returned and the caller must not transfer again.
+func claimIdempotencyKey(ctx context.Context, tx *sql.Tx, key, requestHash string) (string, error) {
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO transfer_idempotency (key, request_hash, created_at) VALUES ($1,$2,$3)
+		ON CONFLICT (key) DO NOTHING`,
+		key, requestHash, time.Now().UTC(),
+	)
+	if err != nil {
+		return "", err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 1 {
+		return "", err
+	}
+	var hash string
+	var transactionID sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT request_hash, transaction_id FROM transfer_idempotency WHERE key=$1", key).Scan(&hash, &transactionID)
+	if err != nil {
+		return "", err
+	}
+	if hash != requestHash || !transactionID.Valid {
+		return "", ErrIdempotencyKeyReused
+	}
+	return transactionID.String, nil
+}
+
+func recordIdempotencyKey(ctx context.Context, tx *sql.Tx, key, transactionID string) error {
+	_, err := tx.ExecContext(ctx, "UPDATE transfer_idempotency SET transaction_id=$1 WHERE key=$2", transactionID, key)
+	return err
+}
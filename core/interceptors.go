@@ -0,0 +1,350 @@
+package core
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	AuthMTLS  = "mtls"
+	AuthToken = "token"
+)
+
+var ErrInvalidServiceToken = errors.New("invalid service token")
+
+type Principal struct {
+	Name   string
+	Method string
+}
+
+type principalKey struct{}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// principalHolder is placed on the context by the logging interceptors,
+// which run outside authentication, so they can still log who called.
+type principalHolder struct{ name string }
+
+type principalHolderKey struct{}
+
+func withPrincipalHolder(ctx context.Context) (context.Context, *principalHolder) {
+	h := &principalHolder{}
+	return context.WithValue(ctx, principalHolderKey{}, h), h
+}
+
+func notePrincipal(ctx context.Context, p Principal) {
+	if h, ok := ctx.Value(principalHolderKey{}).(*principalHolder); ok {
+		h.name = p.Name
+	}
+}
+
+type serviceClaims struct {
+	Sub string `json:"sub"`
+	Iat int64  `json:"iat"`
+	Exp int64  `json:"exp"`
+}
+
+// IssueServiceToken mints a bearer token for subject that the core server
+// accepts in the "authorization" metadata header. It is the payload and
+// its SignPayload signature, both base64url encoded and joined by a dot.
+func IssueServiceToken(km KeyManager, subject string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims, err := json.Marshal(serviceClaims{Sub: subject, Iat: now.Unix(), Exp: now.Add(ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(claims)
+	sig, err := SignPayload([]byte("svc."+body), km)
+	if err != nil {
+		return "", err
+	}
+	return body + "." + base64.RawURLEncoding.EncodeToString([]byte(sig)), nil
+}
+
+func VerifyServiceToken(token string, km KeyManager) (string, error) {
+	body, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidServiceToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return "", ErrInvalidServiceToken
+	}
+	valid, err := VerifySignature([]byte("svc."+body), string(sig), km)
+	if err != nil {
+		return "", err
+	}
+	if !valid {
+		return "", ErrInvalidServiceToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return "", ErrInvalidServiceToken
+	}
+	var claims serviceClaims
+	if err := json.Unmarshal(raw, &claims); err != nil || claims.Sub == "" {
+		return "", ErrInvalidServiceToken
+	}
+	if time.Now().Unix() >= claims.Exp {
+		return "", ErrInvalidServiceToken
+	}
+	return claims.Sub, nil
+}
+
+// MethodPolicy maps a full gRPC method name, or "*" for any method, to the
+// principals allowed to call it. "*" as a principal admits any caller that
+// authenticated.
+type MethodPolicy map[string][]string
+
+func (p MethodPolicy) Allows(method, principal string) bool {
+	allowed, ok := p[method]
+	if !ok {
+		allowed = p["*"]
+	}
+	for _, a := range allowed {
+		if a == "*" || a == principal {
+			return true
+		}
+	}
+	return false
+}
+
+type InterceptorConfig struct {
+	KeyManager KeyManager
+	Policy     MethodPolicy
+	Logger     *slog.Logger
+	// Unauthenticated lists methods, such as health checks, that skip
+	// authentication and authorization.
+	Unauthenticated []string
+}
+
+func ServerInterceptors(cfg InterceptorConfig) []grpc.ServerOption {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			LoggingInterceptor(cfg.Logger),
+			RecoveryInterceptor(cfg.Logger),
+			AuthInterceptor(cfg),
+		),
+		grpc.ChainStreamInterceptor(
+			StreamLoggingInterceptor(cfg.Logger),
+			StreamRecoveryInterceptor(cfg.Logger),
+			StreamAuthInterceptor(cfg),
+		),
+	}
+}
+
+// serverStream overrides the context of a stream and counts sent messages.
+type serverStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent int
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+	}
+	return err
+}
+
+func LoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx, caller := withPrincipalHolder(ctx)
+		resp, err := handler(ctx, req)
+		attrs := []any{
+			slog.String("method", info.FullMethod),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("code", status.Code(err).String()),
+		}
+		if caller.name != "" {
+			attrs = append(attrs, slog.String("principal", caller.name))
+		}
+		if r, ok := resp.(interface{ GetReference() string }); ok && r.GetReference() != "" {
+			attrs = append(attrs, slog.String("reference", r.GetReference()))
+		}
+		level := slog.LevelInfo
+		if err != nil {
+			attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+			if status.Code(err) == codes.Internal || status.Code(err) == codes.Unknown {
+				level = slog.LevelError
+			}
+		}
+		logger.Log(ctx, level, "grpc call", attrs...)
+		return resp, err
+	}
+}
+
+func StreamLoggingInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, caller := withPrincipalHolder(ss.Context())
+		wrapped := &serverStream{ServerStream: ss, ctx: ctx}
+		err := handler(srv, wrapped)
+		attrs := []any{
+			slog.String("method", info.FullMethod),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("code", status.Code(err).String()),
+			slog.Int("sent", wrapped.sent),
+		}
+		if caller.name != "" {
+			attrs = append(attrs, slog.String("principal", caller.name))
+		}
+		level := slog.LevelInfo
+		if err != nil {
+			attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+			if status.Code(err) == codes.Internal || status.Code(err) == codes.Unknown {
+				level = slog.LevelError
+			}
+		}
+		logger.Log(ss.Context(), level, "grpc stream", attrs...)
+		return err
+	}
+}
+
+func RecoveryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("grpc panic", slog.String("method", info.FullMethod), slog.Any("panic", r), slog.String("stack", string(debug.Stack())))
+				resp, err = nil, status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func StreamRecoveryInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("grpc panic", slog.String("method", info.FullMethod), slog.Any("panic", r), slog.String("stack", string(debug.Stack())))
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func AuthInterceptor(cfg InterceptorConfig) grpc.UnaryServerInterceptor {
+	open := map[string]bool{}
+	for _, m := range cfg.Unauthenticated {
+		open[m] = true
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if open[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		p, err := authenticate(ctx, cfg.KeyManager)
+		if err != nil {
+			return nil, err
+		}
+		notePrincipal(ctx, p)
+		if !cfg.Policy.Allows(info.FullMethod, p.Name) {
+			return nil, status.Errorf(codes.PermissionDenied, "%s may not call %s", p.Name, info.FullMethod)
+		}
+		return handler(context.WithValue(ctx, principalKey{}, p), req)
+	}
+}
+
+func StreamAuthInterceptor(cfg InterceptorConfig) grpc.StreamServerInterceptor {
+	open := map[string]bool{}
+	for _, m := range cfg.Unauthenticated {
+		open[m] = true
+	}
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if open[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		p, err := authenticate(ss.Context(), cfg.KeyManager)
+		if err != nil {
+			return err
+		}
+		notePrincipal(ss.Context(), p)
+		if !cfg.Policy.Allows(info.FullMethod, p.Name) {
+			return status.Errorf(codes.PermissionDenied, "%s may not call %s", p.Name, info.FullMethod)
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), principalKey{}, p)})
+	}
+}
+
+func authenticate(ctx context.Context, km KeyManager) (Principal, error) {
+	if name, ok := peerCertificateName(ctx); ok {
+		return Principal{Name: name, Method: AuthMTLS}, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return authenticateBearer(md.Get("authorization"), km)
+}
+
+func authenticateBearer(headers []string, km KeyManager) (Principal, error) {
+	for _, v := range headers {
+		token, ok := strings.CutPrefix(v, "Bearer ")
+		if !ok {
+			continue
+		}
+		sub, err := VerifyServiceToken(token, km)
+		if errors.Is(err, ErrInvalidServiceToken) {
+			return Principal{}, status.Error(codes.Unauthenticated, "invalid service token")
+		}
+		if err != nil {
+			return Principal{}, status.Error(codes.Unavailable, "token verification unavailable")
+		}
+		return Principal{Name: sub, Method: AuthToken}, nil
+	}
+	return Principal{}, status.Error(codes.Unauthenticated, "client certificate or service token required")
+}
+
+// peerCertificateName only trusts certificates the TLS stack verified
+// against the configured client CA.
+func peerCertificateName(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return certificateName(info.State.VerifiedChains[0][0]), true
+}
+
+func certificateName(cert *x509.Certificate) string {
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			return strings.TrimPrefix(u.Path, "/")
+		}
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return fmt.Sprintf("serial:%s", cert.SerialNumber)
+}
@@ -0,0 +1,47 @@
+package core_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	core "payments-core"
+)
+
+func TestServiceToken(t *testing.T) {
+	km := newTestKeyManager(t)
+	token, err := core.IssueServiceToken(km, "card-service", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := core.VerifyServiceToken(token, km)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub != "card-service" {
+		t.Fatalf("got subject %q, want card-service", sub)
+	}
+
+	expired, err := core.IssueServiceToken(km, "card-service", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := core.IssueServiceToken(newTestKeyManager(t), "manager-server", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, sig, _ := strings.Cut(token, ".")
+	otherBody, _, _ := strings.Cut(other, ".")
+	for name, tok := range map[string]string{
+		"expired":         expired,
+		"other key":       other,
+		"subject swapped": otherBody + "." + sig,
+		"no signature":    body,
+		"empty":           "",
+	} {
+		if _, err := core.VerifyServiceToken(tok, km); !errors.Is(err, core.ErrInvalidServiceToken) {
+			t.Errorf("%s: got %v, want ErrInvalidServiceToken", name, err)
+		}
+	}
+}
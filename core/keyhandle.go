@@ -0,0 +1,229 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"sync"
+)
+
+var ErrKeyManagerDestroyed = errors.New("key manager destroyed")
+
+// KeyBorrower lends key material to fn for the duration of the call. The
+// slice is wiped once fn returns, so fn must not retain it.
+type KeyBorrower interface {
+	WithEncryptionKey(fn func(key []byte) error) error
+	WithSigningKey(fn func(key []byte) error) error
+}
+
+// PrimitiveCache hands out ready-made primitives so hot paths such as
+// Encrypt and SignPayload do not rebuild the cipher and MAC on every call.
+// The AEAD is safe for concurrent use; a MAC must be released after use.
+type PrimitiveCache interface {
+	EncryptionAEAD() (cipher.AEAD, error)
+	AcquireSigningMAC() (hash.Hash, func(), error)
+}
+
+type primitiveCache struct {
+	mu      sync.RWMutex
+	aead    cipher.AEAD
+	macPool *sync.Pool
+}
+
+func (m *InMemoryKeyManager) WithEncryptionKey(fn func(key []byte) error) error {
+	key, err := m.GetEncryptionKey()
+	if err != nil {
+		return err
+	}
+	defer wipe(key)
+	return fn(key)
+}
+
+func (m *InMemoryKeyManager) WithSigningKey(fn func(key []byte) error) error {
+	key, err := m.GetSigningKey()
+	if err != nil {
+		return err
+	}
+	defer wipe(key)
+	return fn(key)
+}
+
+func (m *InMemoryKeyManager) EncryptionAEAD() (cipher.AEAD, error) {
+	m.cache.mu.RLock()
+	aead := m.cache.aead
+	m.cache.mu.RUnlock()
+	if aead != nil {
+		return aead, nil
+	}
+	m.cache.mu.Lock()
+	defer m.cache.mu.Unlock()
+	if m.cache.aead != nil {
+		return m.cache.aead, nil
+	}
+	if m.destroyed {
+		return nil, ErrKeyManagerDestroyed
+	}
+	err := m.WithEncryptionKey(func(key []byte) error {
+		var err error
+		m.cache.aead, err = newGCM(key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.cache.aead, nil
+}
+
+func (m *InMemoryKeyManager) AcquireSigningMAC() (hash.Hash, func(), error) {
+	m.cache.mu.RLock()
+	pool := m.cache.macPool
+	m.cache.mu.RUnlock()
+	if pool == nil {
+		m.cache.mu.Lock()
+		if m.cache.macPool == nil {
+			if m.destroyed {
+				m.cache.mu.Unlock()
+				return nil, nil, ErrKeyManagerDestroyed
+			}
+			m.keyMu.RLock()
+			noKey := len(m.signKey) == 0
+			m.keyMu.RUnlock()
+			if noKey {
+				m.cache.mu.Unlock()
+				return nil, nil, errors.New("invalid signing key")
+			}
+			m.cache.macPool = &sync.Pool{New: func() any {
+				var h hash.Hash
+				m.WithSigningKey(func(key []byte) error {
+					h = hmac.New(sha256.New, key)
+					return nil
+				})
+				return h
+			}}
+		}
+		pool = m.cache.macPool
+		m.cache.mu.Unlock()
+	}
+	h, _ := pool.Get().(hash.Hash)
+	if h == nil {
+		return nil, nil, ErrKeyManagerDestroyed
+	}
+	h.Reset()
+	return h, func() {
+		h.Reset()
+		pool.Put(h)
+	}, nil
+}
+
+// Destroy wipes every key the manager holds and drops the cached
+// primitives. Later calls fail with ErrKeyManagerDestroyed or an invalid key
+// error.
+func (m *InMemoryKeyManager) Destroy() {
+	m.cache.mu.Lock()
+	m.destroyed = true
+	m.cache.aead = nil
+	m.cache.macPool = nil
+	m.cache.mu.Unlock()
+	m.keyMu.Lock()
+	wipe(m.encKey)
+	wipe(m.signKey)
+	m.encKey = nil
+	m.signKey = nil
+	m.keyMu.Unlock()
+	m.asym.mu.Lock()
+	for _, k := range m.asym.keys {
+		wipe(k.priv)
+	}
+	m.asym.keys = nil
+	m.asym.mu.Unlock()
+}
+
+func withEncryptionKey(km KeyManager, fn func(key []byte) error) error {
+	if b, ok := km.(KeyBorrower); ok {
+		return b.WithEncryptionKey(fn)
+	}
+	key, err := km.GetEncryptionKey()
+	if err != nil {
+		return err
+	}
+	defer wipe(key)
+	return fn(key)
+}
+
+func withSigningKey(km KeyManager, fn func(key []byte) error) error {
+	if b, ok := km.(KeyBorrower); ok {
+		return b.WithSigningKey(fn)
+	}
+	key, err := km.GetSigningKey()
+	if err != nil {
+		return err
+	}
+	defer wipe(key)
+	return fn(key)
+}
+
+// keyedDigest computes an HMAC of data separated by label, so blind
+// indexes, CVVs and references never collide with each other or with
+// SignPayload. In memory, each label gets its own sub-key of the signing key.
+// A RemoteCrypto manager cannot derive keys: it MACs label and data together
+// under its digest key, which for Vault is the signing key unless
+// VaultConfig.DigestKey names another.
+func keyedDigest(km KeyManager, label string, data []byte) ([]byte, error) {
+	if rc, ok := km.(RemoteCrypto); ok {
+		return rc.RemoteDigest(append([]byte(label+"\x00"), data...))
+	}
+	var sum []byte
+	err := withSigningKey(km, func(key []byte) error {
+		sub := hmac.New(sha256.New, key)
+		sub.Write([]byte(label))
+		subKey := sub.Sum(nil)
+		defer wipe(subKey)
+		mac := hmac.New(sha256.New, subKey)
+		mac.Write(data)
+		sum = mac.Sum(nil)
+		return nil
+	})
+	return sum, err
+}
+
+func encryptionAEAD(km KeyManager) (cipher.AEAD, error) {
+	if c, ok := km.(PrimitiveCache); ok {
+		return c.EncryptionAEAD()
+	}
+	var aead cipher.AEAD
+	err := withEncryptionKey(km, func(key []byte) error {
+		var err error
+		aead, err = newGCM(key)
+		return err
+	})
+	return aead, err
+}
+
+func withSigningMAC(km KeyManager, fn func(h hash.Hash) error) error {
+	if c, ok := km.(PrimitiveCache); ok {
+		h, release, err := c.AcquireSigningMAC()
+		if err != nil {
+			return err
+		}
+		defer release()
+		return fn(h)
+	}
+	return withSigningKey(km, func(key []byte) error {
+		return fn(hmac.New(sha256.New, key))
+	})
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func wipe(b []byte) {
+	clear(b)
+}
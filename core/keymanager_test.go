@@ -0,0 +1,279 @@
+package core_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	core "payments-core"
+	"payments-core/transitfake"
+)
+
+// testKeyManagerContract runs the behaviour every KeyManager must share.
+func testKeyManagerContract(t *testing.T, km core.KeyManager) {
+	t.Run("encrypt/decrypt round trip", func(t *testing.T) {
+		ct, err := core.Encrypt([]byte("4111111111111111"), km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt, err := core.Decrypt(ct, km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(pt) != "4111111111111111" {
+			t.Fatalf("plaintext mismatch: %q", pt)
+		}
+	})
+	t.Run("ciphertexts are randomized", func(t *testing.T) {
+		a, err := core.Encrypt([]byte("same"), km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := core.Encrypt([]byte("same"), km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a == b {
+			t.Fatal("identical ciphertexts")
+		}
+	})
+	t.Run("tampered ciphertext is rejected", func(t *testing.T) {
+		ct, err := core.Encrypt([]byte("payload"), km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := []byte(ct)
+		i := len(b) - 4
+		if b[i] == 'A' {
+			b[i] = 'B'
+		} else {
+			b[i] = 'A'
+		}
+		if _, err := core.Decrypt(string(b), km); err == nil {
+			t.Fatal("tampered ciphertext decrypted")
+		}
+	})
+	t.Run("sign/verify", func(t *testing.T) {
+		sig, err := core.SignPayload([]byte("transfer|1|2|100"), km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := core.VerifySignature([]byte("transfer|1|2|100"), sig, km)
+		if err != nil || !ok {
+			t.Fatalf("valid signature rejected: %v", err)
+		}
+		if ok, _ := core.VerifySignature([]byte("transfer|1|2|999"), sig, km); ok {
+			t.Fatal("signature verified for different payload")
+		}
+	})
+	t.Run("blind index is deterministic", func(t *testing.T) {
+		v := core.NewCardVault(km)
+		a, err := v.BlindIndex("4111 1111 1111 1111")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := v.BlindIndex("4111-1111-1111-1111")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != b {
+			t.Fatal("blind index differs for the same pan")
+		}
+	})
+	t.Run("references validate", func(t *testing.T) {
+		ref, err := core.GenerateReference(km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := core.ValidateReference(ref, km); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("stream round trip", func(t *testing.T) {
+		data := bytes.Repeat([]byte("ledger line\n"), 20000)
+		var buf bytes.Buffer
+		w, err := core.NewEncryptWriterSize(&buf, km, 4096)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		r, err := core.NewDecryptReader(bytes.NewReader(buf.Bytes()), km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatal("stream plaintext mismatch")
+		}
+	})
+}
+
+// testEventSignerContract checks event signing against the published key
+// set. rotate must make a new key active.
+func testEventSignerContract(t *testing.T, km, unkeyed core.AsymmetricKeyManager, rotate func()) {
+	payload := []byte("t1|acc-a|acc-b|1500|SBK-REF")
+	t.Run("events verify against the key set", func(t *testing.T) {
+		sig, err := core.SignEvent(payload, km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set, err := km.PublicKeySet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := core.VerifyEventSignature(payload, sig, set); err != nil || !ok {
+			t.Fatalf("valid event signature rejected: %v", err)
+		}
+		if ok, _ := core.VerifyEventSignature([]byte("t1|acc-a|acc-b|150000|SBK-REF"), sig, set); ok {
+			t.Fatal("signature verified for a different payload")
+		}
+	})
+	t.Run("rotated keys stay in the key set", func(t *testing.T) {
+		before, err := core.SignEvent(payload, km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotate()
+		after, err := core.SignEvent(payload, km)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if before.Kid == after.Kid {
+			t.Fatal("rotation did not change the key id")
+		}
+		set, err := km.PublicKeySet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sig := range []*core.EventSignature{before, after} {
+			if ok, err := core.VerifyEventSignature(payload, sig, set); err != nil || !ok {
+				t.Fatalf("signature by %s rejected after rotation: %v", sig.Kid, err)
+			}
+		}
+	})
+	t.Run("no event key fails closed", func(t *testing.T) {
+		if _, err := core.SignEvent(payload, unkeyed); !errors.Is(err, core.ErrNoEventSigner) {
+			t.Fatalf("expected ErrNoEventSigner, got %v", err)
+		}
+	})
+}
+
+func TestInMemoryKeyManagerContract(t *testing.T) {
+	km := newTestKeyManager(t)
+	testKeyManagerContract(t, km)
+
+	addKey := func() {
+		priv, err := core.GenerateEd25519Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := km.AddEd25519Key(priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addKey()
+	testEventSignerContract(t, km, newTestKeyManager(t), addKey)
+}
+
+func newTestVault(t *testing.T, addr string, fake *transitfake.Server, digestKey string) *core.VaultKeyManager {
+	t.Helper()
+	return newTestVaultConfig(t, core.VaultConfig{Address: addr, DigestKey: digestKey, EventKey: "core-events"}, fake)
+}
+
+func newTestVaultConfig(t *testing.T, cfg core.VaultConfig, fake *transitfake.Server) *core.VaultKeyManager {
+	t.Helper()
+	km, err := core.NewVaultKeyManager(core.VaultConfig{
+		Address:       cfg.Address,
+		EncryptionKey: "core-enc",
+		SigningKey:    "core-sign",
+		DigestKey:     cfg.DigestKey,
+		EventKey:      cfg.EventKey,
+		RoleID:        fake.RoleID,
+		SecretID:      fake.SecretID,
+		Timeout:       time.Second,
+		BaseBackoff:   5 * time.Millisecond,
+		MaxBackoff:    20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return km
+}
+
+func TestVaultKeyManagerContract(t *testing.T) {
+	fake := transitfake.NewServer()
+	t.Cleanup(fake.Close)
+	testKeyManagerContract(t, newTestVault(t, fake.URL, fake, ""))
+	testKeyManagerContract(t, newTestVault(t, fake.URL, fake, "core-digest"))
+	unkeyed := newTestVaultConfig(t, core.VaultConfig{Address: fake.URL}, fake)
+	testEventSignerContract(t, newTestVault(t, fake.URL, fake, ""), unkeyed, func() { fake.RotateKey("core-events") })
+}
+
+func TestVaultKeyManager(t *testing.T) {
+	fake := transitfake.NewServer()
+	t.Cleanup(fake.Close)
+	km := newTestVault(t, fake.URL, fake, "")
+
+	if _, err := km.GetEncryptionKey(); !errors.Is(err, core.ErrKeyNotExportable) {
+		t.Fatalf("raw keys: expected ErrKeyNotExportable, got %v", err)
+	}
+
+	core.Encrypt([]byte("warm"), km)
+	logins := fake.Logins()
+	for i := 0; i < 5; i++ {
+		core.Encrypt([]byte("x"), km)
+	}
+	if fake.Logins() != logins {
+		t.Fatal("token was not cached")
+	}
+
+	fake.RevokeTokens()
+	if _, err := core.Encrypt([]byte("x"), km); err != nil {
+		t.Fatalf("after token revocation: %v", err)
+	}
+	if fake.Logins() != logins+1 {
+		t.Fatalf("expected one re-login, got %d", fake.Logins()-logins)
+	}
+
+	fake.FailNext(http.StatusServiceUnavailable, http.StatusInternalServerError)
+	if _, err := core.Encrypt([]byte("x"), km); err != nil {
+		t.Fatalf("transient errors were not retried: %v", err)
+	}
+
+	fake.FailNext(http.StatusBadRequest)
+	if _, err := core.Encrypt([]byte("x"), km); err == nil {
+		t.Fatal("client error was retried into success")
+	}
+
+	down := newTestVault(t, "http://127.0.0.1:1", fake, "")
+	if _, err := core.Encrypt([]byte("x"), down); !errors.Is(err, core.ErrVaultUnavailable) {
+		t.Fatalf("unreachable: expected ErrVaultUnavailable, got %v", err)
+	}
+}
+
+func TestVaultDigestKeySeparatesDigests(t *testing.T) {
+	fake := transitfake.NewServer()
+	t.Cleanup(fake.Close)
+	shared, err := core.NewCardVault(newTestVault(t, fake.URL, fake, "")).BlindIndex("4111111111111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	separate, err := core.NewCardVault(newTestVault(t, fake.URL, fake, "core-digest")).BlindIndex("4111111111111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared == separate {
+		t.Fatal("DigestKey did not change the digest key")
+	}
+}
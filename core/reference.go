@@ -0,0 +1,141 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var crockford = base32.NewEncoding(crockfordAlphabet).WithPadding(base32.NoPadding)
+
+var (
+	ErrReferenceFormat   = errors.New("malformed reference")
+	ErrReferenceChecksum = errors.New("reference check digit mismatch")
+	ErrReferenceForged   = errors.New("reference failed authentication")
+)
+
+// ReferenceFormat renders references as PREFIX-DATE-BODYMAC-C, where BODY is
+// random, MAC authenticates prefix, date and body, and C is a Luhn mod 32
+// check character over everything after the prefix.
+type ReferenceFormat struct {
+	Prefix     string
+	DateLayout string
+	BodyLen    int
+	MACLen     int
+}
+
+var DefaultReferenceFormat = ReferenceFormat{Prefix: "SBK", DateLayout: "20060102", BodyLen: 8, MACLen: 6}
+
+func (f ReferenceFormat) validate() error {
+	if f.Prefix == "" || strings.Contains(f.Prefix, "-") {
+		return errors.New("invalid reference prefix")
+	}
+	if f.BodyLen < 4 || f.MACLen < 4 || f.BodyLen+f.MACLen > 40 {
+		return errors.New("invalid reference lengths")
+	}
+	for _, r := range time.Now().Format(f.DateLayout) {
+		if r < '0' || r > '9' {
+			return errors.New("reference date layout must be numeric")
+		}
+	}
+	return nil
+}
+
+func GenerateReference(km KeyManager) (string, error) {
+	return DefaultReferenceFormat.Generate(km)
+}
+
+func ValidateReference(ref string, km KeyManager) error {
+	return DefaultReferenceFormat.Validate(ref, km)
+}
+
+func (f ReferenceFormat) Generate(km KeyManager) (string, error) {
+	if err := f.validate(); err != nil {
+		return "", err
+	}
+	raw := make([]byte, (f.BodyLen*5+7)/8)
+	_, err := io.ReadFull(rand.Reader, raw)
+	if err != nil {
+		return "", err
+	}
+	body := crockford.EncodeToString(raw)[:f.BodyLen]
+	date := time.Now().UTC().Format(f.DateLayout)
+	mac, err := f.mac(date, body, km)
+	if err != nil {
+		return "", err
+	}
+	check := luhnMod32(date + body + mac)
+	return f.Prefix + "-" + date + "-" + body + mac + "-" + string(check), nil
+}
+
+func (f ReferenceFormat) Validate(ref string, km KeyManager) error {
+	parts := strings.Split(NormalizeReference(ref), "-")
+	if len(parts) != 4 || parts[0] != strings.ToUpper(f.Prefix) || len(parts[3]) != 1 {
+		return ErrReferenceFormat
+	}
+	date, tail := parts[1], parts[2]
+	if len(date) != len(f.DateLayout) || len(tail) != f.BodyLen+f.MACLen {
+		return ErrReferenceFormat
+	}
+	for _, r := range date + tail {
+		if !strings.ContainsRune(crockfordAlphabet, r) {
+			return ErrReferenceFormat
+		}
+	}
+	if luhnMod32(date+tail) != parts[3][0] {
+		return ErrReferenceChecksum
+	}
+	body, got := tail[:f.BodyLen], tail[f.BodyLen:]
+	want, err := f.mac(date, body, km)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(got), []byte(want)) {
+		return ErrReferenceForged
+	}
+	return nil
+}
+
+func (f ReferenceFormat) mac(date, body string, km KeyManager) (string, error) {
+	sum, err := keyedDigest(km, "transaction-reference", []byte(f.Prefix+"|"+date+"|"+body))
+	if err != nil {
+		return "", err
+	}
+	return crockford.EncodeToString(sum)[:f.MACLen], nil
+}
+
+// NormalizeReference upper-cases a reference typed by a person and folds the
+// characters Crockford base32 treats as look-alikes.
+func NormalizeReference(ref string) string {
+	ref = strings.ToUpper(strings.TrimSpace(ref))
+	ref = strings.ReplaceAll(ref, " ", "")
+	i := strings.IndexByte(ref, '-')
+	if i < 0 {
+		return ref
+	}
+	rest := strings.NewReplacer("O", "0", "I", "1", "L", "1").Replace(ref[i:])
+	return ref[:i] + rest
+}
+
+func luhnMod32(s string) byte {
+	const n = len(crockfordAlphabet)
+	factor := 2
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		code := strings.IndexByte(crockfordAlphabet, s[i])
+		addend := factor * code
+		if factor == 2 {
+			factor = 1
+		} else {
+			factor = 2
+		}
+		sum += addend/n + addend%n
+	}
+	return crockfordAlphabet[(n-sum%n)%n]
+}
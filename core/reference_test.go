@@ -0,0 +1,141 @@
+package core_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	core "payments-core"
+)
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func newTestKeyManager(t testing.TB) *core.InMemoryKeyManager {
+	t.Helper()
+	enc, err := core.GenerateRandomKey(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign, err := core.GenerateRandomKey(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	km := core.NewInMemoryKeyManager(enc, sign)
+	t.Cleanup(km.Destroy)
+	return km
+}
+
+func generateReference(t *testing.T, km core.KeyManager) string {
+	t.Helper()
+	ref, err := core.GenerateReference(km)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := core.ValidateReference(ref, km); err != nil {
+		t.Fatalf("generated reference %s rejected: %v", ref, err)
+	}
+	return ref
+}
+
+// checked replaces the check character of ref with the one that satisfies
+// the mod 32 check, so only the MAC can reject it.
+func checked(t *testing.T, ref string, km core.KeyManager) string {
+	t.Helper()
+	base := ref[:len(ref)-1]
+	for _, c := range crockfordAlphabet {
+		cand := base + string(c)
+		if err := core.ValidateReference(cand, km); !errors.Is(err, core.ErrReferenceChecksum) {
+			return cand
+		}
+	}
+	t.Fatalf("no check character satisfies %s", base)
+	return ""
+}
+
+// checkedPositions are the indexes of ref covered by the check digit: the
+// date, body, MAC and the check character itself.
+func checkedPositions(ref string) []int {
+	var out []int
+	for i := strings.IndexByte(ref, '-') + 1; i < len(ref); i++ {
+		if ref[i] != '-' {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func TestReferenceCheckDigitCatchesTypos(t *testing.T) {
+	km := newTestKeyManager(t)
+	ref := generateReference(t, km)
+	for _, i := range checkedPositions(ref) {
+		for _, c := range crockfordAlphabet {
+			if byte(c) == ref[i] {
+				continue
+			}
+			typo := ref[:i] + string(c) + ref[i+1:]
+			if err := core.ValidateReference(typo, km); !errors.Is(err, core.ErrReferenceChecksum) {
+				t.Errorf("%s (typo at %d of %s): got %v, want %v", typo, i, ref, err, core.ErrReferenceChecksum)
+			}
+		}
+	}
+}
+
+func TestReferenceCheckDigitCatchesTranspositions(t *testing.T) {
+	km := newTestKeyManager(t)
+	for n := 0; n < 20; n++ {
+		ref := generateReference(t, km)
+		for _, i := range checkedPositions(ref) {
+			j := i + 1
+			if j >= len(ref) || ref[j] == '-' || ref[i] == ref[j] {
+				continue
+			}
+			// Like 09 and 90 under Luhn mod 10, swapping the first and last
+			// symbols of the alphabet leaves a Luhn mod 32 sum unchanged.
+			if pair := string([]byte{ref[i], ref[j]}); pair == "0Z" || pair == "Z0" {
+				continue
+			}
+			swapped := ref[:i] + string(ref[j]) + string(ref[i]) + ref[j+1:]
+			if err := core.ValidateReference(swapped, km); !errors.Is(err, core.ErrReferenceChecksum) {
+				t.Errorf("%s (transposed at %d of %s): got %v, want %v", swapped, i, ref, err, core.ErrReferenceChecksum)
+			}
+		}
+	}
+}
+
+func TestValidateReference(t *testing.T) {
+	km := newTestKeyManager(t)
+	other := newTestKeyManager(t)
+	ref := generateReference(t, km)
+	tail := strings.Split(ref, "-")[2]
+	body := tail[:core.DefaultReferenceFormat.BodyLen]
+	flip := func(s string, i int) string {
+		c := crockfordAlphabet[(strings.IndexByte(crockfordAlphabet, s[i])+1)%len(crockfordAlphabet)]
+		return s[:i] + string(c) + s[i+1:]
+	}
+
+	tests := []struct {
+		name string
+		ref  string
+		want error
+	}{
+		{"generated", ref, nil},
+		{"lower case with spaces", " " + strings.ToLower(ref) + " ", nil},
+		{"typo in date", flip(ref, 4), core.ErrReferenceChecksum},
+		{"typo in body", flip(ref, 13), core.ErrReferenceChecksum},
+		{"typo in check digit", flip(ref, len(ref)-1), core.ErrReferenceChecksum},
+		{"missing check digit", ref[:len(ref)-2], core.ErrReferenceFormat},
+		{"wrong prefix", "XYZ" + ref[3:], core.ErrReferenceFormat},
+		{"character outside the alphabet", ref[:13] + "U" + ref[14:], core.ErrReferenceFormat},
+		{"MAC from another key", generateReference(t, other), core.ErrReferenceForged},
+		{"body altered with check digit fixed", checked(t, strings.Replace(ref, body, flip(body, 0), 1), km), core.ErrReferenceForged},
+		{"MAC altered with check digit fixed", checked(t, flip(ref, len(ref)-3), km), core.ErrReferenceForged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := core.ValidateReference(tt.ref, km)
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("ValidateReference(%q) = %v, want %v", tt.ref, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	pb "payments-core/generated"
+)
+
+const maxRESTBody = 64 << 10
+
+type RESTConfig struct {
+	KeyManager KeyManager
+	Policy     MethodPolicy
+	Logger     *slog.Logger
+	// Cards, when set, is served at POST /v1/cards.
+	Cards pb.CardVaultServiceServer
+}
+
+// Problem is an RFC 9457 problem details body. Code and Reason carry the
+// gRPC code and, for core errors, the same ErrorInfo reason gRPC clients see.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type restHandler struct {
+	srv pb.TransferServiceServer
+	cfg RESTConfig
+}
+
+// NewRESTHandler serves the JSON mapping of TransferService:
+//
+//	POST /v1/transfers       TransferRequest    -> 201 TransferResponse
+//	GET  /v1/transfers/{id}  GetTransferRequest -> 200 TransferResponse
+//	GET  /v1/accounts/{id}/balance?max_staleness_ms=N  -> 200 Balance
+//	POST /v1/cards           IssueCardRequest   -> 201 IssuedCard
+//	POST /v1/cards/{id}/cvv  ReissueCVVRequest  -> 200 IssuedCard
+//
+// Requests are authenticated and authorized like the gRPC methods they map
+// to and are handed to srv in process, so validation and error mapping are
+// the gRPC server's own. Mount it under a prefix with http.StripPrefix.
+func NewRESTHandler(srv pb.TransferServiceServer, cfg RESTConfig) http.Handler {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	h := &restHandler{srv: srv, cfg: cfg}
+	mux := http.NewServeMux()
+	mux.Handle("POST /v1/transfers", h.route(pb.TransferService_Transfer_FullMethodName, h.transfer))
+	mux.Handle("GET /v1/transfers/{id}", h.route(pb.TransferService_GetTransfer_FullMethodName, h.getTransfer))
+	mux.Handle("GET /v1/accounts/{id}/balance", h.route(pb.TransferService_GetBalance_FullMethodName, h.getBalance))
+	if cfg.Cards != nil {
+		mux.Handle("POST /v1/cards", h.route(pb.CardVaultService_IssueCard_FullMethodName, h.issueCard))
+		mux.Handle("POST /v1/cards/{id}/cvv", h.route(pb.CardVaultService_ReissueCVV_FullMethodName, h.reissueCVV))
+		mux.HandleFunc("/v1/cards", methodNotAllowed(http.MethodPost))
+		mux.HandleFunc("/v1/cards/{id}/cvv", methodNotAllowed(http.MethodPost))
+	}
+	mux.HandleFunc("/v1/transfers", methodNotAllowed(http.MethodPost))
+	mux.HandleFunc("/v1/transfers/{id}", methodNotAllowed(http.MethodGet))
+	mux.HandleFunc("/v1/accounts/{id}/balance", methodNotAllowed(http.MethodGet))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		WriteProblem(w, status.New(codes.NotFound, "no route for "+r.URL.Path))
+	})
+	return mux
+}
+
+type restCall func(ctx context.Context, w http.ResponseWriter, r *http.Request) (proto.Message, int, error)
+
+func (h *restHandler) route(method string, call restCall) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		code := http.StatusOK
+		var principal string
+		defer func() {
+			if p := recover(); p != nil {
+				h.cfg.Logger.Error("http panic", slog.String("method", method), slog.Any("panic", p), slog.String("stack", string(debug.Stack())))
+				code = WriteProblem(w, status.New(codes.Internal, "internal error"))
+			}
+			h.cfg.Logger.Info("http call",
+				slog.String("method", method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", code),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("principal", principal),
+			)
+		}()
+
+		p, err := h.authenticate(r)
+		if err != nil {
+			code = WriteProblem(w, StatusFromError(err))
+			return
+		}
+		principal = p.Name
+		if !h.cfg.Policy.Allows(method, p.Name) {
+			code = WriteProblem(w, status.Newf(codes.PermissionDenied, "%s may not call %s", p.Name, method))
+			return
+		}
+		ctx := context.WithValue(r.Context(), principalKey{}, p)
+		resp, success, err := call(ctx, w, r)
+		if err != nil {
+			code = WriteProblem(w, StatusFromError(err))
+			return
+		}
+		body, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(resp)
+		if err != nil {
+			code = WriteProblem(w, status.New(codes.Internal, "internal error"))
+			return
+		}
+		code = success
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write(body)
+	})
+}
+
+func (h *restHandler) authenticate(r *http.Request) (Principal, error) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return Principal{Name: certificateName(r.TLS.VerifiedChains[0][0]), Method: AuthMTLS}, nil
+	}
+	return authenticateBearer(r.Header.Values("Authorization"), h.cfg.KeyManager)
+}
+
+func (h *restHandler) transfer(ctx context.Context, w http.ResponseWriter, r *http.Request) (proto.Message, int, error) {
+	req := &pb.TransferRequest{}
+	if err := decodeJSONBody(w, r, req); err != nil {
+		return nil, 0, err
+	}
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	resp, err := h.srv.Transfer(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	w.Header().Set("Location", "/v1/transfers/"+resp.GetTransactionId())
+	return resp, http.StatusCreated, nil
+}
+
+func (h *restHandler) issueCard(ctx context.Context, w http.ResponseWriter, r *http.Request) (proto.Message, int, error) {
+	req := &pb.IssueCardRequest{}
+	if err := decodeJSONBody(w, r, req); err != nil {
+		return nil, 0, err
+	}
+	resp, err := h.cfg.Cards.IssueCard(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	return resp, http.StatusCreated, nil
+}
+
+func (h *restHandler) reissueCVV(ctx context.Context, w http.ResponseWriter, r *http.Request) (proto.Message, int, error) {
+	resp, err := h.cfg.Cards.ReissueCVV(ctx, &pb.ReissueCVVRequest{CardId: r.PathValue("id")})
+	if err != nil {
+		return nil, 0, err
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	return resp, http.StatusOK, nil
+}
+
+func (h *restHandler) getTransfer(ctx context.Context, w http.ResponseWriter, r *http.Request) (proto.Message, int, error) {
+	resp, err := h.srv.GetTransfer(ctx, &pb.GetTransferRequest{TransactionId: r.PathValue("id")})
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp, http.StatusOK, nil
+}
+
+func (h *restHandler) getBalance(ctx context.Context, w http.ResponseWriter, r *http.Request) (proto.Message, int, error) {
+	req := &pb.GetBalanceRequest{AccountId: r.PathValue("id")}
+	if v := r.URL.Query().Get("max_staleness_ms"); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, 0, status.Error(codes.InvalidArgument, "max_staleness_ms must be an integer")
+		}
+		req.MaxStalenessMs = int32(ms)
+	}
+	resp, err := h.srv.GetBalance(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp, http.StatusOK, nil
+}
+
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, m proto.Message) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		return status.Error(codes.InvalidArgument, "content type must be application/json")
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRESTBody))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "request body too large or unreadable")
+	}
+	if err := protojson.Unmarshal(body, m); err != nil {
+		return status.Error(codes.InvalidArgument, "invalid JSON body: "+err.Error())
+	}
+	return nil
+}
+
+func methodNotAllowed(allow string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		p := problemFromStatus(status.New(codes.Unimplemented, r.Method+" is not supported here"))
+		p.Status = http.StatusMethodNotAllowed
+		p.Title = http.StatusText(http.StatusMethodNotAllowed)
+		writeProblem(w, p)
+	}
+}
+
+// WriteProblem writes st as application/problem+json and returns the HTTP
+// status it used.
+func WriteProblem(w http.ResponseWriter, st *status.Status) int {
+	p := problemFromStatus(st)
+	writeProblem(w, p)
+	return p.Status
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+func problemFromStatus(st *status.Status) Problem {
+	code := HTTPStatusFromCode(st.Code())
+	p := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: st.Message(),
+		Code:   st.Code().String(),
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == ErrorDomain {
+			p.Reason = info.Reason
+			p.Type = "urn:" + ErrorDomain + ":" + strings.ToLower(strings.ReplaceAll(info.Reason, "_", "-"))
+		}
+	}
+	return p
+}
+
+// HTTPStatusFromCode follows google/rpc/code.proto, except that
+// FailedPrecondition is a 422 so clients can tell a refused transfer from a
+// malformed request.
+func HTTPStatusFromCode(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.FailedPrecondition:
+		return http.StatusUnprocessableEntity
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTransferNotPending = errors.New("transfer is not pending review")
+	ErrSelfReview         = errors.New("a transfer cannot be reviewed by its initiator")
+)
+
+var reviewSchema = []string{
+	`CREATE TABLE IF NOT EXISTS transfer_reviews (
+		transaction_id  TEXT PRIMARY KEY,
+		from_account_id TEXT NOT NULL,
+		to_account_id   TEXT NOT NULL,
+		hold_id         BIGINT NOT NULL,
+		reasons         TEXT NOT NULL,
+		created_at      TIMESTAMP NOT NULL,
+		decision        TEXT,
+		decided_by      TEXT,
+		note            TEXT,
+		decided_at      TIMESTAMP
+	)`,
+	"CREATE INDEX IF NOT EXISTS transfer_reviews_open_idx ON transfer_reviews (created_at) WHERE decided_at IS NULL",
+	"ALTER TABLE transfer_reviews ADD COLUMN IF NOT EXISTS initiated_by TEXT",
+}
+
+func EnsureReviewSchema(ctx context.Context, db *sql.DB) error {
+	for _, s := range reviewSchema {
+		if _, err := db.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openReview holds the transfer amount on the sender so the funds are still
+// there if a reviewer approves. initiator may not review it.
+func openReview(ctx context.Context, tx *sql.Tx, t *Transaction, fromAccountId, toAccountId, initiator string) error {
+	holdID, err := PlaceHold(ctx, tx, fromAccountId, t.Amount, "review:"+t.ID, time.Time{})
+	if err != nil {
+		return err
+	}
+	var reasons []string
+	if t.Risk != nil {
+		reasons = t.Risk.Reasons
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transfer_reviews (transaction_id, from_account_id, to_account_id, hold_id, reasons, created_at, initiated_by)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+		t.ID, fromAccountId, toAccountId, holdID, strings.Join(reasons, ","), t.CreatedAt, sql.NullString{String: initiator, Valid: initiator != ""},
+	)
+	return err
+}
+
+type openedReview struct {
+	fromAccountID string
+	toAccountID   string
+	holdID        int64
+	t             *Transaction
+}
+
+// claimReview locks an undecided review and releases its hold. reviewer and
+// the recorded initiator are user identities asserted by the calling
+// services; reviews whose initiator was not recorded can only be decided by
+// a named reviewer.
+func claimReview(ctx context.Context, tx *sql.Tx, transactionID, reviewer string) (*openedReview, error) {
+	r := &openedReview{}
+	var initiator sql.NullString
+	err := tx.QueryRowContext(ctx,
+		"SELECT from_account_id, to_account_id, hold_id, initiated_by FROM transfer_reviews WHERE transaction_id=$1 AND decided_at IS NULL FOR UPDATE",
+		transactionID,
+	).Scan(&r.fromAccountID, &r.toAccountID, &r.holdID, &initiator)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := getTransaction(ctx, tx, transactionID, ""); err != nil {
+			return nil, err
+		}
+		return nil, ErrTransferNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reviewer == "" || reviewer == initiator.String {
+		return nil, ErrSelfReview
+	}
+	if r.t, err = getTransaction(ctx, tx, transactionID, "FOR UPDATE"); err != nil {
+		return nil, err
+	}
+	if r.t.Status != StatusPendingReview {
+		return nil, ErrTransferNotPending
+	}
+	if err := ReleaseHold(ctx, tx, r.holdID); err != nil && !errors.Is(err, ErrHoldNotFound) {
+		return nil, err
+	}
+	return r, nil
+}
+
+func closeReview(ctx context.Context, tx *sql.Tx, km KeyManager, r *openedReview, status, reviewer, note string) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE transactions SET status=$1 WHERE id=$2", status, r.t.ID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		"UPDATE transfer_reviews SET decision=$1, decided_by=$2, note=$3, decided_at=$4 WHERE transaction_id=$5",
+		status, reviewer, note, time.Now().UTC(), r.t.ID,
+	)
+	if err != nil {
+		return err
+	}
+	r.t.Status = status
+	return AppendChainLink(ctx, tx, km, r.t)
+}
+
+// ApproveTransfer completes a transfer parked for review. Balances are
+// checked again, since the sender may have spent other funds meanwhile.
+func ApproveTransfer(ctx context.Context, db *sql.DB, kafka *KafkaService, km KeyManager, transactionID, reviewer, note string) (*Transaction, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	r, err := claimReview(ctx, tx, transactionID, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	fromBal, err := lockBalance(ctx, tx, r.fromAccountID, ErrFromAccountNotFound)
+	if err != nil {
+		return nil, err
+	}
+	toBal, err := lockBalance(ctx, tx, r.toAccountID, ErrToAccountNotFound)
+	if err != nil {
+		return nil, err
+	}
+	held, err := heldAmount(ctx, tx, r.fromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if fromBal-held < r.t.Amount {
+		return nil, ErrInsufficientBalance
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE accounts SET balance=$1 WHERE id=$2", fromBal-r.t.Amount, r.fromAccountID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE accounts SET balance=$1 WHERE id=$2", toBal+r.t.Amount, r.toAccountID); err != nil {
+		return nil, err
+	}
+	if err := closeReview(ctx, tx, km, r, StatusCompleted, reviewer, note); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	publishTransferCompleted(kafka, km, r.t, r.fromAccountID, r.toAccountID)
+	return r.t, nil
+}
+
+// lockBalance locks an account row and returns its ledger balance, or
+// notFound if the account is gone.
+func lockBalance(ctx context.Context, tx *sql.Tx, accountID string, notFound error) (int64, error) {
+	var balance string
+	err := tx.QueryRowContext(ctx, "SELECT balance::text FROM accounts WHERE id=$1 FOR UPDATE", accountID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, notFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return parseMinorUnits(balance)
+}
+
+// RejectTransfer releases the held amount and marks the transfer REJECTED.
+func RejectTransfer(ctx context.Context, db *sql.DB, km KeyManager, transactionID, reviewer, note string) (*Transaction, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	r, err := claimReview(ctx, tx, transactionID, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	if err := closeReview(ctx, tx, km, r, StatusRejected, reviewer, note); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.t, nil
+}
@@ -0,0 +1,103 @@
+package core_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	core "payments-core"
+	pb "payments-core/generated"
+)
+
+// pendingReview sets f up with a transfer of 90000 from A to B that is
+// waiting for review, initiated by initiator.
+func pendingReview(f *fakeDB, initiator driver.Value) {
+	at := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	f.on("FROM transfer_reviews WHERE transaction_id",
+		[]string{"from_account_id", "to_account_id", "hold_id", "initiated_by"},
+		[]driver.Value{"acc-a", "acc-b", int64(7), initiator})
+	f.on("FROM transactions WHERE id",
+		[]string{"id", "type", "amount", "description", "status", "reference", "from_account", "to_account", "created_at"},
+		[]driver.Value{"t1", "TRANSFER", "90000.00", "", core.StatusPendingReview, "r1", "A", "B", at})
+	f.on("SELECT COALESCE(SUM(held), 0)", []string{"held"}, []driver.Value{int64(0)})
+	f.on("SELECT seq, hash FROM transaction_chain ORDER BY", []string{"seq", "hash"})
+}
+
+func TestApproveTransfer(t *testing.T) {
+	km := newTestKeyManager(t)
+	dbDown := errors.New("connection reset")
+
+	tests := []struct {
+		name      string
+		initiator driver.Value
+		reviewer  string
+		balance   func(f *fakeDB)
+		want      error
+	}{
+		{name: "approved", initiator: "alice", reviewer: "bob"},
+		{name: "initiator unknown", initiator: nil, reviewer: "bob"},
+		{name: "reviewed by initiator", initiator: "alice", reviewer: "alice", want: core.ErrSelfReview},
+		{name: "no reviewer", initiator: nil, reviewer: "", want: core.ErrSelfReview},
+		{name: "account gone", initiator: "alice", reviewer: "bob", balance: func(f *fakeDB) {
+			f.on("SELECT balance::text FROM accounts", []string{"balance"})
+		}, want: core.ErrFromAccountNotFound},
+		{name: "database error", initiator: "alice", reviewer: "bob", balance: func(f *fakeDB) {
+			f.fail("SELECT balance::text FROM accounts", dbDown)
+		}, want: dbDown},
+		{name: "fractional balance", initiator: "alice", reviewer: "bob", balance: func(f *fakeDB) {
+			f.on("SELECT balance::text FROM accounts", []string{"balance"}, []driver.Value{"100000.50"})
+		}, want: errors.New("fractional amount")},
+		{name: "insufficient balance", initiator: "alice", reviewer: "bob", balance: func(f *fakeDB) {
+			f.on("SELECT balance::text FROM accounts", []string{"balance"}, []driver.Value{"50000.00"})
+		}, want: core.ErrInsufficientBalance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := newFakeDB(t)
+			pendingReview(f, tt.initiator)
+			f.on("SELECT balance::text FROM accounts", []string{"balance"}, []driver.Value{"100000.00"})
+			if tt.balance != nil {
+				tt.balance(f)
+			}
+
+			tr, err := core.ApproveTransfer(context.Background(), db, &core.KafkaService{}, km, "t1", tt.reviewer, "")
+			if tt.want != nil {
+				if err == nil || (!errors.Is(err, tt.want) && err.Error() != tt.want.Error()) {
+					t.Fatalf("got %v, want %v", err, tt.want)
+				}
+				if len(f.execsMatching("UPDATE accounts")) != 0 {
+					t.Fatal("balances were updated")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tr.Status != core.StatusCompleted {
+				t.Fatalf("status %s, want %s", tr.Status, core.StatusCompleted)
+			}
+			moves := f.execsMatching("UPDATE accounts SET balance")
+			if len(moves) != 2 || moves[0].args[0] != int64(10000) || moves[1].args[0] != int64(190000) {
+				t.Fatalf("balance updates %+v, want 10000 for A and 190000 for B", moves)
+			}
+			decided := f.execsMatching("UPDATE transfer_reviews")
+			if len(decided) != 1 || decided[0].args[1] != tt.reviewer {
+				t.Fatalf("review closed with %+v, want decided_by %s", decided, tt.reviewer)
+			}
+		})
+	}
+}
+
+func TestReviewRequiresReviewer(t *testing.T) {
+	db, _ := newFakeDB(t)
+	srv := core.NewTransferServer(db, &core.KafkaService{}, newTestKeyManager(t))
+	_, err := srv.ApproveTransfer(context.Background(), &pb.ReviewTransferRequest{TransactionId: "t1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument", err)
+	}
+}
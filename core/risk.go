@@ -0,0 +1,292 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+type RiskDecision int
+
+const (
+	RiskAllow RiskDecision = iota
+	RiskReview
+	RiskDeny
+)
+
+func (d RiskDecision) String() string {
+	switch d {
+	case RiskAllow:
+		return "allow"
+	case RiskReview:
+		return "review"
+	case RiskDeny:
+		return "deny"
+	}
+	return fmt.Sprintf("RiskDecision(%d)", int(d))
+}
+
+func (d *RiskDecision) UnmarshalText(b []byte) error {
+	switch strings.ToLower(string(b)) {
+	case "allow":
+		*d = RiskAllow
+	case "review":
+		*d = RiskReview
+	case "deny":
+		*d = RiskDeny
+	default:
+		return fmt.Errorf("unknown risk decision %q", b)
+	}
+	return nil
+}
+
+const riskHistoryLimit = 20
+
+type Velocity struct {
+	Count1h   int64
+	Amount1h  int64
+	Count24h  int64
+	Amount24h int64
+}
+
+// RiskInput describes a transfer at the point where funds have been checked
+// but not yet moved. History holds the sender's most recent transactions,
+// newest first; velocity counts outgoing transfers that completed or are
+// waiting for review.
+type RiskInput struct {
+	FromAccountID     string
+	ToAccountID       string
+	FromAccountNumber string
+	ToAccountNumber   string
+	Amount            int64
+	AvailableBalance  int64
+	NewCounterparty   bool
+	Velocity          Velocity
+	History           []Transaction
+}
+
+type RiskResult struct {
+	Decision RiskDecision
+	Reasons  []string
+}
+
+// RiskEvaluator runs inside the transfer's database transaction, before any
+// balance changes. An error fails the transfer.
+type RiskEvaluator interface {
+	Evaluate(ctx context.Context, in RiskInput) (RiskResult, error)
+}
+
+var ErrTransferDenied = errors.New("transfer denied by risk checks")
+
+// RiskDeniedError is returned for denied transfers and matches
+// ErrTransferDenied with errors.Is.
+type RiskDeniedError struct {
+	Reasons []string
+}
+
+func (e *RiskDeniedError) Error() string {
+	if len(e.Reasons) == 0 {
+		return ErrTransferDenied.Error()
+	}
+	return ErrTransferDenied.Error() + ": " + strings.Join(e.Reasons, ", ")
+}
+
+func (e *RiskDeniedError) Unwrap() error {
+	return ErrTransferDenied
+}
+
+func buildRiskInput(ctx context.Context, tx *sql.Tx, from, to Account, amount, available int64) (RiskInput, error) {
+	in := RiskInput{
+		FromAccountID:     from.ID,
+		ToAccountID:       to.ID,
+		FromAccountNumber: from.AccountNumber,
+		ToAccountNumber:   to.AccountNumber,
+		Amount:            amount,
+		AvailableBalance:  available,
+	}
+	now := time.Now().UTC()
+	var amount1h, amount24h string
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE created_at > $2),
+			COALESCE(SUM(amount) FILTER (WHERE created_at > $2), 0)::text,
+			COUNT(*),
+			COALESCE(SUM(amount), 0)::text
+		FROM transactions
+		WHERE from_account=$1 AND created_at > $3 AND status IN ($4, $5)`,
+		from.AccountNumber, now.Add(-time.Hour), now.Add(-24*time.Hour), StatusCompleted, StatusPendingReview,
+	).Scan(&in.Velocity.Count1h, &amount1h, &in.Velocity.Count24h, &amount24h)
+	if err != nil {
+		return in, err
+	}
+	if in.Velocity.Amount1h, err = parseMinorUnits(amount1h); err != nil {
+		return in, err
+	}
+	if in.Velocity.Amount24h, err = parseMinorUnits(amount24h); err != nil {
+		return in, err
+	}
+	var known bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM transactions WHERE from_account=$1 AND to_account=$2 AND status=$3)",
+		from.AccountNumber, to.AccountNumber, StatusCompleted,
+	).Scan(&known)
+	if err != nil {
+		return in, err
+	}
+	in.NewCounterparty = !known
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, type, amount::text, description, status, reference,
+			COALESCE(from_account, ''), COALESCE(to_account, ''), created_at
+		FROM transactions
+		WHERE from_account=$1 OR to_account=$1
+		ORDER BY created_at DESC
+		LIMIT $2`, from.AccountNumber, riskHistoryLimit)
+	if err != nil {
+		return in, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t Transaction
+		var amt string
+		if err := rows.Scan(&t.ID, &t.Type, &amt, &t.Description, &t.Status, &t.Reference, &t.FromAccount, &t.ToAccount, &t.CreatedAt); err != nil {
+			return in, err
+		}
+		if t.Amount, err = parseMinorUnits(amt); err != nil {
+			return in, err
+		}
+		in.History = append(in.History, t)
+	}
+	return in, rows.Err()
+}
+
+// RiskConditions are ANDed; unset conditions always match.
+type RiskConditions struct {
+	AmountGTE            *int64   `json:"amount_gte,omitempty"`
+	Count1hGTE           *int64   `json:"count_1h_gte,omitempty"`
+	Amount1hGTE          *int64   `json:"amount_1h_gte,omitempty"`
+	Count24hGTE          *int64   `json:"count_24h_gte,omitempty"`
+	Amount24hGTE         *int64   `json:"amount_24h_gte,omitempty"`
+	NewCounterparty      *bool    `json:"new_counterparty,omitempty"`
+	NoHistory            *bool    `json:"no_history,omitempty"`
+	BalanceFractionGTE   *float64 `json:"balance_fraction_gte,omitempty"`
+	ToAccountNumbersIn   []string `json:"to_account_numbers_in,omitempty"`
+	FromAccountNumbersIn []string `json:"from_account_numbers_in,omitempty"`
+}
+
+type RiskRule struct {
+	Name     string         `json:"name"`
+	When     RiskConditions `json:"when"`
+	Decision RiskDecision   `json:"decision"`
+}
+
+type RiskRules struct {
+	Rules   []RiskRule   `json:"rules"`
+	Default RiskDecision `json:"default"`
+}
+
+// RuleEvaluator applies every matching rule and returns the most severe
+// decision among them, with the names of the rules that produced it as
+// reasons. When nothing matches, Default applies.
+type RuleEvaluator struct {
+	rules RiskRules
+}
+
+func NewRuleEvaluator(rules RiskRules) (*RuleEvaluator, error) {
+	seen := map[string]bool{}
+	for i, r := range rules.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("risk rule %d has no name", i)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("duplicate risk rule %q", r.Name)
+		}
+		seen[r.Name] = true
+		if f := r.When.BalanceFractionGTE; f != nil && (*f < 0 || *f > 1) {
+			return nil, fmt.Errorf("risk rule %q: balance_fraction_gte must be between 0 and 1", r.Name)
+		}
+	}
+	return &RuleEvaluator{rules: rules}, nil
+}
+
+// LoadRuleEvaluator reads rules from a JSON file such as:
+//
+//	{
+//	  "default": "allow",
+//	  "rules": [
+//	    {"name": "large-new-payee", "when": {"amount_gte": 5000000, "new_counterparty": true}, "decision": "review"},
+//	    {"name": "burst", "when": {"count_1h_gte": 20}, "decision": "deny"}
+//	  ]
+//	}
+func LoadRuleEvaluator(path string) (*RuleEvaluator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseRuleEvaluator(path, data)
+}
+
+//go:embed risk-rules.json
+var defaultRiskRules []byte
+
+// DefaultRuleEvaluator applies the rules in risk-rules.json, which are
+// compiled in and used when no rules file is configured.
+func DefaultRuleEvaluator() (*RuleEvaluator, error) {
+	return parseRuleEvaluator("risk-rules.json", defaultRiskRules)
+}
+
+func parseRuleEvaluator(name string, data []byte) (*RuleEvaluator, error) {
+	var rules RiskRules
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return NewRuleEvaluator(rules)
+}
+
+func (e *RuleEvaluator) Evaluate(ctx context.Context, in RiskInput) (RiskResult, error) {
+	res := RiskResult{Decision: e.rules.Default}
+	matched := false
+	for _, r := range e.rules.Rules {
+		if !r.When.match(in) {
+			continue
+		}
+		switch {
+		case !matched || r.Decision > res.Decision:
+			res = RiskResult{Decision: r.Decision, Reasons: []string{r.Name}}
+			matched = true
+		case r.Decision == res.Decision:
+			res.Reasons = append(res.Reasons, r.Name)
+		}
+	}
+	return res, nil
+}
+
+func (c RiskConditions) match(in RiskInput) bool {
+	gte := func(limit *int64, v int64) bool { return limit == nil || v >= *limit }
+	is := func(want *bool, v bool) bool { return want == nil || v == *want }
+	oneOf := func(list []string, v string) bool { return len(list) == 0 || slices.Contains(list, v) }
+	if c.BalanceFractionGTE != nil {
+		if in.AvailableBalance <= 0 || float64(in.Amount)/float64(in.AvailableBalance) < *c.BalanceFractionGTE {
+			return false
+		}
+	}
+	return gte(c.AmountGTE, in.Amount) &&
+		gte(c.Count1hGTE, in.Velocity.Count1h) &&
+		gte(c.Amount1hGTE, in.Velocity.Amount1h) &&
+		gte(c.Count24hGTE, in.Velocity.Count24h) &&
+		gte(c.Amount24hGTE, in.Velocity.Amount24h) &&
+		is(c.NewCounterparty, in.NewCounterparty) &&
+		is(c.NoHistory, len(in.History) == 0) &&
+		oneOf(c.ToAccountNumbersIn, in.ToAccountNumber) &&
+		oneOf(c.FromAccountNumbersIn, in.FromAccountNumber)
+}
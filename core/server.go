@@ -0,0 +1,282 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "payments-core/generated"
+)
+
+type TransferServer struct {
+	pb.UnimplementedTransferServiceServer
+	db    *sql.DB
+	kafka *KafkaService
+	km    KeyManager
+
+	feed         *FeedNotifier
+	poller       *FeedPoller
+	PollInterval time.Duration
+	// Replica, when set, may serve balance reads that allow staleness.
+	Replica *sql.DB
+	// Risk, when set, screens every transfer before funds move.
+	Risk RiskEvaluator
+}
+
+func NewTransferServer(db *sql.DB, kafka *KafkaService, km KeyManager) *TransferServer {
+	feed := NewFeedNotifier()
+	return &TransferServer{db: db, kafka: kafka, km: km, feed: feed, poller: NewFeedPoller(db, feed), PollInterval: DefaultFeedPollInterval}
+}
+
+func ValidateTransferRequest(req *pb.TransferRequest) error {
+	if req.GetFromAccountId() == "" || req.GetToAccountId() == "" {
+		return status.Error(codes.InvalidArgument, "from_account_id and to_account_id are required")
+	}
+	if req.GetAmount() <= 0 {
+		return StatusFromError(ErrInvalidAmount).Err()
+	}
+	if req.GetFromAccountId() == req.GetToAccountId() {
+		return StatusFromError(ErrSameAccount).Err()
+	}
+	if len(req.GetDescription()) > 255 {
+		return status.Error(codes.InvalidArgument, "description too long")
+	}
+	if len(req.GetIdempotencyKey()) > 255 {
+		return status.Error(codes.InvalidArgument, "idempotency_key too long")
+	}
+	if len(req.GetInitiatedBy()) > 255 {
+		return status.Error(codes.InvalidArgument, "initiated_by too long")
+	}
+	return nil
+}
+
+func (s *TransferServer) Transfer(ctx context.Context, req *pb.TransferRequest) (*pb.TransferResponse, error) {
+	if err := ValidateTransferRequest(req); err != nil {
+		return nil, err
+	}
+	t, err := Transfer(ctx, s.db, s.kafka, s.km, s.Risk, req.GetFromAccountId(), req.GetToAccountId(), req.GetAmount(), req.GetDescription(), req.GetIdempotencyKey(), req.GetInitiatedBy())
+	if err != nil {
+		return nil, StatusFromError(err).Err()
+	}
+	s.feed.Broadcast()
+	return transferResponse(t), nil
+}
+
+func (s *TransferServer) GetTransfer(ctx context.Context, req *pb.GetTransferRequest) (*pb.TransferResponse, error) {
+	if req.GetTransactionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "transaction_id is required")
+	}
+	t, err := GetTransaction(ctx, s.db, req.GetTransactionId())
+	if err != nil {
+		return nil, StatusFromError(err).Err()
+	}
+	return transferResponse(t), nil
+}
+
+func (s *TransferServer) ApproveTransfer(ctx context.Context, req *pb.ReviewTransferRequest) (*pb.TransferResponse, error) {
+	return s.review(ctx, req, func() (*Transaction, error) {
+		return ApproveTransfer(ctx, s.db, s.kafka, s.km, req.GetTransactionId(), req.GetReviewer(), req.GetNote())
+	})
+}
+
+func (s *TransferServer) RejectTransfer(ctx context.Context, req *pb.ReviewTransferRequest) (*pb.TransferResponse, error) {
+	return s.review(ctx, req, func() (*Transaction, error) {
+		return RejectTransfer(ctx, s.db, s.km, req.GetTransactionId(), req.GetReviewer(), req.GetNote())
+	})
+}
+
+func (s *TransferServer) review(ctx context.Context, req *pb.ReviewTransferRequest, decide func() (*Transaction, error)) (*pb.TransferResponse, error) {
+	if req.GetTransactionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "transaction_id is required")
+	}
+	if req.GetReviewer() == "" {
+		return nil, status.Error(codes.InvalidArgument, "reviewer is required")
+	}
+	if len(req.GetReviewer()) > 255 {
+		return nil, status.Error(codes.InvalidArgument, "reviewer too long")
+	}
+	if len(req.GetNote()) > 1000 {
+		return nil, status.Error(codes.InvalidArgument, "note too long")
+	}
+	t, err := decide()
+	if err != nil {
+		return nil, StatusFromError(err).Err()
+	}
+	s.feed.Broadcast()
+	return transferResponse(t), nil
+}
+
+func (s *TransferServer) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb.Balance, error) {
+	if req.GetAccountId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "account_id is required")
+	}
+	if req.GetMaxStalenessMs() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_staleness_ms must not be negative")
+	}
+	balances, err := ReadBalances(ctx, s.db, s.Replica, []string{req.GetAccountId()}, time.Duration(req.GetMaxStalenessMs())*time.Millisecond)
+	if err != nil {
+		return nil, StatusFromError(err).Err()
+	}
+	if len(balances) == 0 {
+		return nil, StatusFromError(ErrAccountNotFound).Err()
+	}
+	return balanceResponse(balances[0]), nil
+}
+
+func (s *TransferServer) GetBalances(ctx context.Context, req *pb.GetBalancesRequest) (*pb.GetBalancesResponse, error) {
+	n := len(req.GetAccountIds())
+	if n == 0 || n > MaxBalanceAccounts {
+		return nil, status.Errorf(codes.InvalidArgument, "between 1 and %d account_ids are required", MaxBalanceAccounts)
+	}
+	if req.GetMaxStalenessMs() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_staleness_ms must not be negative")
+	}
+	balances, err := ReadBalances(ctx, s.db, s.Replica, req.GetAccountIds(), time.Duration(req.GetMaxStalenessMs())*time.Millisecond)
+	if err != nil {
+		return nil, StatusFromError(err).Err()
+	}
+	byID := make(map[string]AccountBalance, len(balances))
+	for _, b := range balances {
+		byID[b.AccountID] = b
+	}
+	resp := &pb.GetBalancesResponse{}
+	seen := map[string]bool{}
+	for _, id := range req.GetAccountIds() {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if b, ok := byID[id]; ok {
+			resp.Balances = append(resp.Balances, balanceResponse(b))
+		} else {
+			resp.MissingAccountIds = append(resp.MissingAccountIds, id)
+		}
+	}
+	return resp, nil
+}
+
+func ValidateWatchRequest(req *pb.WatchTransactionsRequest) error {
+	n := len(req.GetAccountIds())
+	if n == 0 || n > MaxWatchedAccounts {
+		return status.Errorf(codes.InvalidArgument, "between 1 and %d account_ids are required", MaxWatchedAccounts)
+	}
+	replay := time.Duration(req.GetReplaySeconds()) * time.Second
+	if replay < 0 || replay > MaxReplayWindow {
+		return status.Errorf(codes.InvalidArgument, "replay_seconds must be between 0 and %d", int(MaxReplayWindow.Seconds()))
+	}
+	return nil
+}
+
+// WatchTransactions streams transactions touching the requested accounts.
+// Events are read from the database a batch at a time and the next batch is
+// only fetched once the previous one has been sent, so a slow consumer
+// stalls its own stream through gRPC flow control instead of queueing
+// events in memory. After a reconnect, clients pass the cursor of the last
+// event they processed and receive everything after it.
+func (s *TransferServer) WatchTransactions(req *pb.WatchTransactionsRequest, stream grpc.ServerStreamingServer[pb.TransactionEvent]) error {
+	if err := ValidateWatchRequest(req); err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	numbers, err := AccountNumbers(ctx, s.db, req.GetAccountIds())
+	if err != nil {
+		return StatusFromError(err).Err()
+	}
+	if len(numbers) == 0 {
+		return status.Error(codes.NotFound, "no such accounts")
+	}
+	var cursor int64
+	if req.GetCursor() != "" {
+		cursor, err = ParseCursor(req.GetCursor())
+	} else {
+		var since time.Time
+		if req.GetReplaySeconds() > 0 {
+			since = time.Now().Add(-time.Duration(req.GetReplaySeconds()) * time.Second)
+		}
+		cursor, err = FeedStart(ctx, s.db, since)
+	}
+	if err != nil {
+		return StatusFromError(err).Err()
+	}
+
+	defer s.poller.Subscribe(s.PollInterval)()
+	for {
+		wake := s.feed.Wait()
+		head := s.poller.Head()
+		events, err := FetchFeedEvents(ctx, s.db, numbers, cursor, feedBatchSize)
+		if err != nil {
+			return StatusFromError(err).Err()
+		}
+		for _, e := range events {
+			if err := stream.Send(transactionEvent(e)); err != nil {
+				return err
+			}
+			cursor = e.Seq
+		}
+		if len(events) == feedBatchSize {
+			continue
+		}
+		// Every link up to head had committed when it was read, so later
+		// fetches can skip the links that matched none of the accounts.
+		cursor = max(cursor, head)
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-wake:
+		}
+	}
+}
+
+func transferResponse(t *Transaction) *pb.TransferResponse {
+	resp := &pb.TransferResponse{
+		TransactionId: t.ID,
+		Reference:     t.Reference,
+		Status:        t.Status,
+		Amount:        t.Amount,
+		CreatedAt:     t.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Description:   t.Description,
+		FromAccount:   t.FromAccount,
+		ToAccount:     t.ToAccount,
+	}
+	if t.Risk != nil {
+		resp.RiskReasons = t.Risk.Reasons
+	}
+	return resp
+}
+
+func balanceResponse(b AccountBalance) *pb.Balance {
+	out := &pb.Balance{
+		AccountId:         b.AccountID,
+		AccountNumber:     b.AccountNumber,
+		LedgerBalance:     b.Ledger,
+		AvailableBalance:  b.Available(),
+		HeldAmount:        b.Held,
+		Currency:          b.Currency,
+		AsOfTransactionId: b.AsOfTransactionID,
+		FromReplica:       b.FromReplica,
+		StalenessMs:       b.Staleness.Milliseconds(),
+	}
+	if b.AsOfSeq > 0 {
+		out.AsOfCursor = FormatCursor(b.AsOfSeq)
+	}
+	return out
+}
+
+func transactionEvent(e FeedEvent) *pb.TransactionEvent {
+	return &pb.TransactionEvent{
+		Cursor:        FormatCursor(e.Seq),
+		TransactionId: e.ID,
+		Reference:     e.Reference,
+		Type:          e.Type,
+		Status:        e.Status,
+		Amount:        e.Amount,
+		Description:   e.Description,
+		FromAccount:   e.FromAccount,
+		ToAccount:     e.ToAccount,
+		CreatedAt:     e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const AlgEdDSA = "EdDSA"
+
+var (
+	ErrUnknownKeyID  = errors.New("unknown signing key id")
+	ErrNoEventSigner = errors.New("no asymmetric event signing key")
+)
+
+// AsymmetricKeyManager is implemented by key managers that can sign events
+// with a private key, so verifiers only ever need the public half. The
+// private key never leaves the manager.
+type AsymmetricKeyManager interface {
+	KeyManager
+	// SignEd25519 signs payload with the active key and returns that key's
+	// id, or ErrNoEventSigner when the manager has no event key.
+	SignEd25519(payload []byte) (kid string, sig []byte, err error)
+	PublicKeySet() (*JWKS, error)
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type EventSignature struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Sig string `json:"sig"`
+}
+
+type edKey struct {
+	kid  string
+	priv ed25519.PrivateKey
+}
+
+type asymKeys struct {
+	mu   sync.RWMutex
+	keys []edKey
+}
+
+func GenerateEd25519Key() (ed25519.PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	return priv, err
+}
+
+func Ed25519KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// AddEd25519Key makes priv the active event signing key. Earlier keys stay in
+// the published key set so events signed before a rotation still verify.
+func (m *InMemoryKeyManager) AddEd25519Key(priv ed25519.PrivateKey) (string, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return "", errors.New("invalid ed25519 key")
+	}
+	kid := Ed25519KeyID(priv.Public().(ed25519.PublicKey))
+	m.asym.mu.Lock()
+	defer m.asym.mu.Unlock()
+	m.asym.keys = append(m.asym.keys, edKey{kid: kid, priv: ed25519.PrivateKey(cloneBytes(priv))})
+	return kid, nil
+}
+
+func (m *InMemoryKeyManager) SignEd25519(payload []byte) (string, []byte, error) {
+	m.asym.mu.RLock()
+	defer m.asym.mu.RUnlock()
+	if len(m.asym.keys) == 0 {
+		return "", nil, ErrNoEventSigner
+	}
+	k := m.asym.keys[len(m.asym.keys)-1]
+	return k.kid, ed25519.Sign(k.priv, payload), nil
+}
+
+func (m *InMemoryKeyManager) PublicKeySet() (*JWKS, error) {
+	m.asym.mu.RLock()
+	defer m.asym.mu.RUnlock()
+	set := &JWKS{Keys: make([]JWK, 0, len(m.asym.keys))}
+	for _, k := range m.asym.keys {
+		set.Keys = append(set.Keys, publicJWK(k.kid, k.priv.Public().(ed25519.PublicKey)))
+	}
+	return set, nil
+}
+
+func publicJWK(kid string, pub ed25519.PublicKey) JWK {
+	return JWK{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub), Kid: kid, Alg: AlgEdDSA, Use: "sig"}
+}
+
+func (s *JWKS) Lookup(kid string) (ed25519.PublicKey, error) {
+	for _, k := range s.Keys {
+		if k.Kid != kid {
+			continue
+		}
+		if k.Kty != "OKP" || k.Crv != "Ed25519" {
+			return nil, errors.New("unsupported key type")
+		}
+		pub, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(pub) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+		return ed25519.PublicKey(pub), nil
+	}
+	return nil, ErrUnknownKeyID
+}
+
+// SignEvent signs with the active Ed25519 key. There is no shared-secret
+// fallback: consumers only accept EdDSA, so without a key it fails.
+func SignEvent(payload []byte, km KeyManager) (*EventSignature, error) {
+	akm, ok := km.(AsymmetricKeyManager)
+	if !ok {
+		return nil, ErrNoEventSigner
+	}
+	kid, sig, err := akm.SignEd25519(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &EventSignature{Alg: AlgEdDSA, Kid: kid, Sig: base64.RawURLEncoding.EncodeToString(sig)}, nil
+}
+
+// ParseEd25519Key accepts a standard base64 Ed25519 seed or private key.
+func ParseEd25519Key(s string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ed25519 key: %w", err)
+	}
+	defer wipe(raw)
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(cloneBytes(raw)), nil
+	}
+	return nil, errors.New("invalid ed25519 key")
+}
+
+func VerifyEventSignature(payload []byte, sig *EventSignature, keys *JWKS) (bool, error) {
+	if sig == nil || sig.Alg != AlgEdDSA {
+		return false, errors.New("unsupported signature algorithm")
+	}
+	pub, err := keys.Lookup(sig.Kid)
+	if err != nil {
+		return false, err
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(sig.Sig)
+	if err != nil {
+		return false, err
+	}
+	return ed25519.Verify(pub, payload, raw), nil
+}
+
+func JWKSHandler(km AsymmetricKeyManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set, err := km.PublicKeySet()
+		if err != nil {
+			http.Error(w, "key set unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(set)
+	})
+}
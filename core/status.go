@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const ErrorDomain = "payments-core"
+
+type errorMapping struct {
+	err    error
+	code   codes.Code
+	reason string
+}
+
+// errorTable is shared by the gRPC server, which turns core errors into
+// statuses, and by clients, which turn statuses back into the same errors.
+var errorTable = []errorMapping{
+	{ErrFromAccountNotFound, codes.NotFound, "FROM_ACCOUNT_NOT_FOUND"},
+	{ErrToAccountNotFound, codes.NotFound, "TO_ACCOUNT_NOT_FOUND"},
+	{ErrAccountNotFound, codes.NotFound, "ACCOUNT_NOT_FOUND"},
+	{ErrInvalidHold, codes.InvalidArgument, "HOLD_INVALID"},
+	{ErrHoldNotFound, codes.NotFound, "HOLD_NOT_FOUND"},
+	{ErrTransferNotFound, codes.NotFound, "TRANSFER_NOT_FOUND"},
+	{ErrInsufficientBalance, codes.FailedPrecondition, "INSUFFICIENT_BALANCE"},
+	{ErrTransferDenied, codes.FailedPrecondition, "TRANSFER_DENIED"},
+	{ErrTransferNotPending, codes.FailedPrecondition, "TRANSFER_NOT_PENDING"},
+	{ErrSelfReview, codes.PermissionDenied, "SELF_REVIEW"},
+	{ErrIdempotencyKeyReused, codes.FailedPrecondition, "IDEMPOTENCY_KEY_REUSED"},
+	{ErrInvalidAmount, codes.InvalidArgument, "INVALID_AMOUNT"},
+	{ErrSameAccount, codes.InvalidArgument, "SAME_ACCOUNT"},
+	{ErrReferenceFormat, codes.InvalidArgument, "REFERENCE_MALFORMED"},
+	{ErrReferenceChecksum, codes.InvalidArgument, "REFERENCE_CHECKSUM"},
+	{ErrReferenceForged, codes.InvalidArgument, "REFERENCE_FORGED"},
+	{ErrInvalidCursor, codes.InvalidArgument, "CURSOR_INVALID"},
+	{ErrInvalidExpiry, codes.InvalidArgument, "CARD_EXPIRY_INVALID"},
+	{ErrInvalidPAN, codes.InvalidArgument, "CARD_NUMBER_INVALID"},
+	{ErrInvalidCVV, codes.PermissionDenied, "CARD_CVV_INVALID"},
+	{ErrCardInactive, codes.FailedPrecondition, "CARD_INACTIVE"},
+	{ErrCardUnknown, codes.NotFound, "CARD_NOT_FOUND"},
+	{ErrCardNotMigrated, codes.FailedPrecondition, "CARD_NOT_MIGRATED"},
+	{ErrVaultUnavailable, codes.Unavailable, "KEY_MANAGER_UNAVAILABLE"},
+}
+
+func StatusFromError(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.New(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.New(codes.Canceled, err.Error())
+	}
+	for _, m := range errorTable {
+		if errors.Is(err, m.err) {
+			st := status.New(m.code, m.err.Error())
+			if d, derr := st.WithDetails(&errdetails.ErrorInfo{Reason: m.reason, Domain: ErrorDomain}); derr == nil {
+				return d
+			}
+			return st
+		}
+	}
+	return status.New(codes.Internal, "internal error")
+}
+
+// ErrorFromStatus returns the core error a status was built from, or nil if
+// the status carries no payments-core reason.
+func ErrorFromStatus(st *status.Status) error {
+	if st == nil {
+		return nil
+	}
+	for _, d := range st.Details() {
+		info, ok := d.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != ErrorDomain {
+			continue
+		}
+		for _, m := range errorTable {
+			if m.reason == info.Reason {
+				return m.err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package core
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Stream format:
+//
+//	header: magic "SBS1" | chunk size (uint32 BE) | salt (16 bytes)
+//	chunks: AES-256-GCM(chunk) with nonce = counter (uint64 BE, 11 bytes) | final flag
+//
+// Each stream encrypts under a subkey derived from the KeyManager key and the
+// salt, and the header is bound to every chunk as additional data. The final
+// flag in the last nonce means a stream cut at a chunk boundary fails to open.
+//
+// Key managers that never release keys (RemoteCrypto) write magic "SBS2" and
+// append a wrapped data key (uint16 BE length | key) to the header; the
+// subkey is then derived from the unwrapped data key instead.
+
+const (
+	streamMagic            = "SBS1"
+	streamMagicWrapped     = "SBS2"
+	streamSaltSize         = 16
+	streamHeaderSize       = len(streamMagic) + 4 + streamSaltSize
+	DefaultStreamChunkSize = 64 * 1024
+	maxStreamChunkSize     = 16 * 1024 * 1024
+)
+
+var (
+	ErrStreamTruncated = errors.New("encrypted stream truncated")
+	ErrStreamCorrupt   = errors.New("encrypted stream corrupt")
+)
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	size    int
+	counter uint64
+	closed  bool
+	err     error
+}
+
+func NewEncryptWriter(w io.Writer, km KeyManager) (io.WriteCloser, error) {
+	return NewEncryptWriterSize(w, km, DefaultStreamChunkSize)
+}
+
+func NewEncryptWriterSize(w io.Writer, km KeyManager, chunkSize int) (io.WriteCloser, error) {
+	if chunkSize <= 0 || chunkSize > maxStreamChunkSize {
+		return nil, errors.New("invalid chunk size")
+	}
+	header := make([]byte, streamHeaderSize)
+	copy(header, streamMagic)
+	binary.BigEndian.PutUint32(header[len(streamMagic):], uint32(chunkSize))
+	salt := header[len(streamMagic)+4:]
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	var aead cipher.AEAD
+	var err error
+	if rc, ok := km.(RemoteCrypto); ok {
+		dataKey, wrapped, err := rc.GenerateDataKey()
+		if err != nil {
+			return nil, err
+		}
+		if len(wrapped) > 0xffff {
+			wipe(dataKey)
+			return nil, errors.New("wrapped data key too large")
+		}
+		copy(header, streamMagicWrapped)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+		header = append(header, wrapped...)
+		aead, err = streamAEADFromKey(dataKey, salt)
+		wipe(dataKey)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		aead, err = streamAEAD(km, salt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, header: header, buf: make([]byte, 0, chunkSize), size: chunkSize}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+	n := 0
+	for len(p) > 0 {
+		// A full buffer is only sealed once more data arrives, so the last
+		// chunk is always the one sealed by Close with the final flag.
+		if len(e.buf) == e.size {
+			if e.err = e.seal(false); e.err != nil {
+				return n, e.err
+			}
+		}
+		k := copy(e.buf[len(e.buf):e.size], p)
+		e.buf = e.buf[:len(e.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if e.err != nil {
+		return e.err
+	}
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(final bool) error {
+	nonce := streamNonce(e.counter, final)
+	out := e.aead.Seal(nil, nonce, e.buf, e.header)
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(out)
+	return err
+}
+
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	chunk   []byte
+	plain   []byte
+	counter uint64
+	done    bool
+	err     error
+}
+
+func NewDecryptReader(r io.Reader, km KeyManager) (io.Reader, error) {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrStreamCorrupt
+	}
+	magic := string(header[:len(streamMagic)])
+	if magic != streamMagic && magic != streamMagicWrapped {
+		return nil, ErrStreamCorrupt
+	}
+	size := int(binary.BigEndian.Uint32(header[len(streamMagic):]))
+	if size <= 0 || size > maxStreamChunkSize {
+		return nil, ErrStreamCorrupt
+	}
+	salt := header[len(streamMagic)+4:]
+	var aead cipher.AEAD
+	var err error
+	if magic == streamMagicWrapped {
+		rc, ok := km.(RemoteCrypto)
+		if !ok {
+			return nil, errors.New("stream requires a remote key manager")
+		}
+		var n [2]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return nil, ErrStreamCorrupt
+		}
+		wrapped := make([]byte, binary.BigEndian.Uint16(n[:]))
+		if _, err := io.ReadFull(r, wrapped); err != nil {
+			return nil, ErrStreamCorrupt
+		}
+		dataKey, err := rc.UnwrapDataKey(string(wrapped))
+		if err != nil {
+			return nil, err
+		}
+		aead, err = streamAEADFromKey(dataKey, salt)
+		wipe(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		header = append(append(header, n[:]...), wrapped...)
+	} else {
+		aead, err = streamAEAD(km, salt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sealed := size + aead.Overhead()
+	return &decryptReader{r: bufio.NewReaderSize(r, sealed+1), aead: aead, header: header, chunk: make([]byte, sealed)}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	n, err := io.ReadFull(d.r, d.chunk)
+	if err == io.EOF {
+		return ErrStreamTruncated
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	final := n < len(d.chunk)
+	if !final {
+		if _, err := d.r.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+	plain, err := d.aead.Open(d.chunk[:0], streamNonce(d.counter, final), d.chunk[:n], d.header)
+	if err != nil {
+		if final {
+			return ErrStreamTruncated
+		}
+		return ErrStreamCorrupt
+	}
+	d.counter++
+	d.plain = plain
+	d.done = final
+	return nil
+}
+
+func streamNonce(counter uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func streamAEAD(km KeyManager, salt []byte) (cipher.AEAD, error) {
+	var aead cipher.AEAD
+	err := withEncryptionKey(km, func(key []byte) error {
+		var err error
+		aead, err = streamAEADFromKey(key, salt)
+		return err
+	})
+	return aead, err
+}
+
+func streamAEADFromKey(key, salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("stream-chunk-key"))
+	mac.Write(salt)
+	sub := mac.Sum(nil)
+	defer wipe(sub)
+	return newGCM(sub)
+}
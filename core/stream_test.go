@@ -0,0 +1,146 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	core "payments-core"
+	"payments-core/transitfake"
+)
+
+const testChunkSize = 1024
+
+// sealedStream is an encrypted stream split into its header and sealed
+// chunks, so tests can rearrange them.
+type sealedStream struct {
+	header []byte
+	chunks [][]byte
+}
+
+func (s sealedStream) bytes() []byte {
+	out := append([]byte(nil), s.header...)
+	for _, c := range s.chunks {
+		out = append(out, c...)
+	}
+	return out
+}
+
+func sealStream(t *testing.T, km core.KeyManager, data []byte) sealedStream {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := core.NewEncryptWriterSize(&buf, km, testChunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+	n := 24
+	if string(raw[:4]) == "SBS2" {
+		n += 2 + int(binary.BigEndian.Uint16(raw[24:]))
+	}
+	s := sealedStream{header: raw[:n]}
+	for rest, sealed := raw[n:], testChunkSize+16; len(rest) > 0; {
+		k := min(sealed, len(rest))
+		s.chunks = append(s.chunks, rest[:k])
+		rest = rest[k:]
+	}
+	return s
+}
+
+func openStream(km core.KeyManager, b []byte) ([]byte, error) {
+	r, err := core.NewDecryptReader(bytes.NewReader(b), km)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncryptedStream(t *testing.T) {
+	fake := transitfake.NewServer()
+	t.Cleanup(fake.Close)
+	managers := []struct {
+		name string
+		km   core.KeyManager
+	}{
+		{"in memory", newTestKeyManager(t)},
+		{"vault", newTestVault(t, fake.URL, fake, "")},
+	}
+	data := bytes.Repeat([]byte("0123456789abcdef"), testChunkSize*7/32)
+
+	for _, m := range managers {
+		t.Run(m.name, func(t *testing.T) {
+			s := sealStream(t, m.km, data)
+			if len(s.chunks) != 4 {
+				t.Fatalf("got %d chunks, want 4", len(s.chunks))
+			}
+			got, err := openStream(m.km, s.bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("plaintext mismatch")
+			}
+
+			tests := []struct {
+				name   string
+				stream func() []byte
+				want   error
+			}{
+				{"final chunk dropped", func() []byte {
+					return sealedStream{s.header, s.chunks[:3]}.bytes()
+				}, core.ErrStreamTruncated},
+				{"all chunks dropped", func() []byte {
+					return s.header
+				}, core.ErrStreamTruncated},
+				{"cut inside a chunk", func() []byte {
+					b := s.bytes()
+					return b[:len(s.header)+testChunkSize]
+				}, core.ErrStreamTruncated},
+				{"chunks reordered", func() []byte {
+					return sealedStream{s.header, [][]byte{s.chunks[1], s.chunks[0], s.chunks[2], s.chunks[3]}}.bytes()
+				}, core.ErrStreamCorrupt},
+				{"chunk replayed", func() []byte {
+					return sealedStream{s.header, [][]byte{s.chunks[0], s.chunks[0], s.chunks[2], s.chunks[3]}}.bytes()
+				}, core.ErrStreamCorrupt},
+				{"middle chunks dropped", func() []byte {
+					return sealedStream{s.header, [][]byte{s.chunks[0], s.chunks[3]}}.bytes()
+				}, core.ErrStreamTruncated},
+				{"chunk from another stream", func() []byte {
+					other := sealStream(t, m.km, data)
+					return sealedStream{s.header, [][]byte{s.chunks[0], other.chunks[1], s.chunks[2], s.chunks[3]}}.bytes()
+				}, core.ErrStreamCorrupt},
+				{"ciphertext byte flipped", func() []byte {
+					b := s.bytes()
+					b[len(s.header)+10] ^= 1
+					return b
+				}, core.ErrStreamCorrupt},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					_, err := openStream(m.km, tt.stream())
+					if !errors.Is(err, tt.want) {
+						t.Fatalf("got %v, want %v", err, tt.want)
+					}
+				})
+			}
+
+			// Every header byte is either parsed or bound to the chunks as
+			// additional data, so flipping any of them must fail.
+			for i := range s.header {
+				b := s.bytes()
+				b[i] ^= 0x40
+				if _, err := openStream(m.km, b); err == nil {
+					t.Errorf("header byte %d flipped: stream still opened", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"crypto/tls"
+	"errors"
+
+	"payments-core/tlsreload"
+)
+
+var ErrClientCARequired = errors.New("tls: a client CA bundle is required")
+
+// ServerTLSConfig requires every client to present a certificate signed by
+// the CA bundle in clientCAFile; serving TLS without one is refused, since
+// principals come from client certificates. Both the key pair and the
+// bundle reload.
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if clientCAFile == "" {
+		return nil, ErrClientCARequired
+	}
+	return tlsreload.ServerConfig(certFile, keyFile, clientCAFile)
+}
+
+// HTTPServerTLSConfig is ServerTLSConfig for the REST and JWKS listener.
+// A client certificate is verified against the bundle when one is presented
+// but not required, since REST callers may authenticate with a bearer
+// token and the JWKS is public.
+func HTTPServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if clientCAFile == "" {
+		return nil, ErrClientCARequired
+	}
+	return tlsreload.ServerConfigClientAuth(certFile, keyFile, clientCAFile, tls.VerifyClientCertIfGiven)
+}
+
+// ClientTLSConfig verifies the server against caFile and, when certFile is
+// set, presents a client certificate for mutual TLS.
+func ClientTLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	return tlsreload.ClientConfig(caFile, certFile, keyFile, serverName)
+}
@@ -0,0 +1,274 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	core "payments-core"
+	pb "payments-core/generated"
+	"payments-core/tlsreload/tlstest"
+)
+
+type principalEcho struct {
+	pb.UnimplementedTransferServiceServer
+}
+
+func (principalEcho) Transfer(ctx context.Context, _ *pb.TransferRequest) (*pb.TransferResponse, error) {
+	p, _ := core.PrincipalFromContext(ctx)
+	return &pb.TransferResponse{Reference: p.Name}, nil
+}
+
+func TestServerTLSConfigRequiresClientCA(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewAuthority(t, "test-ca")
+	cert, key := ca.Issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	os.WriteFile(filepath.Join(dir, "server.pem"), cert, 0o600)
+	os.WriteFile(filepath.Join(dir, "server.key"), key, 0o600)
+	_, err := core.ServerTLSConfig(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"), "")
+	if !errors.Is(err, core.ErrClientCARequired) {
+		t.Fatalf("expected ErrClientCARequired, got %v", err)
+	}
+}
+
+// TestMutualTLS runs a real gRPC server: mutual TLS succeeds, missing or
+// foreign client certificates are refused, and rotated files are picked up
+// without restarting either side.
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ca := tlstest.NewAuthority(t, "test-ca")
+	rogue := tlstest.NewAuthority(t, "rogue-ca")
+	srvCert, srvKey := ca.Issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	cliCert, cliKey := ca.Issue(t, "gateway", x509.ExtKeyUsageClientAuth)
+	rogueCert, rogueKey := rogue.Issue(t, "gateway", x509.ExtKeyUsageClientAuth)
+	for name, data := range map[string][]byte{
+		"ca.pem": ca.PEM, "server.pem": srvCert, "server.key": srvKey,
+		"client.pem": cliCert, "client.key": cliKey, "rogue.pem": rogueCert, "rogue.key": rogueKey,
+	} {
+		if err := os.WriteFile(path(name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serverTLS, err := core.ServerTLSConfig(path("server.pem"), path("server.key"), path("ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	km := core.NewInMemoryKeyManager(make([]byte, 32), make([]byte, 32))
+	opts := core.ServerInterceptors(core.InterceptorConfig{KeyManager: km, Policy: core.MethodPolicy{"*": {"gateway"}}})
+	srv := grpc.NewServer(append(opts, grpc.Creds(credentials.NewTLS(serverTLS)))...)
+	pb.RegisterTransferServiceServer(srv, principalEcho{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	call := func(certFile, keyFile string) (string, error) {
+		cfg, err := core.ClientTLSConfig(path("ca.pem"), certFile, keyFile, "localhost")
+		if err != nil {
+			return "", err
+		}
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(cfg)))
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		resp, err := pb.NewTransferServiceClient(conn).Transfer(ctx, &pb.TransferRequest{})
+		if err != nil {
+			return "", err
+		}
+		return resp.GetReference(), nil
+	}
+	refused := func(t *testing.T, err error) {
+		t.Helper()
+		if err == nil {
+			t.Fatal("call succeeded")
+		}
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected a handshake failure, got %v", err)
+		}
+	}
+
+	t.Run("trusted client certificate", func(t *testing.T) {
+		name, err := call(path("client.pem"), path("client.key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != "gateway" {
+			t.Fatalf("principal %q, want gateway", name)
+		}
+	})
+	t.Run("client without a certificate is refused", func(t *testing.T) {
+		_, err := call("", "")
+		refused(t, err)
+	})
+	t.Run("client certificate from another CA is refused", func(t *testing.T) {
+		_, err := call(path("rogue.pem"), path("rogue.key"))
+		refused(t, err)
+	})
+	t.Run("reloaded client CA bundle is honoured", func(t *testing.T) {
+		tlstest.Replace(t, path("ca.pem"), append(append([]byte{}, ca.PEM...), rogue.PEM...))
+		time.Sleep(1100 * time.Millisecond)
+		if _, err := call(path("rogue.pem"), path("rogue.key")); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("rotated server certificate is served", func(t *testing.T) {
+		rotated, rotatedKey := ca.Issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+		tlstest.Replace(t, path("server.key"), rotatedKey)
+		tlstest.Replace(t, path("server.pem"), rotated)
+		time.Sleep(1100 * time.Millisecond)
+		serverPresents(t, lis.Addr().String(), rotated, path("client.pem"), path("client.key"))
+
+		// A broken replacement is logged and the rotated pair kept.
+		tlstest.Replace(t, path("server.pem"), []byte("not a certificate"))
+		time.Sleep(1100 * time.Millisecond)
+		serverPresents(t, lis.Addr().String(), rotated, path("client.pem"), path("client.key"))
+	})
+}
+
+func serverPresents(t *testing.T, addr string, want []byte, certFile, keyFile string) {
+	t.Helper()
+	block, _ := pem.Decode(want)
+	cfg, err := core.ClientTLSConfig("", certFile, keyFile, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.InsecureSkipVerify = true
+	cfg.NextProtos = []string{"h2"}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 2 * time.Second}, "tcp", addr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 || !bytes.Equal(certs[0].Raw, block.Bytes) {
+		t.Fatal("server does not present the expected certificate")
+	}
+}
+
+// TestHTTPServerTLS checks that the REST listener verifies client
+// certificates when presented but also serves callers that authenticate
+// with a bearer token instead.
+func TestHTTPServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ca := tlstest.NewAuthority(t, "test-ca")
+	rogue := tlstest.NewAuthority(t, "rogue-ca")
+	srvCert, srvKey := ca.Issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	cliCert, cliKey := ca.Issue(t, "gateway", x509.ExtKeyUsageClientAuth)
+	rogueCert, rogueKey := rogue.Issue(t, "gateway", x509.ExtKeyUsageClientAuth)
+	for name, data := range map[string][]byte{
+		"ca.pem": ca.PEM, "server.pem": srvCert, "server.key": srvKey,
+		"client.pem": cliCert, "client.key": cliKey, "rogue.pem": rogueCert, "rogue.key": rogueKey,
+	} {
+		if err := os.WriteFile(path(name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serverTLS, err := core.HTTPServerTLSConfig(path("server.pem"), path("server.key"), path("ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	km := newTestKeyManager(t)
+	token, err := core.IssueServiceToken(km, "card-service", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler:   core.NewRESTHandler(principalEcho{}, core.RESTConfig{KeyManager: km, Policy: core.MethodPolicy{"*": {"gateway", "card-service"}}}),
+		TLSConfig: serverTLS,
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeTLS(lis, "", "")
+	t.Cleanup(func() { srv.Close() })
+
+	post := func(certFile, keyFile, bearer string) (*http.Response, error) {
+		cfg, err := core.ClientTLSConfig(path("ca.pem"), certFile, keyFile, "localhost")
+		if err != nil {
+			return nil, err
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 2 * time.Second}
+		req, _ := http.NewRequest(http.MethodPost, "https://"+lis.Addr().String()+"/v1/transfers", strings.NewReader(`{}`))
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		return client.Do(req)
+	}
+	principal := func(t *testing.T, resp *http.Response) string {
+		t.Helper()
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+		var body struct {
+			Reference string `json:"reference"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Reference
+	}
+
+	t.Run("bearer token without a certificate", func(t *testing.T) {
+		resp, err := post("", "", token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name := principal(t, resp); name != "card-service" {
+			t.Fatalf("principal %q, want card-service", name)
+		}
+	})
+	t.Run("trusted client certificate", func(t *testing.T) {
+		resp, err := post(path("client.pem"), path("client.key"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name := principal(t, resp); name != "gateway" {
+			t.Fatalf("principal %q, want gateway", name)
+		}
+	})
+	t.Run("neither is unauthenticated", func(t *testing.T) {
+		resp, err := post("", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("status %d, want 401", resp.StatusCode)
+		}
+	})
+	t.Run("certificate from another CA is refused", func(t *testing.T) {
+		if resp, err := post(path("rogue.pem"), path("rogue.key"), token); err == nil {
+			resp.Body.Close()
+			t.Fatal("handshake with an untrusted certificate succeeded")
+		}
+	})
+}
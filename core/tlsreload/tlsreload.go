@@ -0,0 +1,207 @@
+// Package tlsreload builds TLS configs whose key pairs and CA bundles are
+// re-read from disk when the files change, so certificates rotate without a
+// restart. It is shared by the core server and the gateway.
+package tlsreload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const checkInterval = time.Second
+
+// CertReloader serves a key pair from disk. Files are stat'ed at most once
+// per second; a failed reload is logged and the previous pair kept.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) load() error {
+	mod := latestModTime(r.certFile, r.keyFile)
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = mod
+	return nil
+}
+
+func (r *CertReloader) current() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) >= checkInterval {
+		r.checkedAt = time.Now()
+		if mod := latestModTime(r.certFile, r.keyFile); mod.After(r.modTime) {
+			if err := r.load(); err != nil {
+				reloadFailed(r.certFile, mod, &r.modTime, err)
+			}
+		}
+	}
+	return r.cert
+}
+
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.current(), nil
+}
+
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.current(), nil
+}
+
+// CAPoolReloader is the CA bundle counterpart of CertReloader.
+type CAPoolReloader struct {
+	file string
+
+	mu        sync.Mutex
+	pool      *x509.CertPool
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func NewCAPoolReloader(file string) (*CAPoolReloader, error) {
+	r := &CAPoolReloader{file: file}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CAPoolReloader) load() error {
+	mod := latestModTime(r.file)
+	pem, err := os.ReadFile(r.file)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("no certificates found in " + r.file)
+	}
+	r.pool = pool
+	r.modTime = mod
+	return nil
+}
+
+func (r *CAPoolReloader) Pool() *x509.CertPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) >= checkInterval {
+		r.checkedAt = time.Now()
+		if mod := latestModTime(r.file); mod.After(r.modTime) {
+			if err := r.load(); err != nil {
+				reloadFailed(r.file, mod, &r.modTime, err)
+			}
+		}
+	}
+	return r.pool
+}
+
+// reloadFailed logs once per change of the files: the failed modification
+// time is remembered, so a broken file is not re-read every second.
+func reloadFailed(file string, mod time.Time, seen *time.Time, err error) {
+	*seen = mod
+	slog.Default().Error("tls reload failed; keeping the previous certificates",
+		slog.String("file", file), slog.Any("error", err))
+}
+
+// ServerConfig serves the reloading key pair. With clientCAFile set, every
+// client must present a certificate signed by that bundle.
+func ServerConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	return ServerConfigClientAuth(certFile, keyFile, clientCAFile, tls.RequireAndVerifyClientCert)
+}
+
+// ServerConfigClientAuth is ServerConfig with the client certificate policy
+// given by auth, which applies only with clientCAFile set.
+func ServerConfigClientAuth(certFile, keyFile, clientCAFile string, auth tls.ClientAuthType) (*tls.Config, error) {
+	certs, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	base := &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certs.GetCertificate}
+	if clientCAFile == "" {
+		return base, nil
+	}
+	cas, err := NewCAPoolReloader(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	base.ClientAuth = auth
+	base.ClientCAs = cas.Pool()
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientCAs = cas.Pool()
+		return cfg, nil
+	}
+	return base, nil
+}
+
+// ClientConfig verifies the server against caFile and, when certFile is
+// set, presents a client certificate for mutual TLS.
+func ClientConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	if caFile != "" {
+		cas, err := NewCAPoolReloader(caFile)
+		if err != nil {
+			return nil, err
+		}
+		// RootCAs would be fixed for the life of the config, so the default
+		// verification is replaced with one against the current bundle.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPeer(cs, cas.Pool(), serverName)
+		}
+	}
+	if certFile != "" {
+		certs, err := NewCertReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = certs.GetClientCertificate
+	}
+	return cfg, nil
+}
+
+func verifyPeer(cs tls.ConnectionState, roots *x509.CertPool, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: no peer certificate")
+	}
+	if serverName == "" {
+		serverName = cs.ServerName
+	}
+	opts := x509.VerifyOptions{Roots: roots, DNSName: serverName, Intermediates: x509.NewCertPool()}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+func latestModTime(files ...string) time.Time {
+	var latest time.Time
+	for _, f := range files {
+		if st, err := os.Stat(f); err == nil && st.ModTime().After(latest) {
+			latest = st.ModTime()
+		}
+	}
+	return latest
+}
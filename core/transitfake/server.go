@@ -0,0 +1,319 @@
+// Package transitfake is an in-process stand-in for the subset of Vault's
+// transit engine and AppRole login that core.VaultKeyManager uses. It exists
+// so the key manager contract can be exercised without a real Vault.
+package transitfake
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Server struct {
+	*httptest.Server
+
+	RoleID   string
+	SecretID string
+	TokenTTL int
+
+	mu       sync.Mutex
+	keys     map[string][]byte
+	edKeys   map[string][]ed25519.PrivateKey
+	tokens   map[string]bool
+	failures []int
+	logins   int
+	requests int
+}
+
+func NewServer() *Server {
+	s := &Server{RoleID: "core-role", SecretID: "core-secret", TokenTTL: 60, keys: map[string][]byte{}, edKeys: map[string][]ed25519.PrivateKey{}, tokens: map[string]bool{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// FailNext makes the next len(statuses) requests fail with the given HTTP
+// statuses, in order, before any normal handling.
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// RevokeTokens forgets every issued token, as if their leases had expired.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = map[string]bool{}
+}
+
+// RotateKey adds a new version to the ed25519 key name, which signs from then
+// on; earlier versions keep being listed.
+func (s *Server) RotateKey(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate(name)
+}
+
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mu.Unlock()
+		writeErrors(w, status, "injected failure")
+		return
+	}
+	s.mu.Unlock()
+
+	if r.Method == http.MethodGet {
+		name, ok := strings.CutPrefix(r.URL.Path, "/v1/transit/keys/")
+		switch {
+		case !s.authorized(r.Header.Get("X-Vault-Token")):
+			writeErrors(w, http.StatusForbidden, "permission denied")
+		case !ok || name == "" || strings.Contains(name, "/"):
+			writeErrors(w, http.StatusNotFound, "unsupported path")
+		default:
+			s.readKey(w, name)
+		}
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeErrors(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var body map[string]any
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&body); err != nil {
+		writeErrors(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if r.URL.Path == "/v1/auth/approle/login" {
+		s.login(w, body)
+		return
+	}
+	if !s.authorized(r.Header.Get("X-Vault-Token")) {
+		writeErrors(w, http.StatusForbidden, "permission denied")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/transit/"), "/")
+	if len(parts) < 2 {
+		writeErrors(w, http.StatusNotFound, "unsupported path")
+		return
+	}
+	switch parts[0] {
+	case "encrypt":
+		s.encrypt(w, parts[1], body)
+	case "decrypt":
+		s.decrypt(w, parts[1], body)
+	case "hmac":
+		s.hmac(w, parts[1], body)
+	case "verify":
+		s.verify(w, parts[1], body)
+	case "sign":
+		s.sign(w, parts[1], body)
+	case "datakey":
+		if len(parts) != 3 || parts[1] != "plaintext" {
+			writeErrors(w, http.StatusNotFound, "unsupported path")
+			return
+		}
+		s.datakey(w, parts[2])
+	default:
+		writeErrors(w, http.StatusNotFound, "unsupported path")
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, body map[string]any) {
+	if body["role_id"] != s.RoleID || body["secret_id"] != s.SecretID {
+		writeErrors(w, http.StatusBadRequest, "invalid role or secret id")
+		return
+	}
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	token := "s." + base64.RawURLEncoding.EncodeToString(raw)
+	s.mu.Lock()
+	s.tokens[token] = true
+	s.logins++
+	s.mu.Unlock()
+	writeJSON(w, map[string]any{"auth": map[string]any{"client_token": token, "lease_duration": s.TokenTTL}})
+}
+
+func (s *Server) authorized(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[token]
+}
+
+func (s *Server) key(name string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[name]
+	if !ok {
+		k = make([]byte, 32)
+		rand.Read(k)
+		s.keys[name] = k
+	}
+	return k
+}
+
+func (s *Server) gcm(name string) cipher.AEAD {
+	block, _ := aes.NewCipher(s.key(name))
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
+
+func (s *Server) seal(name string, plain []byte) string {
+	aead := s.gcm(name)
+	out := make([]byte, aead.NonceSize())
+	rand.Read(out)
+	out = aead.Seal(out, out, plain, nil)
+	return "vault:v1:" + base64.StdEncoding.EncodeToString(out)
+}
+
+func (s *Server) encrypt(w http.ResponseWriter, name string, body map[string]any) {
+	plain, err := base64.StdEncoding.DecodeString(fmt.Sprint(body["plaintext"]))
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, "plaintext must be base64")
+		return
+	}
+	writeJSON(w, map[string]any{"data": map[string]any{"ciphertext": s.seal(name, plain)}})
+}
+
+func (s *Server) decrypt(w http.ResponseWriter, name string, body map[string]any) {
+	ct, _ := body["ciphertext"].(string)
+	if !strings.HasPrefix(ct, "vault:v1:") {
+		writeErrors(w, http.StatusBadRequest, "invalid ciphertext")
+		return
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ct, "vault:v1:"))
+	aead := s.gcm(name)
+	if err != nil || len(raw) < aead.NonceSize() {
+		writeErrors(w, http.StatusBadRequest, "invalid ciphertext")
+		return
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, "cipher: message authentication failed")
+		return
+	}
+	writeJSON(w, map[string]any{"data": map[string]any{"plaintext": base64.StdEncoding.EncodeToString(plain)}})
+}
+
+func (s *Server) mac(name string, body map[string]any) (string, bool) {
+	input, err := base64.StdEncoding.DecodeString(fmt.Sprint(body["input"]))
+	if err != nil {
+		return "", false
+	}
+	m := hmac.New(sha256.New, s.key(name))
+	m.Write(input)
+	return "vault:v1:" + base64.StdEncoding.EncodeToString(m.Sum(nil)), true
+}
+
+func (s *Server) hmac(w http.ResponseWriter, name string, body map[string]any) {
+	sum, ok := s.mac(name, body)
+	if !ok {
+		writeErrors(w, http.StatusBadRequest, "input must be base64")
+		return
+	}
+	writeJSON(w, map[string]any{"data": map[string]any{"hmac": sum}})
+}
+
+func (s *Server) verify(w http.ResponseWriter, name string, body map[string]any) {
+	sum, ok := s.mac(name, body)
+	if !ok {
+		writeErrors(w, http.StatusBadRequest, "input must be base64")
+		return
+	}
+	got, _ := body["hmac"].(string)
+	writeJSON(w, map[string]any{"data": map[string]any{"valid": hmac.Equal([]byte(sum), []byte(got))}})
+}
+
+func (s *Server) datakey(w http.ResponseWriter, name string) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	writeJSON(w, map[string]any{"data": map[string]any{
+		"plaintext":  base64.StdEncoding.EncodeToString(key),
+		"ciphertext": s.seal(name, key),
+	}})
+}
+
+// edKey returns the latest version of the ed25519 key name, creating it on
+// first use like the symmetric keys. Callers hold s.mu.
+func (s *Server) edKey(name string) (int, ed25519.PrivateKey) {
+	if len(s.edKeys[name]) == 0 {
+		s.rotate(name)
+	}
+	versions := s.edKeys[name]
+	return len(versions), versions[len(versions)-1]
+}
+
+func (s *Server) rotate(name string) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	s.edKeys[name] = append(s.edKeys[name], priv)
+}
+
+func (s *Server) sign(w http.ResponseWriter, name string, body map[string]any) {
+	input, err := base64.StdEncoding.DecodeString(fmt.Sprint(body["input"]))
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, "input must be base64")
+		return
+	}
+	s.mu.Lock()
+	version, priv := s.edKey(name)
+	s.mu.Unlock()
+	sig := ed25519.Sign(priv, input)
+	writeJSON(w, map[string]any{"data": map[string]any{
+		"signature":   "vault:v" + strconv.Itoa(version) + ":" + base64.StdEncoding.EncodeToString(sig),
+		"key_version": version,
+	}})
+}
+
+func (s *Server) readKey(w http.ResponseWriter, name string) {
+	s.mu.Lock()
+	latest, _ := s.edKey(name)
+	keys := map[string]any{}
+	for i, priv := range s.edKeys[name] {
+		keys[strconv.Itoa(i+1)] = map[string]any{
+			"name":       "ed25519",
+			"public_key": base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)),
+		}
+	}
+	s.mu.Unlock()
+	writeJSON(w, map[string]any{"data": map[string]any{
+		"name": name, "type": "ed25519", "latest_version": latest, "keys": keys,
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeErrors(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"errors": []string{msg}})
+}
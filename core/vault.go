@@ -0,0 +1,434 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeyNotExportable = errors.New("key material is not exportable from this key manager")
+	ErrVaultUnavailable = errors.New("vault unavailable")
+)
+
+type VaultConfig struct {
+	Address       string
+	Mount         string
+	EncryptionKey string
+	SigningKey    string
+	// DigestKey is the transit key behind RemoteDigest (blind indexes, CVVs,
+	// references). Empty means SigningKey, separated only by the label
+	// prefix keyedDigest adds.
+	DigestKey string
+	// EventKey is an ed25519 transit key that signs events. Empty leaves the
+	// manager without an event signer, so SignEvent fails.
+	EventKey    string
+	Token       string
+	RoleID      string
+	SecretID    string
+	Timeout     time.Duration
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	HTTPClient  *http.Client
+}
+
+// VaultKeyManager speaks the transit secrets engine API, so encryption,
+// HMAC signing and data-key generation happen inside Vault and raw keys never
+// enter this process. Every failure is returned to the caller; there is no
+// local fallback.
+type VaultKeyManager struct {
+	cfg    VaultConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+
+	// eventKids maps EventKey versions to the key ids in PublicKeySet.
+	eventMu   sync.Mutex
+	eventKids map[int]string
+}
+
+type VaultError struct {
+	Status int
+	Errors []string
+}
+
+func (e *VaultError) Error() string {
+	return fmt.Sprintf("vault: status %d: %s", e.Status, strings.Join(e.Errors, "; "))
+}
+
+func NewVaultKeyManager(cfg VaultConfig) (*VaultKeyManager, error) {
+	if cfg.Address == "" || cfg.EncryptionKey == "" || cfg.SigningKey == "" {
+		return nil, errors.New("vault address and key names are required")
+	}
+	if cfg.Token == "" && (cfg.RoleID == "" || cfg.SecretID == "") {
+		return nil, errors.New("vault token or approle credentials are required")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "transit"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 2 * time.Second
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+	m := &VaultKeyManager{cfg: cfg, client: client}
+	if cfg.Token != "" {
+		m.token = cfg.Token
+	}
+	return m, nil
+}
+
+func (m *VaultKeyManager) GetEncryptionKey() ([]byte, error) {
+	return nil, ErrKeyNotExportable
+}
+
+func (m *VaultKeyManager) GetSigningKey() ([]byte, error) {
+	return nil, ErrKeyNotExportable
+}
+
+func (m *VaultKeyManager) RemoteEncrypt(plaintext []byte) (string, error) {
+	var out struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := m.transit("encrypt/"+m.cfg.EncryptionKey, map[string]any{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.Ciphertext, nil
+}
+
+func (m *VaultKeyManager) RemoteDecrypt(ciphertext string) ([]byte, error) {
+	var out struct {
+		Plaintext string `json:"plaintext"`
+	}
+	err := m.transit("decrypt/"+m.cfg.EncryptionKey, map[string]any{"ciphertext": ciphertext}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Plaintext)
+}
+
+func (m *VaultKeyManager) RemoteSign(payload []byte) (string, error) {
+	var out struct {
+		HMAC string `json:"hmac"`
+	}
+	err := m.transit("hmac/"+m.cfg.SigningKey+"/sha2-256", map[string]any{"input": base64.StdEncoding.EncodeToString(payload)}, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.HMAC, nil
+}
+
+func (m *VaultKeyManager) RemoteVerify(payload []byte, sig string) (bool, error) {
+	var out struct {
+		Valid bool `json:"valid"`
+	}
+	err := m.transit("verify/"+m.cfg.SigningKey+"/sha2-256", map[string]any{"input": base64.StdEncoding.EncodeToString(payload), "hmac": sig}, &out)
+	if err != nil {
+		return false, err
+	}
+	return out.Valid, nil
+}
+
+// RemoteDigest returns the raw HMAC bytes under DigestKey without Vault's
+// version prefix. Digests change when that key is rotated, so blind indexes
+// built on them must be recomputed after a rotation.
+func (m *VaultKeyManager) RemoteDigest(data []byte) ([]byte, error) {
+	key := m.cfg.DigestKey
+	if key == "" {
+		key = m.cfg.SigningKey
+	}
+	var out struct {
+		HMAC string `json:"hmac"`
+	}
+	err := m.transit("hmac/"+key+"/sha2-256", map[string]any{"input": base64.StdEncoding.EncodeToString(data)}, &out)
+	if err != nil {
+		return nil, err
+	}
+	sig := out.HMAC
+	i := strings.LastIndexByte(sig, ':')
+	if i < 0 {
+		return nil, errors.New("vault: malformed hmac")
+	}
+	return base64.StdEncoding.DecodeString(sig[i+1:])
+}
+
+// SignEd25519 signs with the latest version of EventKey. The key id is
+// derived from that version's public key, so it matches PublicKeySet.
+func (m *VaultKeyManager) SignEd25519(payload []byte) (string, []byte, error) {
+	if m.cfg.EventKey == "" {
+		return "", nil, ErrNoEventSigner
+	}
+	var out struct {
+		Signature string `json:"signature"`
+	}
+	err := m.transit("sign/"+m.cfg.EventKey, map[string]any{"input": base64.StdEncoding.EncodeToString(payload)}, &out)
+	if err != nil {
+		return "", nil, err
+	}
+	version, raw, err := splitVaultValue(out.Signature)
+	if err != nil {
+		return "", nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	m.eventMu.Lock()
+	kid, ok := m.eventKids[version]
+	m.eventMu.Unlock()
+	if !ok {
+		// A version we have not seen means the key was rotated.
+		if _, err := m.PublicKeySet(); err != nil {
+			return "", nil, err
+		}
+		m.eventMu.Lock()
+		kid, ok = m.eventKids[version]
+		m.eventMu.Unlock()
+		if !ok {
+			return "", nil, fmt.Errorf("vault: no public key for %s version %d", m.cfg.EventKey, version)
+		}
+	}
+	return kid, sig, nil
+}
+
+// PublicKeySet lists every version of EventKey that Vault still holds.
+func (m *VaultKeyManager) PublicKeySet() (*JWKS, error) {
+	if m.cfg.EventKey == "" {
+		return &JWKS{Keys: []JWK{}}, nil
+	}
+	var out struct {
+		Type string `json:"type"`
+		Keys map[string]struct {
+			PublicKey string `json:"public_key"`
+		} `json:"keys"`
+	}
+	if err := m.call(http.MethodGet, "/v1/"+m.cfg.Mount+"/keys/"+m.cfg.EventKey, nil, &out, true); err != nil {
+		return nil, err
+	}
+	if out.Type != "ed25519" {
+		return nil, fmt.Errorf("vault: event key %s has type %q, want ed25519", m.cfg.EventKey, out.Type)
+	}
+	versions := make([]int, 0, len(out.Keys))
+	for v := range out.Keys {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("vault: malformed key version %q", v)
+		}
+		versions = append(versions, n)
+	}
+	slices.Sort(versions)
+	set := &JWKS{Keys: make([]JWK, 0, len(versions))}
+	kids := make(map[int]string, len(versions))
+	for _, v := range versions {
+		pub, err := base64.StdEncoding.DecodeString(out.Keys[strconv.Itoa(v)].PublicKey)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("vault: malformed public key for %s version %d", m.cfg.EventKey, v)
+		}
+		kids[v] = Ed25519KeyID(pub)
+		set.Keys = append(set.Keys, publicJWK(kids[v], pub))
+	}
+	m.eventMu.Lock()
+	m.eventKids = kids
+	m.eventMu.Unlock()
+	return set, nil
+}
+
+// splitVaultValue splits "vault:v3:payload" into 3 and "payload".
+func splitVaultValue(s string) (int, string, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return 0, "", errors.New("vault: malformed value")
+	}
+	version, err := strconv.Atoi(parts[1][1:])
+	if err != nil {
+		return 0, "", errors.New("vault: malformed value")
+	}
+	return version, parts[2], nil
+}
+
+func (m *VaultKeyManager) GenerateDataKey() ([]byte, string, error) {
+	var out struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := m.transit("datakey/plaintext/"+m.cfg.EncryptionKey, map[string]any{"bits": 256}, &out)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := base64.StdEncoding.DecodeString(out.Plaintext)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, out.Ciphertext, nil
+}
+
+func (m *VaultKeyManager) UnwrapDataKey(wrapped string) ([]byte, error) {
+	return m.RemoteDecrypt(wrapped)
+}
+
+func (m *VaultKeyManager) transit(op string, body, out any) error {
+	return m.call(http.MethodPost, "/v1/"+m.cfg.Mount+"/"+op, body, out, true)
+}
+
+func (m *VaultKeyManager) call(method, path string, body, out any, authed bool) error {
+	var payload []byte
+	var err error
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	var lastErr error
+	refreshed := false
+	for attempt := 0; attempt < m.cfg.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(m.backoff(attempt))
+		}
+		var token string
+		if authed {
+			token, err = m.currentToken()
+			if err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		status, data, err := m.do(method, path, token, payload)
+		if err != nil {
+			lastErr = fmt.Errorf("%w: %v", ErrVaultUnavailable, err)
+			continue
+		}
+		switch {
+		case status == http.StatusForbidden && authed && !refreshed && m.cfg.RoleID != "":
+			refreshed = true
+			m.invalidateToken()
+			lastErr = decodeVaultError(status, data)
+			attempt--
+			continue
+		case status == http.StatusTooManyRequests || status >= 500:
+			lastErr = decodeVaultError(status, data)
+			continue
+		case status >= 300:
+			return decodeVaultError(status, data)
+		}
+		var envelope struct {
+			Data json.RawMessage `json:"data"`
+			Auth json.RawMessage `json:"auth"`
+		}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return err
+		}
+		raw := envelope.Data
+		if !authed {
+			raw = envelope.Auth
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			return errors.New("vault: empty response")
+		}
+		return json.Unmarshal(raw, out)
+	}
+	return lastErr
+}
+
+func (m *VaultKeyManager) do(method, path, token string, payload []byte) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(m.cfg.Address, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+func (m *VaultKeyManager) backoff(attempt int) time.Duration {
+	d := m.cfg.BaseBackoff << (attempt - 1)
+	if d > m.cfg.MaxBackoff || d <= 0 {
+		d = m.cfg.MaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func (m *VaultKeyManager) currentToken() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token != "" && (m.tokenExpiry.IsZero() || time.Now().Before(m.tokenExpiry)) {
+		return m.token, nil
+	}
+	if m.cfg.RoleID == "" {
+		return m.token, nil
+	}
+	var auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	}
+	body := map[string]string{"role_id": m.cfg.RoleID, "secret_id": m.cfg.SecretID}
+	if err := m.call(http.MethodPost, "/v1/auth/approle/login", body, &auth, false); err != nil {
+		return "", err
+	}
+	if auth.ClientToken == "" {
+		return "", errors.New("vault: login returned no token")
+	}
+	m.token = auth.ClientToken
+	m.tokenExpiry = time.Time{}
+	if auth.LeaseDuration > 0 {
+		// Renew a little early so a call never races the lease running out.
+		ttl := time.Duration(auth.LeaseDuration) * time.Second
+		m.tokenExpiry = time.Now().Add(ttl - ttl/10)
+	}
+	return m.token, nil
+}
+
+func (m *VaultKeyManager) invalidateToken() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cfg.RoleID != "" {
+		m.token = ""
+	}
+}
+
+func decodeVaultError(status int, data []byte) error {
+	var body struct {
+		Errors []string `json:"errors"`
+	}
+	json.Unmarshal(data, &body)
+	return &VaultError{Status: status, Errors: body.Errors}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type backendStatus struct {
+	Upstream     string    `json:"upstream"`
+	Backend      string    `json:"backend"`
+	State        string    `json:"state"`
+	Since        time.Time `json:"since,omitzero"`
+	Requests     int64     `json:"requests"`
+	ErrorRate    float64   `json:"error_rate"`
+	SlowCallRate float64   `json:"slow_call_rate"`
+	Healthy      bool      `json:"healthy"`
+	Ejected      bool      `json:"ejected"`
+	Inflight     int64     `json:"inflight"`
+
+	transitions [3]int64
+}
+
+// backendStatuses lists every backend of the running config, ordered by
+// upstream name and then by position in the pool.
+func (g *Gateway) backendStatuses() []backendStatus {
+	st := g.current()
+	names := make([]string, 0, len(st.upstreams))
+	for name := range st.upstreams {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var out []backendStatus
+	for _, name := range names {
+		lb := st.upstreams[name].lb
+		cfg := lb.breaker.Load()
+		for _, b := range lb.Backends() {
+			s := backendStatus{
+				Upstream: name,
+				Backend:  b.URL,
+				State:    b.breaker.current().String(),
+				Healthy:  b.isHealthy(),
+				Ejected:  b.ejected.Load(),
+				Inflight: b.inflight.Load(),
+			}
+			if since := b.breaker.since.Load(); since != 0 {
+				s.Since = time.Unix(0, since).UTC()
+			}
+			if cfg != nil {
+				s.Requests, s.ErrorRate, s.SlowCallRate = b.breaker.rates(cfg)
+			}
+			for i := range s.transitions {
+				s.transitions[i] = b.breaker.transitions[i].Load()
+			}
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// NewAdminApp serves GET /breakers as JSON and GET /metrics in the
+// Prometheus text format.
+func NewAdminApp(g *Gateway) *fiber.App {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/breakers", func(c *fiber.Ctx) error {
+		return c.JSON(g.backendStatuses())
+	})
+	app.Get("/metrics", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4")
+		return c.SendString(formatMetrics(g.backendStatuses()))
+	})
+	return app
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatMetrics(statuses []backendStatus) string {
+	var sb strings.Builder
+	metric := func(name, kind, help string, value func(s backendStatus, labels string) string) {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, s := range statuses {
+			labels := fmt.Sprintf(`upstream="%s",backend="%s"`, labelEscaper.Replace(s.Upstream), labelEscaper.Replace(s.Backend))
+			sb.WriteString(value(s, labels))
+		}
+	}
+	gauge := func(name, help string, v func(backendStatus) float64) {
+		metric(name, "gauge", help, func(s backendStatus, labels string) string {
+			return fmt.Sprintf("%s{%s} %g\n", name, labels, v(s))
+		})
+	}
+	boolValue := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	gauge("gateway_breaker_state", "Circuit breaker state: 0 closed, 1 open, 2 half-open.", func(s backendStatus) float64 {
+		switch s.State {
+		case breakerOpen.String():
+			return 1
+		case breakerHalfOpen.String():
+			return 2
+		}
+		return 0
+	})
+	metric("gateway_breaker_transitions_total", "counter", "Circuit breaker state changes, by the state entered.", func(s backendStatus, labels string) string {
+		var out string
+		for _, state := range []breakerState{breakerClosed, breakerOpen, breakerHalfOpen} {
+			out += fmt.Sprintf("gateway_breaker_transitions_total{%s,state=%q} %d\n", labels, state.String(), s.transitions[state])
+		}
+		return out
+	})
+	gauge("gateway_breaker_error_rate", "Share of failed requests in the breaker window.", func(s backendStatus) float64 { return s.ErrorRate })
+	gauge("gateway_breaker_slow_call_rate", "Share of slow requests in the breaker window.", func(s backendStatus) float64 { return s.SlowCallRate })
+	gauge("gateway_backend_healthy", "1 if the backend passes active health checks.", func(s backendStatus) float64 { return boolValue(s.Healthy) })
+	gauge("gateway_backend_ejected", "1 if outlier detection has ejected the backend.", func(s backendStatus) float64 { return boolValue(s.Ejected) })
+	gauge("gateway_backend_inflight", "Requests in flight to the backend.", func(s backendStatus) float64 { return float64(s.Inflight) })
+	return sb.String()
+}
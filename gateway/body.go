@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// responseBufferSize is the largest backend response body read in full
+// before it is passed on.
+const responseBufferSize = 64 << 10
+
+var errBodyTooLarge = errors.New("body exceeds the configured limit")
+
+// limitReader fails with errBodyTooLarge instead of truncating, so an
+// oversized body aborts the transfer rather than arriving cut short.
+type limitReader struct {
+	r    io.Reader
+	left int64 // bytes still allowed; negative means no limit
+	read int64
+	eof  bool
+	err  error // first failure other than io.EOF
+}
+
+func newLimitReader(r io.Reader, limit int64) *limitReader {
+	if limit == 0 {
+		limit = -1
+	}
+	return &limitReader{r: r, left: limit}
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.readLimited(p)
+	l.read += int64(n)
+	switch {
+	case err == io.EOF:
+		l.eof = true
+	case err != nil && l.err == nil:
+		l.err = err
+	}
+	return n, err
+}
+
+// broken reports whether the body itself failed: it was too large, could
+// not be read, or ended before the size it declared (size < 0 if unknown).
+func (l *limitReader) broken(size int) bool {
+	return l.err != nil || (l.eof && size >= 0 && l.read < int64(size))
+}
+
+func (l *limitReader) readLimited(p []byte) (int, error) {
+	if l.left < 0 {
+		return l.r.Read(p)
+	}
+	if l.left == 0 {
+		// Only fail if there is actually more to read.
+		var one [1]byte
+		if n, err := l.r.Read(one[:]); n == 0 {
+			return 0, err
+		}
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// responseBody streams a backend response to the client. fasthttp closes it
+// once the body is written or the client goes away, which returns the
+// backend connection to the pool and the response to fasthttp.
+type responseBody struct {
+	*limitReader
+	resp    *fasthttp.Response
+	backend string
+}
+
+func (b *responseBody) Close() error {
+	err := b.resp.CloseBodyStream()
+	fasthttp.ReleaseResponse(b.resp)
+	return err
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	n, err := b.limitReader.Read(p)
+	if errors.Is(err, errBodyTooLarge) {
+		log.Printf("[proxy] response from %s exceeds max_response_body; aborting", b.backend)
+	}
+	return n, err
+}
+
+// hopHeaders describe a single connection and are not forwarded; fasthttp
+// sets its own framing headers for the body it sends.
+var hopHeaders = map[string]bool{
+	fasthttp.HeaderConnection:       true,
+	fasthttp.HeaderKeepAlive:        true,
+	fasthttp.HeaderTransferEncoding: true,
+	fasthttp.HeaderContentLength:    true,
+	fasthttp.HeaderTE:               true,
+	fasthttp.HeaderTrailer:          true,
+	fasthttp.HeaderUpgrade:          true,
+	"Proxy-Connection":              true,
+}
+
+// idleConn turns the absolute deadlines fasthttp sets for each request into
+// idle timeouts: every Read or Write may wait that long for progress. A
+// large upload or a long streamed response then takes as long as it needs,
+// while a backend that stalls is still cut off. The response headers are
+// the exception: they must arrive by the absolute read deadline, so a
+// backend trickling them out cannot hold a request past its attempt.
+type idleConn struct {
+	net.Conn
+	readIdle  atomic.Int64
+	writeIdle atomic.Int64
+	// inHeaders is set while the response headers are outstanding; reads
+	// then keep the absolute deadline. crlf counts the bytes of the blank
+	// line ending them seen so far.
+	inHeaders atomic.Bool
+	crlf      int
+}
+
+func dialIdle(addr string) (net.Conn, error) {
+	conn, err := fasthttp.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &idleConn{Conn: conn}, nil
+}
+
+// dialIdleTLS does the TLS handshake itself, so the idleConn sits above TLS
+// and scans the plaintext response for the end of its headers. fasthttp
+// leaves a connection that has a Handshake method as it is.
+func dialIdleTLS(cfg *tls.Config, timeout time.Duration) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		raw, err := fasthttp.Dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		c := &tls.Config{}
+		if cfg != nil {
+			c = cfg.Clone()
+		}
+		if c.ServerName == "" {
+			c.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		conn := tls.Client(raw, c)
+		conn.SetDeadline(time.Now().Add(timeout))
+		if err := conn.Handshake(); err != nil {
+			raw.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return &idleTLSConn{&idleConn{Conn: conn}}, nil
+	}
+}
+
+type idleTLSConn struct {
+	*idleConn
+}
+
+func (c *idleTLSConn) Handshake() error {
+	return c.Conn.(*tls.Conn).Handshake()
+}
+
+func idleFor(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return int64(max(time.Until(t), time.Millisecond))
+}
+
+func (c *idleConn) SetDeadline(t time.Time) error {
+	c.startHeaders(t)
+	c.writeIdle.Store(idleFor(t))
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline is called by fasthttp just before it reads a response.
+func (c *idleConn) SetReadDeadline(t time.Time) error {
+	c.startHeaders(t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *idleConn) startHeaders(t time.Time) {
+	c.readIdle.Store(idleFor(t))
+	c.crlf = 0
+	c.inHeaders.Store(!t.IsZero())
+}
+
+func (c *idleConn) SetWriteDeadline(t time.Time) error {
+	c.writeIdle.Store(idleFor(t))
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *idleConn) Read(p []byte) (int, error) {
+	if c.inHeaders.Load() {
+		n, err := c.Conn.Read(p)
+		c.scanHeaders(p[:n])
+		return n, err
+	}
+	if d := c.readIdle.Load(); d > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(time.Duration(d)))
+	}
+	return c.Conn.Read(p)
+}
+
+// scanHeaders looks for the CRLF CRLF ending the response headers.
+func (c *idleConn) scanHeaders(p []byte) {
+	for _, b := range p {
+		switch {
+		case b == "\r\n\r\n"[c.crlf]:
+			c.crlf++
+			if c.crlf == 4 {
+				c.inHeaders.Store(false)
+				return
+			}
+		case b == '\r':
+			c.crlf = 1
+		default:
+			c.crlf = 0
+		}
+	}
+}
+
+func (c *idleConn) Write(p []byte) (int, error) {
+	if d := c.writeIdle.Load(); d > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(time.Duration(d)))
+	}
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TestIdleTimeout checks that the upstream timeout bounds the wait for the
+// response headers and each silence in the body, not the whole transfer,
+// over plain HTTP and TLS alike.
+func TestIdleTimeout(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	for _, scheme := range []string{"http", "https"} {
+		for _, tc := range []struct {
+			name        string
+			headerDelay time.Duration
+			chunkDelay  time.Duration
+			chunks      int
+			wantErr     error
+		}{
+			// Eight chunks 100ms apart: four times the timeout in total.
+			{name: "slow stream", chunkDelay: timeout / 2, chunks: 8},
+			{name: "stalled body", chunkDelay: 3 * timeout, chunks: 2, wantErr: errAnyTimeout},
+			{name: "late headers", headerDelay: 3 * timeout, chunks: 1, wantErr: fasthttp.ErrTimeout},
+		} {
+			t.Run(scheme+"/"+tc.name, func(t *testing.T) {
+				h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					time.Sleep(tc.headerDelay)
+					w.WriteHeader(http.StatusOK)
+					w.(http.Flusher).Flush()
+					for i := range tc.chunks {
+						if i > 0 {
+							time.Sleep(tc.chunkDelay)
+						}
+						io.WriteString(w, "data: tick\n\n")
+						w.(http.Flusher).Flush()
+					}
+				})
+				var srv *httptest.Server
+				if scheme == "https" {
+					srv = httptest.NewTLSServer(h)
+				} else {
+					srv = httptest.NewServer(h)
+				}
+				t.Cleanup(srv.Close)
+				client, err := upstreamClient(UpstreamConfig{Timeout: timeout}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if scheme == "https" {
+					client.TLSConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+				}
+				t.Cleanup(client.CloseIdleConnections)
+
+				req := fasthttp.AcquireRequest()
+				defer fasthttp.ReleaseRequest(req)
+				resp := fasthttp.AcquireResponse()
+				defer fasthttp.ReleaseResponse(resp)
+				req.SetRequestURI(srv.URL + "/events")
+				start := time.Now()
+				err = client.DoDeadline(req, resp, start.Add(timeout))
+				var body []byte
+				if err == nil {
+					body, err = io.ReadAll(resp.BodyStream())
+					resp.CloseBodyStream()
+				}
+				switch {
+				case tc.wantErr == nil && err != nil:
+					t.Fatalf("after %s: %v", time.Since(start), err)
+				case tc.wantErr == nil:
+					if want := strings.Repeat("data: tick\n\n", tc.chunks); string(body) != want {
+						t.Fatalf("body = %q, want %q", body, want)
+					}
+				case tc.wantErr == errAnyTimeout:
+					if !isTimeout(err) {
+						t.Fatalf("err = %v, want a timeout", err)
+					}
+				case !errors.Is(err, tc.wantErr):
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+			})
+		}
+	}
+}
+
+// errAnyTimeout stands for whichever timeout error the read surfaces.
+var errAnyTimeout = errors.New("any timeout")
+
+func isTimeout(err error) bool {
+	var ne interface{ Timeout() bool }
+	return errors.Is(err, fasthttp.ErrTimeout) || errors.As(err, &ne) && ne.Timeout()
+}
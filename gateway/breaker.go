@@ -0,0 +1,232 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+type breakerState int32
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+// breakerBuckets is how many slices the rolling window is cut into.
+const breakerBuckets = 10
+
+type breakerBucket struct {
+	epoch    atomic.Int64
+	total    atomic.Int64
+	failures atomic.Int64
+	slow     atomic.Int64
+}
+
+// breaker is lock-free: counts go into the bucket for the current slice of
+// the window, reset by whichever request first lands in a new slice, and
+// state changes are compare-and-swaps so only one caller logs each one.
+type breaker struct {
+	state     atomic.Int32
+	since     atomic.Int64 // unix ns of the last state change
+	openUntil atomic.Int64 // when open, the end of the wait; when half-open, the trial deadline
+	permits   atomic.Int64 // half-open trial requests left to hand out
+	passed    atomic.Int64 // half-open trial requests that succeeded
+	buckets   [breakerBuckets]breakerBucket
+
+	transitions [3]atomic.Int64 // entries into each state
+}
+
+func (br *breaker) current() breakerState {
+	return breakerState(br.state.Load())
+}
+
+// available is the cheap check used while choosing a backend; admit makes
+// the actual decision once one is chosen.
+func (br *breaker) available() bool {
+	switch br.current() {
+	case breakerOpen:
+		return time.Now().UnixNano() >= br.openUntil.Load()
+	case breakerHalfOpen:
+		return br.permits.Load() > 0 || time.Now().UnixNano() >= br.openUntil.Load()
+	}
+	return true
+}
+
+// admit decides whether a request may go to the backend. The caller that
+// moves an expired open breaker to half-open takes the first trial permit
+// and is told so through halfOpened. A trial round that is still undecided
+// after OpenFor, because trial requests were lost without a report or a
+// release, starts over the same way.
+func (br *breaker) admit(cfg *BreakerConfig) (ok, halfOpened bool) {
+	now := time.Now().UnixNano()
+	switch br.current() {
+	case breakerClosed:
+		return true, false
+	case breakerOpen:
+		if now < br.openUntil.Load() || !br.transition(breakerOpen, breakerHalfOpen) {
+			return false, false
+		}
+		br.startTrials(cfg, now)
+		return true, true
+	}
+	if br.permits.Add(-1) >= 0 {
+		return true, false
+	}
+	br.permits.Add(1)
+	if until := br.openUntil.Load(); now >= until && br.openUntil.CompareAndSwap(until, now+int64(cfg.OpenFor)) {
+		br.startTrials(cfg, now)
+		return true, true
+	}
+	return false, false
+}
+
+// startTrials hands out a round of trial permits, the first of which goes
+// to the caller.
+func (br *breaker) startTrials(cfg *BreakerConfig, now int64) {
+	br.openUntil.Store(now + int64(cfg.OpenFor))
+	br.passed.Store(0)
+	br.permits.Store(int64(cfg.HalfOpenRequests) - 1)
+}
+
+// release returns a trial permit taken for a request that ended without an
+// outcome.
+func (br *breaker) release() {
+	if br.current() == breakerHalfOpen {
+		br.permits.Add(1)
+	}
+}
+
+func (br *breaker) trip(cfg *BreakerConfig, from breakerState) bool {
+	br.permits.Store(0)
+	br.openUntil.Store(time.Now().Add(cfg.OpenFor).UnixNano())
+	return br.transition(from, breakerOpen)
+}
+
+func (br *breaker) transition(from, to breakerState) bool {
+	if !br.state.CompareAndSwap(int32(from), int32(to)) {
+		return false
+	}
+	br.since.Store(time.Now().UnixNano())
+	br.transitions[to].Add(1)
+	return true
+}
+
+func (br *breaker) record(cfg *BreakerConfig, ok, slow bool) {
+	now := time.Now().UnixNano()
+	width := int64(cfg.Window) / breakerBuckets
+	epoch := now / width
+	bk := &br.buckets[epoch%breakerBuckets]
+	if old := bk.epoch.Load(); old != epoch && bk.epoch.CompareAndSwap(old, epoch) {
+		bk.total.Store(0)
+		bk.failures.Store(0)
+		bk.slow.Store(0)
+	}
+	bk.total.Add(1)
+	if !ok {
+		bk.failures.Add(1)
+	}
+	if slow {
+		bk.slow.Add(1)
+	}
+}
+
+// rates sums the buckets still inside the window.
+func (br *breaker) rates(cfg *BreakerConfig) (total int64, errorRate, slowRate float64) {
+	epoch := time.Now().UnixNano() / (int64(cfg.Window) / breakerBuckets)
+	var failures, slow int64
+	for i := range br.buckets {
+		bk := &br.buckets[i]
+		if epoch-bk.epoch.Load() >= breakerBuckets {
+			continue
+		}
+		total += bk.total.Load()
+		failures += bk.failures.Load()
+		slow += bk.slow.Load()
+	}
+	if total == 0 {
+		return 0, 0, 0
+	}
+	return total, float64(failures) / float64(total), float64(slow) / float64(total)
+}
+
+func (br *breaker) reset() {
+	for i := range br.buckets {
+		br.buckets[i].epoch.Store(0)
+	}
+}
+
+func (lb *LoadBalancer) SetCircuitBreaker(cfg BreakerConfig) {
+	if !cfg.Disabled {
+		lb.breaker.Store(&cfg)
+		return
+	}
+	lb.breaker.Store(nil)
+	for _, b := range lb.Backends() {
+		b.breaker.state.Store(int32(breakerClosed))
+	}
+}
+
+func (lb *LoadBalancer) admit(b *Backend) bool {
+	cfg := lb.breaker.Load()
+	if cfg == nil {
+		return true
+	}
+	ok, halfOpened := b.breaker.admit(cfg)
+	if halfOpened {
+		log.Printf("[breaker] %s %s half-open: allowing %d trial requests", lb.Name, b.URL, cfg.HalfOpenRequests)
+	}
+	return ok
+}
+
+// Release ends a request that Acquire admitted but whose outcome will not
+// be reported, such as one the client abandoned, so a half-open trial permit
+// it held is handed out again.
+func (lb *LoadBalancer) Release(b *Backend) {
+	if lb.breaker.Load() != nil {
+		b.breaker.release()
+	}
+}
+
+func (lb *LoadBalancer) recordBreaker(b *Backend, ok bool, elapsed time.Duration) {
+	cfg := lb.breaker.Load()
+	if cfg == nil {
+		return
+	}
+	br := &b.breaker
+	slow := elapsed >= cfg.SlowCall
+	switch br.current() {
+	case breakerHalfOpen:
+		if !ok || slow {
+			if br.trip(cfg, breakerHalfOpen) {
+				log.Printf("[breaker] %s %s reopened: trial request failed", lb.Name, b.URL)
+			}
+		} else if br.passed.Add(1) >= int64(cfg.HalfOpenRequests) {
+			br.reset()
+			if br.transition(breakerHalfOpen, breakerClosed) {
+				log.Printf("[breaker] %s %s closed", lb.Name, b.URL)
+			}
+		}
+	case breakerClosed:
+		br.record(cfg, ok, slow)
+		total, errorRate, slowRate := br.rates(cfg)
+		if total < int64(cfg.MinRequests) || (errorRate < cfg.ErrorRate && slowRate < cfg.SlowCallRate) {
+			return
+		}
+		if br.trip(cfg, breakerClosed) {
+			log.Printf("[breaker] %s %s opened for %s: %.0f%% errors, %.0f%% slow over %d requests",
+				lb.Name, b.URL, cfg.OpenFor, errorRate*100, slowRate*100, total)
+		}
+	}
+}
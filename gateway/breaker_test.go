@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func testBreaker(t *testing.T) (*LoadBalancer, *Backend, BreakerConfig) {
+	t.Helper()
+	cfg := BreakerConfig{MinRequests: 4, OpenFor: 30 * time.Millisecond, HalfOpenRequests: 2}
+	cfg.applyDefaults(time.Second)
+	lb := NewLoadBalancer("test", BalanceConfig{}, []string{"http://a"})
+	lb.SetCircuitBreaker(cfg)
+	return lb, lb.Backends()[0], cfg
+}
+
+// openBreaker fails enough requests to trip the breaker and waits out
+// OpenFor.
+func openBreaker(t *testing.T, lb *LoadBalancer, b *Backend, cfg BreakerConfig) {
+	t.Helper()
+	for range cfg.MinRequests {
+		if lb.Acquire("", nil) != b {
+			t.Fatal("closed breaker turned a request away")
+		}
+		lb.Report(b, false, time.Millisecond)
+	}
+	if got := b.breaker.current(); got != breakerOpen {
+		t.Fatalf("state after %d failures = %s, want open", cfg.MinRequests, got)
+	}
+	if lb.Acquire("", nil) != nil {
+		t.Fatal("open breaker admitted a request")
+	}
+	time.Sleep(cfg.OpenFor)
+}
+
+// acquireTrials takes every trial permit of a fresh half-open round.
+func acquireTrials(t *testing.T, lb *LoadBalancer, b *Backend, cfg BreakerConfig) {
+	t.Helper()
+	for i := range cfg.HalfOpenRequests {
+		if lb.Acquire("", nil) != b {
+			t.Fatalf("trial request %d turned away", i+1)
+		}
+	}
+	if got := b.breaker.current(); got != breakerHalfOpen {
+		t.Fatalf("state = %s, want half_open", got)
+	}
+	if lb.Acquire("", nil) != nil {
+		t.Fatal("admitted more than half_open_requests trial requests")
+	}
+}
+
+func TestBreakerCloses(t *testing.T) {
+	lb, b, cfg := testBreaker(t)
+	openBreaker(t, lb, b, cfg)
+	acquireTrials(t, lb, b, cfg)
+	for range cfg.HalfOpenRequests {
+		lb.Report(b, true, time.Millisecond)
+	}
+	if got := b.breaker.current(); got != breakerClosed {
+		t.Fatalf("state after successful trials = %s, want closed", got)
+	}
+	// The failures that opened it are forgotten.
+	lb.Report(b, false, time.Millisecond)
+	if got := b.breaker.current(); got != breakerClosed {
+		t.Fatalf("state after one failure = %s, want closed", got)
+	}
+}
+
+func TestBreakerReopens(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		ok      bool
+		elapsed time.Duration
+	}{
+		{"failure", false, time.Millisecond},
+		{"slow call", true, time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lb, b, cfg := testBreaker(t)
+			openBreaker(t, lb, b, cfg)
+			acquireTrials(t, lb, b, cfg)
+			lb.Report(b, true, time.Millisecond)
+			lb.Report(b, tc.ok, tc.elapsed)
+			if got := b.breaker.current(); got != breakerOpen {
+				t.Fatalf("state = %s, want open", got)
+			}
+			if lb.Acquire("", nil) != nil {
+				t.Fatal("reopened breaker admitted a request")
+			}
+		})
+	}
+}
+
+func TestBreakerReleaseReturnsTrialPermit(t *testing.T) {
+	lb, b, cfg := testBreaker(t)
+	openBreaker(t, lb, b, cfg)
+	// Trial requests the client abandons hand their permits back, however
+	// many there are.
+	for range 3 * cfg.HalfOpenRequests {
+		if lb.Acquire("", nil) != b {
+			t.Fatal("released trial permit was not handed out again")
+		}
+		lb.Release(b)
+	}
+	if !b.breaker.available() {
+		t.Fatal("backend unavailable after its trial requests were released")
+	}
+	acquireTrials(t, lb, b, cfg)
+	for range cfg.HalfOpenRequests {
+		lb.Report(b, true, time.Millisecond)
+	}
+	if got := b.breaker.current(); got != breakerClosed {
+		t.Fatalf("state = %s, want closed", got)
+	}
+}
+
+func TestBreakerRestartsStuckTrials(t *testing.T) {
+	lb, b, cfg := testBreaker(t)
+	openBreaker(t, lb, b, cfg)
+	// Trial requests that are neither reported nor released.
+	acquireTrials(t, lb, b, cfg)
+	if b.breaker.available() {
+		t.Fatal("backend available with every trial permit taken")
+	}
+	time.Sleep(cfg.OpenFor)
+	if !b.breaker.available() {
+		t.Fatal("backend still unavailable after the trial round timed out")
+	}
+	acquireTrials(t, lb, b, cfg)
+}
+
+func TestBreakerReleaseWhenClosed(t *testing.T) {
+	lb, b, _ := testBreaker(t)
+	lb.Acquire("", nil)
+	lb.Release(b)
+	if got := b.breaker.permits.Load(); got != 0 {
+		t.Fatalf("permits after releasing a closed-state request = %d, want 0", got)
+	}
+}
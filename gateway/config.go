@@ -1,16 +1,681 @@
 package main
 
-import "time"
-
-const (
-	ListenAddr        = ":8080"
-	BackendBasePort   = 3000
-	BackendCount      = 5
-	BackendHealthFreq = 5 * time.Second
-	BackendTimeout    = 2 * time.Second
-)
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
-var (
-	RateLimitRPS   = 5
-	RateLimitBurst = 10
+	"gopkg.in/yaml.v3"
 )
+
+// Config is read from a YAML or JSON file (JSON is valid YAML). Durations
+// are written as Go duration strings such as "5s". Everything except the
+// listener can be changed by a reload.
+type Config struct {
+	Listen    ListenConfig     `yaml:"listen"`
+	Admin     AdminConfig      `yaml:"admin"`
+	Upstreams []UpstreamConfig `yaml:"upstreams"`
+	Routes    []RouteConfig    `yaml:"routes"`
+	// DefaultUpstream receives requests that match no route. Without it
+	// they get a 404.
+	DefaultUpstream string `yaml:"default_upstream"`
+	// RateLimit is the default policy, applied to requests whose route
+	// names none and to requests that match no route. RateLimits holds the
+	// named policies routes can refer to.
+	RateLimit  RateLimitConfig            `yaml:"rate_limit"`
+	RateLimits map[string]RateLimitConfig `yaml:"rate_limits"`
+}
+
+// ListenConfig.MaxBufferedBody is the largest request body read in full
+// before proxying; such requests can be retried. Larger bodies are streamed
+// to the backend as they arrive. GRPCAddr, if set, serves HTTP/2 for routes
+// marked grpc: cleartext h2c, or TLS with the same settings as Addr.
+type ListenConfig struct {
+	Addr            string        `yaml:"addr"`
+	GRPCAddr        string        `yaml:"grpc_addr"`
+	TLS             ListenTLS     `yaml:"tls"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	MaxBufferedBody int           `yaml:"max_buffered_body"`
+}
+
+// AdminConfig serves /metrics and /breakers on a separate listener, which
+// should not be reachable from outside. An empty Addr disables it.
+type AdminConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+type ListenTLS struct {
+	Cert     string `yaml:"cert"`
+	Key      string `yaml:"key"`
+	ClientCA string `yaml:"client_ca"`
+}
+
+// UpstreamConfig.Timeout is how long a backend may go without accepting or
+// sending data. It bounds the wait for response headers and any pause in a
+// body, not the length of a transfer, so it must exceed the interval
+// between server-sent events on streaming routes. MaxRequestBody and
+// MaxResponseBody cap body sizes in bytes, with 0 meaning no limit. None of
+// these, nor Retry, apply to grpc routes: the client's grpc-timeout is the
+// only deadline, messages stream unlimited and RPCs are never retried.
+type UpstreamConfig struct {
+	Name            string        `yaml:"name"`
+	URLs            []string      `yaml:"urls"`
+	Timeout         time.Duration `yaml:"timeout"`
+	MaxRequestBody  int64         `yaml:"max_request_body"`
+	MaxResponseBody int64         `yaml:"max_response_body"`
+	TLS             UpstreamTLS   `yaml:"tls"`
+	HealthCheck     HealthConfig  `yaml:"health_check"`
+	Balance         BalanceConfig `yaml:"balance"`
+	Outlier         OutlierConfig `yaml:"outlier_detection"`
+	Retry           RetryConfig   `yaml:"retry"`
+	Breaker         BreakerConfig `yaml:"circuit_breaker"`
+}
+
+type UpstreamTLS struct {
+	CA         string `yaml:"ca"`
+	Cert       string `yaml:"cert"`
+	Key        string `yaml:"key"`
+	ServerName string `yaml:"server_name"`
+}
+
+// BalanceConfig chooses how requests are spread over an upstream's URLs.
+// Weights are keyed by URL, default to 1, and are honoured by
+// weighted_round_robin and consistent_hash. HashKey is "ip",
+// "header:<name>" or "cookie:<name>"; a request without the header or
+// cookie is hashed on its client IP instead.
+type BalanceConfig struct {
+	Strategy string         `yaml:"strategy"`
+	Weights  map[string]int `yaml:"weights"`
+	HashKey  string         `yaml:"hash_key"`
+}
+
+// OutlierConfig ejects a backend after ConsecutiveFailures failed requests
+// in a row, or once MinRequests have been sent in the current Window and
+// the share that failed reaches ErrorRate. A failure is a transport error
+// or a 5xx response. Each ejection lasts twice as long as the previous one,
+// from BaseEjection up to MaxEjection, and at most MaxEjectionPercent of
+// the pool is ejected at a time. A returning backend's share of traffic
+// grows linearly over RampUp.
+type OutlierConfig struct {
+	Disabled            bool          `yaml:"disabled"`
+	ConsecutiveFailures int           `yaml:"consecutive_failures"`
+	ErrorRate           float64       `yaml:"error_rate"`
+	MinRequests         int           `yaml:"min_requests"`
+	Window              time.Duration `yaml:"window"`
+	BaseEjection        time.Duration `yaml:"base_ejection"`
+	MaxEjection         time.Duration `yaml:"max_ejection"`
+	MaxEjectionPercent  int           `yaml:"max_ejection_percent"`
+	RampUp              time.Duration `yaml:"ramp_up"`
+}
+
+// HealthConfig sets up active probes. Type is "http" (the default), "tcp",
+// which only opens a connection, or "grpc", which calls grpc.health.v1 for
+// GRPCService. An http probe passes when the status is within
+// ExpectedStatus ("200-399" or a single code) and, if BodyContains is set,
+// the body contains it. A backend is marked down after UnhealthyThreshold
+// failed probes in a row and up again after HealthyThreshold passes. Each
+// probe is delayed by a random amount up to Jitter so backends are not all
+// probed at once.
+type HealthConfig struct {
+	Type               string        `yaml:"type"`
+	Path               string        `yaml:"path"`
+	Method             string        `yaml:"method"`
+	ExpectedStatus     string        `yaml:"expected_status"`
+	BodyContains       string        `yaml:"body_contains"`
+	GRPCService        string        `yaml:"grpc_service"`
+	Interval           time.Duration `yaml:"interval"`
+	Timeout            time.Duration `yaml:"timeout"`
+	Jitter             time.Duration `yaml:"jitter"`
+	HealthyThreshold   int           `yaml:"healthy_threshold"`
+	UnhealthyThreshold int           `yaml:"unhealthy_threshold"`
+}
+
+// RetryConfig lets the proxy resend a failed request to another backend.
+// Only idempotent methods and requests carrying IdempotencyHeader are
+// retried. Connection errors and timeouts always qualify; responses only
+// when their status is listed in OnStatus. Attempts counts the first try.
+// Retries across the upstream may not exceed BudgetPercent of the requests
+// seen in the last budget window plus MinRetriesPerSecond, so a failing pool
+// is not hit with a retry storm. Deadline bounds the whole request, retries
+// and Backoff pauses included.
+type RetryConfig struct {
+	Attempts            int           `yaml:"attempts"`
+	OnStatus            []int         `yaml:"on_status"`
+	IdempotencyHeader   string        `yaml:"idempotency_header"`
+	Backoff             time.Duration `yaml:"backoff"`
+	BudgetPercent       int           `yaml:"budget_percent"`
+	MinRetriesPerSecond int           `yaml:"min_retries_per_second"`
+	Deadline            time.Duration `yaml:"deadline"`
+}
+
+// BreakerConfig drives a circuit breaker on each backend. It opens once
+// MinRequests have been seen in the rolling Window and either the share of
+// failures reaches ErrorRate or the share of calls slower than SlowCall
+// reaches SlowCallRate. After OpenFor it lets HalfOpenRequests trial
+// requests through: if they all succeed it closes, otherwise it opens
+// again. A trial round still undecided after another OpenFor, because its
+// requests never completed, is handed out afresh.
+type BreakerConfig struct {
+	Disabled         bool          `yaml:"disabled"`
+	Window           time.Duration `yaml:"window"`
+	MinRequests      int           `yaml:"min_requests"`
+	ErrorRate        float64       `yaml:"error_rate"`
+	SlowCall         time.Duration `yaml:"slow_call"`
+	SlowCallRate     float64       `yaml:"slow_call_rate"`
+	OpenFor          time.Duration `yaml:"open_for"`
+	HalfOpenRequests int           `yaml:"half_open_requests"`
+}
+
+// RouteConfig sends matching requests to Upstream. Routes are tried in
+// order and the first match wins. With StripPrefix the matched path prefix
+// is removed before proxying; RewritePrefix replaces it instead. GRPC
+// routes are only served on listen.grpc_addr and reach their backends over
+// HTTP/2; all other routes are only served on listen.addr.
+type RouteConfig struct {
+	Name          string     `yaml:"name"`
+	Match         RouteMatch `yaml:"match"`
+	Upstream      string     `yaml:"upstream"`
+	StripPrefix   bool       `yaml:"strip_prefix"`
+	RewritePrefix string     `yaml:"rewrite_prefix"`
+	GRPC          bool       `yaml:"grpc"`
+	// RateLimit names a policy in rate_limits; empty uses rate_limit.
+	RateLimit string `yaml:"rate_limit"`
+}
+
+// RouteMatch conditions are ANDed. PathPrefix matches whole segments, so
+// "/api/card" matches "/api/card/1" but not "/api/cards". Host may start
+// with "*." to match any subdomain. A header value of "*" only requires the
+// header to be present.
+type RouteMatch struct {
+	PathPrefix string            `yaml:"path_prefix"`
+	Host       string            `yaml:"host"`
+	Methods    []string          `yaml:"methods"`
+	Headers    map[string]string `yaml:"headers"`
+}
+
+// RateLimitConfig allows each client RPS requests per second with bursts of
+// up to Burst; RPS 0 disables the policy. Key says what a client is, as for
+// balance.hash_key: "ip" (the default), "header:<name>" or "cookie:<name>",
+// falling back to the client IP when the header or cookie is missing.
+type RateLimitConfig struct {
+	RPS   int    `yaml:"rps"`
+	Burst int    `yaml:"burst"`
+	Key   string `yaml:"key"`
+}
+
+// DefaultConfig is used when no config file is given: five local backends
+// on ports 3000-3004, with listener and backend TLS taken from the
+// environment.
+func DefaultConfig() *Config {
+	cfg := &Config{
+		Listen: ListenConfig{
+			Addr: ":8080",
+			TLS: ListenTLS{
+				Cert:     os.Getenv("GATEWAY_TLS_CERT"),
+				Key:      os.Getenv("GATEWAY_TLS_KEY"),
+				ClientCA: os.Getenv("GATEWAY_TLS_CLIENT_CA"),
+			},
+		},
+		DefaultUpstream: "default",
+		RateLimit:       RateLimitConfig{RPS: 5, Burst: 10},
+	}
+	up := UpstreamConfig{
+		Name: "default",
+		TLS: UpstreamTLS{
+			CA:         os.Getenv("GATEWAY_BACKEND_CA"),
+			Cert:       os.Getenv("GATEWAY_BACKEND_CLIENT_CERT"),
+			Key:        os.Getenv("GATEWAY_BACKEND_CLIENT_KEY"),
+			ServerName: os.Getenv("GATEWAY_BACKEND_SERVER_NAME"),
+		},
+	}
+	scheme := "http"
+	if up.TLS.CA != "" || up.TLS.Cert != "" {
+		scheme = "https"
+	}
+	for port := 3000; port < 3005; port++ {
+		up.URLs = append(up.URLs, fmt.Sprintf("%s://127.0.0.1:%d", scheme, port))
+	}
+	cfg.Upstreams = []UpstreamConfig{up}
+	cfg.applyDefaults()
+	return cfg
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) applyDefaults() {
+	c.RateLimit.applyDefaults()
+	for name, rl := range c.RateLimits {
+		rl.applyDefaults()
+		c.RateLimits[name] = rl
+	}
+	if c.Listen.IdleTimeout == 0 {
+		c.Listen.IdleTimeout = 10 * time.Second
+	}
+	if c.Listen.MaxBufferedBody == 0 {
+		c.Listen.MaxBufferedBody = 1 << 20
+	}
+	if c.DefaultUpstream == "" && len(c.Upstreams) == 1 && len(c.Routes) == 0 {
+		c.DefaultUpstream = c.Upstreams[0].Name
+	}
+	for i := range c.Routes {
+		r := &c.Routes[i]
+		if r.Match.PathPrefix == "" {
+			r.Match.PathPrefix = "/"
+		}
+		for j, m := range r.Match.Methods {
+			r.Match.Methods[j] = strings.ToUpper(m)
+		}
+	}
+	for i := range c.Upstreams {
+		u := &c.Upstreams[i]
+		if u.Timeout == 0 {
+			u.Timeout = 2 * time.Second
+		}
+		if u.MaxRequestBody == 0 {
+			u.MaxRequestBody = 64 << 20
+		}
+		u.HealthCheck.applyDefaults()
+		u.Outlier.applyDefaults()
+		u.Retry.applyDefaults(u.Timeout)
+		u.Breaker.applyDefaults(u.Timeout)
+		if u.Balance.Strategy == "" {
+			u.Balance.Strategy = StrategyRoundRobin
+		}
+		if u.Balance.Strategy == StrategyConsistentHash && u.Balance.HashKey == "" {
+			u.Balance.HashKey = "ip"
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Listen.Addr == "" {
+		errs = append(errs, errors.New("listen.addr is required"))
+	}
+	if c.Listen.MaxBufferedBody < 0 {
+		errs = append(errs, errors.New("listen.max_buffered_body must not be negative"))
+	}
+	if (c.Listen.TLS.Cert == "") != (c.Listen.TLS.Key == "") {
+		errs = append(errs, errors.New("listen.tls.cert and listen.tls.key must be set together"))
+	}
+	if c.Listen.TLS.ClientCA != "" && c.Listen.TLS.Cert == "" {
+		errs = append(errs, errors.New("listen.tls.client_ca requires listen.tls.cert"))
+	}
+	if len(c.Upstreams) == 0 {
+		errs = append(errs, errors.New("at least one upstream is required"))
+	}
+	names := map[string]bool{}
+	for i, u := range c.Upstreams {
+		where := fmt.Sprintf("upstreams[%d]", i)
+		if u.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", where))
+		} else if names[u.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate name %q", where, u.Name))
+		}
+		names[u.Name] = true
+		if len(u.URLs) == 0 {
+			errs = append(errs, fmt.Errorf("%s: at least one url is required", where))
+		}
+		for _, raw := range u.URLs {
+			if err := validateBackendURL(raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", where, err))
+			}
+		}
+		if u.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("%s: timeout must not be negative", where))
+		}
+		if u.MaxRequestBody < 0 || u.MaxResponseBody < 0 {
+			errs = append(errs, fmt.Errorf("%s: max_request_body and max_response_body must not be negative", where))
+		}
+		if (u.TLS.Cert == "") != (u.TLS.Key == "") {
+			errs = append(errs, fmt.Errorf("%s: tls.cert and tls.key must be set together", where))
+		}
+		if err := u.HealthCheck.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: health_check: %w", where, err))
+		}
+		errs = append(errs, u.Balance.validate(where, u.URLs)...)
+		if err := u.Retry.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: retry: %w", where, err))
+		}
+		if err := u.Breaker.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: circuit_breaker: %w", where, err))
+		}
+		if err := u.Outlier.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: outlier_detection: %w", where, err))
+		}
+	}
+	for i, r := range c.Routes {
+		where := fmt.Sprintf("routes[%d]", i)
+		if r.Name != "" {
+			where = fmt.Sprintf("route %q", r.Name)
+		}
+		if !names[r.Upstream] {
+			errs = append(errs, fmt.Errorf("%s: upstream %q is not defined", where, r.Upstream))
+		}
+		if !strings.HasPrefix(r.Match.PathPrefix, "/") {
+			errs = append(errs, fmt.Errorf("%s: match.path_prefix must start with /", where))
+		}
+		if r.RewritePrefix != "" && !strings.HasPrefix(r.RewritePrefix, "/") {
+			errs = append(errs, fmt.Errorf("%s: rewrite_prefix must start with /", where))
+		}
+		if r.StripPrefix && r.RewritePrefix != "" {
+			errs = append(errs, fmt.Errorf("%s: strip_prefix and rewrite_prefix are exclusive", where))
+		}
+		if strings.Contains(strings.TrimPrefix(r.Match.Host, "*."), "*") {
+			errs = append(errs, fmt.Errorf("%s: match.host only supports a leading *.", where))
+		}
+		for _, m := range r.Match.Methods {
+			if m == "" || strings.ContainsAny(m, " \t/") {
+				errs = append(errs, fmt.Errorf("%s: invalid method %q", where, m))
+			}
+		}
+		if r.GRPC && c.Listen.GRPCAddr == "" {
+			errs = append(errs, fmt.Errorf("%s: grpc routes need listen.grpc_addr", where))
+		}
+		if _, ok := c.RateLimits[r.RateLimit]; r.RateLimit != "" && !ok {
+			errs = append(errs, fmt.Errorf("%s: rate_limit %q is not defined", where, r.RateLimit))
+		}
+	}
+	if c.DefaultUpstream == "" && len(c.Routes) == 0 {
+		errs = append(errs, errors.New("routes or default_upstream are required"))
+	} else if c.DefaultUpstream != "" && !names[c.DefaultUpstream] {
+		errs = append(errs, fmt.Errorf("default_upstream %q is not defined", c.DefaultUpstream))
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit: %w", err))
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.RateLimits)) {
+		if name == "" {
+			errs = append(errs, errors.New("rate_limits: policy names must not be empty"))
+		} else if err := c.RateLimits[name].validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rate_limits.%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *RateLimitConfig) applyDefaults() {
+	if r.Key == "" {
+		r.Key = "ip"
+	}
+}
+
+func (r RateLimitConfig) validate() error {
+	var errs []error
+	if r.RPS < 0 || r.Burst < 0 || (r.RPS > 0 && r.Burst == 0) {
+		errs = append(errs, errors.New("needs rps >= 0 and, when rps is set, burst >= 1"))
+	}
+	if _, err := parseHashKey(r.Key); err != nil {
+		errs = append(errs, fmt.Errorf("key: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (b BalanceConfig) validate(where string, urls []string) []error {
+	var errs []error
+	if _, err := newStrategy(b.Strategy); err != nil {
+		errs = append(errs, fmt.Errorf("%s: balance.strategy: %w", where, err))
+	}
+	for raw, w := range b.Weights {
+		if !slices.Contains(urls, raw) {
+			errs = append(errs, fmt.Errorf("%s: balance.weights: %q is not one of the upstream's urls", where, raw))
+		}
+		if w < 1 || w > maxWeight {
+			errs = append(errs, fmt.Errorf("%s: balance.weights: weight for %q must be between 1 and %d", where, raw, maxWeight))
+		}
+	}
+	if b.HashKey != "" {
+		if b.Strategy != StrategyConsistentHash {
+			errs = append(errs, fmt.Errorf("%s: balance.hash_key only applies to %s", where, StrategyConsistentHash))
+		} else if _, err := parseHashKey(b.HashKey); err != nil {
+			errs = append(errs, fmt.Errorf("%s: balance.hash_key: %w", where, err))
+		}
+	}
+	return errs
+}
+
+func (h *HealthConfig) applyDefaults() {
+	if h.Type == "" {
+		h.Type = "http"
+	}
+	if h.Path == "" {
+		h.Path = "/health"
+	}
+	h.Method = strings.ToUpper(h.Method)
+	if h.Method == "" {
+		h.Method = "GET"
+	}
+	if h.ExpectedStatus == "" {
+		h.ExpectedStatus = "200-399"
+	}
+	if h.Interval == 0 {
+		h.Interval = 5 * time.Second
+	}
+	if h.Timeout == 0 {
+		h.Timeout = 2 * time.Second
+	}
+	if h.Jitter == 0 {
+		h.Jitter = h.Interval / 10
+	}
+	if h.HealthyThreshold == 0 {
+		h.HealthyThreshold = 2
+	}
+	if h.UnhealthyThreshold == 0 {
+		h.UnhealthyThreshold = 3
+	}
+}
+
+func (h HealthConfig) validate() error {
+	var errs []error
+	switch h.Type {
+	case "http":
+		if ref, err := url.Parse(h.Path); err != nil || ref.Scheme != "" || ref.Host != "" || !strings.HasPrefix(ref.Path, "/") {
+			errs = append(errs, errors.New("path must be an absolute path, optionally with a query"))
+		}
+		if _, _, err := parseStatusRange(h.ExpectedStatus); err != nil {
+			errs = append(errs, err)
+		}
+	case "tcp", "grpc":
+	default:
+		errs = append(errs, fmt.Errorf("unknown type %q: must be http, tcp or grpc", h.Type))
+	}
+	if h.GRPCService != "" && h.Type != "grpc" {
+		errs = append(errs, errors.New("grpc_service only applies to type grpc"))
+	}
+	if h.Interval <= 0 || h.Timeout <= 0 || h.Timeout > h.Interval {
+		errs = append(errs, errors.New("needs 0 < timeout <= interval"))
+	}
+	if h.Jitter < 0 || h.Jitter >= h.Interval {
+		errs = append(errs, errors.New("needs 0 <= jitter < interval"))
+	}
+	if h.HealthyThreshold < 1 || h.UnhealthyThreshold < 1 {
+		errs = append(errs, errors.New("healthy_threshold and unhealthy_threshold must be at least 1"))
+	}
+	return errors.Join(errs...)
+}
+
+// parseStatusRange accepts "200-399" or a single code such as "204".
+func parseStatusRange(s string) (lo, hi int, err error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		b = a
+	}
+	lo, errLo := strconv.Atoi(strings.TrimSpace(a))
+	hi, errHi := strconv.Atoi(strings.TrimSpace(b))
+	if errLo != nil || errHi != nil || lo < 100 || hi > 599 || lo > hi {
+		return 0, 0, fmt.Errorf("expected_status %q must be a status code or a range such as 200-399", s)
+	}
+	return lo, hi, nil
+}
+
+func (r *RetryConfig) applyDefaults(timeout time.Duration) {
+	if r.Attempts == 0 {
+		r.Attempts = 3
+	}
+	if r.OnStatus == nil {
+		r.OnStatus = []int{502, 503, 504}
+	}
+	if r.IdempotencyHeader == "" {
+		r.IdempotencyHeader = "Idempotency-Key"
+	}
+	if r.Backoff == 0 {
+		r.Backoff = 25 * time.Millisecond
+	}
+	if r.BudgetPercent == 0 {
+		r.BudgetPercent = 20
+	}
+	if r.MinRetriesPerSecond == 0 {
+		r.MinRetriesPerSecond = 3
+	}
+	if r.Deadline == 0 {
+		r.Deadline = timeout * time.Duration(r.Attempts)
+	}
+}
+
+func (r RetryConfig) validate() error {
+	switch {
+	case r.Attempts < 1 || r.Attempts > 10:
+		return errors.New("attempts must be between 1 and 10")
+	case r.Backoff < 0:
+		return errors.New("backoff must not be negative")
+	case r.BudgetPercent < 0 || r.MinRetriesPerSecond < 0:
+		return errors.New("budget_percent and min_retries_per_second must not be negative")
+	case r.Deadline <= 0:
+		return errors.New("deadline must be positive")
+	}
+	for _, code := range r.OnStatus {
+		if code != 429 && (code < 500 || code > 599) {
+			return fmt.Errorf("on_status %d must be 429 or a 5xx status", code)
+		}
+	}
+	return nil
+}
+
+func (b *BreakerConfig) applyDefaults(timeout time.Duration) {
+	if b.Window == 0 {
+		b.Window = 30 * time.Second
+	}
+	if b.MinRequests == 0 {
+		b.MinRequests = 20
+	}
+	if b.ErrorRate == 0 {
+		b.ErrorRate = 0.5
+	}
+	if b.SlowCall == 0 {
+		b.SlowCall = timeout / 2
+	}
+	if b.SlowCallRate == 0 {
+		b.SlowCallRate = 0.8
+	}
+	if b.OpenFor == 0 {
+		b.OpenFor = 15 * time.Second
+	}
+	if b.HalfOpenRequests == 0 {
+		b.HalfOpenRequests = 3
+	}
+}
+
+func (b BreakerConfig) validate() error {
+	switch {
+	case b.Window < breakerBuckets*time.Millisecond:
+		return fmt.Errorf("window must be at least %dms", breakerBuckets)
+	case b.MinRequests < 1:
+		return errors.New("min_requests must be at least 1")
+	case b.ErrorRate <= 0 || b.ErrorRate > 1 || b.SlowCallRate <= 0 || b.SlowCallRate > 1:
+		return errors.New("error_rate and slow_call_rate must be in (0, 1]")
+	case b.SlowCall <= 0:
+		return errors.New("slow_call must be positive")
+	case b.OpenFor <= 0:
+		return errors.New("open_for must be positive")
+	case b.HalfOpenRequests < 1:
+		return errors.New("half_open_requests must be at least 1")
+	}
+	return nil
+}
+
+func (o *OutlierConfig) applyDefaults() {
+	if o.ConsecutiveFailures == 0 {
+		o.ConsecutiveFailures = 5
+	}
+	if o.ErrorRate == 0 {
+		o.ErrorRate = 0.5
+	}
+	if o.MinRequests == 0 {
+		o.MinRequests = 20
+	}
+	if o.Window == 0 {
+		o.Window = 30 * time.Second
+	}
+	if o.BaseEjection == 0 {
+		o.BaseEjection = 30 * time.Second
+	}
+	if o.MaxEjection == 0 {
+		o.MaxEjection = 5 * time.Minute
+	}
+	if o.MaxEjectionPercent == 0 {
+		o.MaxEjectionPercent = 50
+	}
+	if o.RampUp == 0 {
+		o.RampUp = 30 * time.Second
+	}
+}
+
+func (o OutlierConfig) validate() error {
+	switch {
+	case o.ConsecutiveFailures < 1:
+		return errors.New("consecutive_failures must be at least 1")
+	case o.ErrorRate <= 0 || o.ErrorRate > 1:
+		return errors.New("error_rate must be in (0, 1]")
+	case o.MinRequests < 1:
+		return errors.New("min_requests must be at least 1")
+	case o.Window <= 0:
+		return errors.New("window must be positive")
+	case o.BaseEjection <= 0 || o.MaxEjection < o.BaseEjection:
+		return errors.New("needs 0 < base_ejection <= max_ejection")
+	case o.MaxEjectionPercent < 1 || o.MaxEjectionPercent > 100:
+		return errors.New("max_ejection_percent must be between 1 and 100")
+	case o.RampUp < 0:
+		return errors.New("ramp_up must not be negative")
+	}
+	return nil
+}
+
+func validateBackendURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %v", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url %q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("url %q has no host", raw)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return fmt.Errorf("url %q must not have a path or query", raw)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const baseConfig = `
+listen: {addr: ":0"}
+rate_limit: {rps: 5, burst: 10}
+rate_limits:
+  api: {rps: 20, burst: 40, key: "header:Authorization"}
+upstreams:
+  - name: app
+    urls: [http://127.0.0.1:1]
+    health_check: {type: tcp}
+routes:
+  - name: api
+    match: {path_prefix: /api}
+    upstream: app
+    rate_limit: api
+`
+
+func writeConfig(t *testing.T, dir, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, "gateway.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExampleConfigLoads(t *testing.T) {
+	cfg, err := LoadConfig("gateway.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RateLimit.Key != "ip" {
+		t.Fatalf("default rate limit key %q, want ip", cfg.RateLimit.Key)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(string) string
+		want string
+	}{
+		{"unknown field", func(s string) string { return s + "bogus: 1\n" }, "field bogus not found"},
+		{"route names an undefined policy", func(s string) string {
+			return strings.Replace(s, "rate_limit: api", "rate_limit: missing", 1)
+		}, `route "api": rate_limit "missing" is not defined`},
+		{"policy without burst", func(s string) string {
+			return strings.Replace(s, "{rps: 20, burst: 40,", "{rps: 20,", 1)
+		}, "rate_limits.api: needs rps >= 0"},
+		{"policy with a bad key", func(s string) string {
+			return strings.Replace(s, "header:Authorization", "query:token", 1)
+		}, `rate_limits.api: key: "query:token" must be ip`},
+		{"negative default rps", func(s string) string {
+			return strings.Replace(s, "{rps: 5, burst: 10}", "{rps: -1, burst: 10}", 1)
+		}, "rate_limit: needs rps >= 0"},
+		{"route to an undefined upstream", func(s string) string {
+			return strings.Replace(s, "upstream: app", "upstream: nowhere", 1)
+		}, `upstream "nowhere" is not defined`},
+		{"backend url with a path", func(s string) string {
+			return strings.Replace(s, "http://127.0.0.1:1", "http://127.0.0.1:1/api", 1)
+		}, "must not have a path or query"},
+		{"bad expected status", func(s string) string {
+			return strings.Replace(s, "{type: tcp}", "{expected_status: 299-200}", 1)
+		}, "expected_status"},
+		{"grpc route without a grpc listener", func(s string) string {
+			return strings.Replace(s, "    rate_limit: api\n", "    grpc: true\n", 1)
+		}, "grpc routes need listen.grpc_addr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, t.TempDir(), tt.edit(baseConfig)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := LoadConfig(writeConfig(t, t.TempDir(), baseConfig)); err != nil {
+		t.Fatalf("base config rejected: %v", err)
+	}
+}
+
+func TestRejectedReloadKeepsConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, baseConfig)
+	gw, err := NewGateway(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(gw.Stop)
+
+	writeConfig(t, dir, strings.Replace(baseConfig, "rate_limit: api", "rate_limit: missing", 1))
+	if err := gw.Reload(); err == nil {
+		t.Fatal("invalid config was accepted")
+	}
+	if got := gw.Config().Routes[0].RateLimit; got != "api" {
+		t.Fatalf("route uses policy %q after a rejected reload, want api", got)
+	}
+
+	writeConfig(t, dir, strings.Replace(baseConfig, "{rps: 20, burst: 40,", "{rps: 1, burst: 1,", 1))
+	if err := gw.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	client := keyOf("10.0.0.1", "tok")
+	if !gw.limiter.Allow("api", client) || gw.limiter.Allow("api", client) {
+		t.Fatal("reloaded policy is not in force")
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const configCheckInterval = 2 * time.Second
+
+type upstream struct {
+	cfg    UpstreamConfig
+	lb     *LoadBalancer
+	key    hashKey
+	client *fasthttp.Client
+	h2     *http.Transport
+	health *healthChecker
+	budget *retryBudget
+}
+
+// gatewayState is immutable once published; a reload builds a new one and
+// swaps it in, so a request sees one consistent config from start to end.
+type gatewayState struct {
+	cfg       *Config
+	upstreams map[string]*upstream
+	routes    []*route
+}
+
+type Gateway struct {
+	path    string
+	limiter *RateLimiter
+	state   atomic.Pointer[gatewayState]
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// NewGateway loads the config at path, or DefaultConfig when path is empty.
+func NewGateway(path string) (*Gateway, error) {
+	cfg := DefaultConfig()
+	var mod time.Time
+	if path != "" {
+		mod = fileModTime(path)
+		var err error
+		if cfg, err = LoadConfig(path); err != nil {
+			return nil, err
+		}
+	}
+	g := &Gateway{path: path, modTime: mod, limiter: NewRateLimiter(cfg)}
+	if err := g.apply(cfg); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *Gateway) current() *gatewayState {
+	return g.state.Load()
+}
+
+func (g *Gateway) Config() *Config {
+	return g.current().cfg
+}
+
+// Reload re-reads the config file. An invalid file is rejected as a whole
+// and the running config stays in place.
+func (g *Gateway) Reload() error {
+	if g.path == "" {
+		return nil
+	}
+	g.mu.Lock()
+	g.modTime = fileModTime(g.path)
+	g.mu.Unlock()
+	cfg, err := LoadConfig(g.path)
+	if err != nil {
+		return err
+	}
+	if old := g.Config(); old.Listen != cfg.Listen || old.Admin != cfg.Admin {
+		log.Printf("[config] listener settings changed; restart to apply them")
+		cfg.Listen, cfg.Admin = old.Listen, old.Admin
+	}
+	return g.apply(cfg)
+}
+
+func (g *Gateway) apply(cfg *Config) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	prev := g.current()
+	next := &gatewayState{cfg: cfg, upstreams: make(map[string]*upstream, len(cfg.Upstreams))}
+	// Build every client first: that is the only step that can fail, and
+	// nothing shared has been touched yet if it does.
+	clients := make([]*fasthttp.Client, len(cfg.Upstreams))
+	for i, uc := range cfg.Upstreams {
+		c, err := upstreamClient(uc, prev.lookup(uc.Name))
+		if err != nil {
+			return fmt.Errorf("upstream %q: %w", uc.Name, err)
+		}
+		clients[i] = c
+	}
+	for i, uc := range cfg.Upstreams {
+		u := &upstream{cfg: uc, client: clients[i]}
+		if old := prev.lookup(uc.Name); old != nil && old.client == u.client {
+			u.h2 = old.h2
+		} else {
+			u.h2 = newH2Transport(u.client.TLSConfig)
+		}
+		if old := prev.lookup(uc.Name); old != nil {
+			u.lb, u.budget = old.lb, old.budget
+			u.lb.Update(uc.Balance, uc.URLs)
+		} else {
+			u.lb = NewLoadBalancer(uc.Name, uc.Balance, uc.URLs)
+			u.budget = &retryBudget{}
+		}
+		u.lb.SetOutlierDetection(uc.Outlier)
+		u.lb.SetCircuitBreaker(uc.Breaker)
+		if uc.Balance.HashKey != "" {
+			u.key, _ = parseHashKey(uc.Balance.HashKey)
+		}
+		u.health = startHealthChecks(u.lb, u.client, uc.HealthCheck)
+		next.upstreams[uc.Name] = u
+	}
+	next.routes = compileRoutes(cfg.Routes, next.upstreams)
+	g.limiter.Update(cfg)
+	g.state.Store(next)
+	if prev != nil {
+		for name, u := range prev.upstreams {
+			u.health.Stop()
+			if n, ok := next.upstreams[name]; !ok || n.client != u.client {
+				u.client.CloseIdleConnections()
+				u.h2.CloseIdleConnections()
+			}
+		}
+	}
+	log.Printf("[config] %d upstreams, %d routes, default %q", len(next.upstreams), len(next.routes), cfg.DefaultUpstream)
+	return nil
+}
+
+func (st *gatewayState) lookup(name string) *upstream {
+	if st == nil {
+		return nil
+	}
+	return st.upstreams[name]
+}
+
+// upstreamClient reuses the previous client, and with it the pooled
+// connections, when its settings are unchanged. Load balancers are always
+// reused so backend health carries over a reload.
+func upstreamClient(cfg UpstreamConfig, prev *upstream) (*fasthttp.Client, error) {
+	if prev != nil && prev.cfg.TLS == cfg.TLS && prev.cfg.Timeout == cfg.Timeout {
+		return prev.client, nil
+	}
+	tlsCfg, err := backendTLSConfig(cfg.TLS.CA, cfg.TLS.Cert, cfg.TLS.Key, cfg.TLS.ServerName)
+	if err != nil {
+		return nil, err
+	}
+	// With StreamResponseBody, MaxResponseBodySize is only the size up to
+	// which a body is read before Do returns; larger ones are streamed.
+	return &fasthttp.Client{
+		TLSConfig:           tlsCfg,
+		Dial:                dialIdle,
+		StreamResponseBody:  true,
+		MaxResponseBodySize: responseBufferSize,
+		ConfigureClient: func(hc *fasthttp.HostClient) error {
+			if hc.IsTLS {
+				hc.Dial = dialIdleTLS(hc.TLSConfig, cfg.Timeout)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// Watch reloads on a value from reload (wired to SIGHUP) or when the config
+// file's modification time changes.
+func (g *Gateway) Watch(ctx context.Context, reload <-chan struct{}) {
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+		case <-ticker.C:
+			if g.path == "" {
+				continue
+			}
+			g.mu.Lock()
+			changed := fileModTime(g.path).After(g.modTime)
+			g.mu.Unlock()
+			if !changed {
+				continue
+			}
+		}
+		if err := g.Reload(); err != nil {
+			log.Printf("[config] reload rejected, keeping current config: %v", err)
+		}
+	}
+}
+
+func (g *Gateway) Stop() {
+	for _, u := range g.current().upstreams {
+		u.health.Stop()
+	}
+	g.limiter.Stop()
+}
+
+func fileModTime(path string) time.Time {
+	if st, err := os.Stat(path); err == nil {
+		return st.ModTime()
+	}
+	return time.Time{}
+}
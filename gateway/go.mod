@@ -6,6 +6,9 @@ require (
 	github.com/gofiber/fiber/v2 v2.52.9
 	github.com/valyala/fasthttp v1.68.0
 	golang.org/x/time v0.14.0
+	google.golang.org/grpc v1.75.1
+	gopkg.in/yaml.v3 v3.0.1
+	payments-core v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -17,5 +20,11 @@ require (
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	golang.org/x/net v0.46.0 // indirect
 	golang.org/x/sys v0.37.0 // indirect
+	golang.org/x/text v0.30.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+	google.golang.org/protobuf v1.36.9 // indirect
 )
+
+replace payments-core => ../core
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// newH2Transport speaks only HTTP/2 to backends: h2c with prior knowledge
+// for http:// URLs and TLS with ALPN h2 for https:// ones.
+func newH2Transport(tlsCfg *tls.Config) *http.Transport {
+	t := &http.Transport{
+		TLSClientConfig: tlsCfg,
+		IdleConnTimeout: 90 * time.Second,
+		Protocols:       new(http.Protocols),
+	}
+	t.Protocols.SetHTTP2(true)
+	t.Protocols.SetUnencryptedHTTP2(true)
+	return t
+}
+
+// NewGRPCServer serves the routes marked grpc over HTTP/2, cleartext h2c
+// unless the caller serves it with TLS.
+func NewGRPCServer(p *Proxy) *http.Server {
+	srv := &http.Server{Handler: http.HandlerFunc(p.ServeGRPC), Protocols: new(http.Protocols)}
+	srv.Protocols.SetHTTP2(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	return srv
+}
+
+// ServeGRPC proxies one RPC. Both bodies stream and trailers pass through,
+// so streaming RPCs work; grpc-timeout is passed on and is the only
+// deadline. Failures before the backend answers are reported to the client
+// as gRPC statuses. RPCs are never retried.
+func (p *Proxy) ServeGRPC(w http.ResponseWriter, r *http.Request) {
+	st := p.gw.current()
+	up, path, policy := st.pick(r.Host, r.Method, cleanPath(r.URL.Path), r.Header.Get, true)
+	if !p.gw.limiter.Allow(policy, func(k hashKey) string { return k.valueHTTP(r) }) {
+		grpcError(w, codes.ResourceExhausted, "rate limit exceeded")
+		return
+	}
+	if up == nil {
+		grpcError(w, codes.Unimplemented, "no route")
+		return
+	}
+	backend := up.lb.Acquire(up.key.valueHTTP(r), nil)
+	if backend == nil {
+		grpcError(w, codes.Unavailable, "no backend available")
+		return
+	}
+	target, err := url.Parse(backend.URL)
+	if err != nil {
+		grpcError(w, codes.Internal, "bad backend url")
+		return
+	}
+	start := time.Now()
+	var latency time.Duration
+	var proxyErr error
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path, pr.Out.URL.RawPath = path, ""
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+		},
+		Transport:     up.h2,
+		FlushInterval: -1,
+		ModifyResponse: func(*http.Response) error {
+			// Streams can stay open indefinitely: latency is the time to
+			// the response headers.
+			latency = time.Since(start)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			proxyErr = err
+			log.Printf("[grpc] error %s: %v", backend.URL, err)
+			grpcError(w, codes.Unavailable, "backend error")
+		},
+	}
+	backend.acquire()
+	// Deferred: ReverseProxy panics with http.ErrAbortHandler when a stream
+	// breaks after the headers were sent. That is a backend failure unless
+	// the client went away, which says nothing about the backend.
+	defer func() {
+		aborted := recover()
+		if latency == 0 {
+			latency = time.Since(start)
+		}
+		backend.release(latency)
+		if aborted == nil || r.Context().Err() == nil {
+			up.lb.Report(backend, aborted == nil && proxyErr == nil && grpcStatus(w.Header()) != codes.Unavailable, latency)
+		} else {
+			up.lb.Release(backend)
+		}
+		if aborted != nil {
+			panic(aborted)
+		}
+	}()
+	rp.ServeHTTP(w, r)
+}
+
+// grpcStatus reads grpc-status from a trailers-only response or from the
+// trailers copied after the body.
+func grpcStatus(h http.Header) codes.Code {
+	v := h.Get("Grpc-Status")
+	if v == "" {
+		v = h.Get(http.TrailerPrefix + "Grpc-Status")
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return codes.Unknown
+	}
+	return codes.Code(n)
+}
+
+// grpcError writes a trailers-only response, which gRPC clients read as the
+// status of the call.
+func grpcError(w http.ResponseWriter, code codes.Code, msg string) {
+	h := w.Header()
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Status", strconv.Itoa(int(code)))
+	h.Set("Grpc-Message", msg)
+	w.WriteHeader(http.StatusOK)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -1,38 +1,203 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
-func startHealthChecks(lb *LoadBalancer) {
-	client := &fasthttp.Client{ReadTimeout: BackendTimeout, WriteTimeout: BackendTimeout}
-	ticker := time.NewTicker(BackendHealthFreq)
-	go func() {
-		for range ticker.C {
-			for _, be := range lb.Backends() {
-				go func(b *Backend) {
-					req := fasthttp.AcquireRequest()
-					resp := fasthttp.AcquireResponse()
-					defer fasthttp.ReleaseRequest(req)
-					defer fasthttp.ReleaseResponse(resp)
-					req.SetRequestURI(b.URL + "/health")
-					req.Header.SetMethod("GET")
-					if err := client.Do(req, resp); err != nil {
-						b.setAlive(false)
-						log.Printf("[health] %s down: %v", b.URL, err)
-						return
-					}
-					if resp.StatusCode() >= 200 && resp.StatusCode() < 500 {
-						b.setAlive(true)
-					} else {
-						b.setAlive(false)
-						log.Printf("[health] %s unhealthy %d", b.URL, resp.StatusCode())
-					}
-				}(be)
+// healthChecker runs one probe loop per backend. Stop cancels them and
+// waits for any probe in flight to finish.
+type healthChecker struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type probeFunc func(ctx context.Context) error
+
+func startHealthChecks(lb *LoadBalancer, client *fasthttp.Client, hc HealthConfig) *healthChecker {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &healthChecker{cancel: cancel}
+	for _, b := range lb.Backends() {
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			runHealthChecks(ctx, lb.Name, b, client, hc)
+		}()
+	}
+	return h
+}
+
+func (h *healthChecker) Stop() {
+	h.cancel()
+	h.wg.Wait()
+}
+
+func runHealthChecks(ctx context.Context, pool string, b *Backend, client *fasthttp.Client, hc HealthConfig) {
+	probe, closeProbe, err := newProbe(b, client, hc)
+	if err != nil {
+		log.Printf("[health] %s %s: cannot probe: %v", pool, b.URL, err)
+		return
+	}
+	defer closeProbe()
+	// The first probe goes out within one jitter period so a reload or a
+	// new backend is checked promptly.
+	delay := jitter(hc.Jitter)
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		delay = hc.Interval + jitter(hc.Jitter)
+
+		probeCtx, cancel := context.WithTimeout(ctx, hc.Timeout)
+		err := probe(probeCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		changed, n := b.recordProbe(err == nil, hc)
+		switch {
+		case changed && err == nil:
+			log.Printf("[health] %s %s up after %d passing checks", pool, b.URL, n)
+		case changed:
+			log.Printf("[health] %s %s down after %d failed checks: %v", pool, b.URL, n, err)
+		}
+	}
+}
+
+// recordProbe counts a probe result and flips the backend's health once the
+// run of passes or failures reaches its threshold. It returns whether the
+// health changed and the length of the run. The counts live on the backend,
+// like its health, because a reload restarts every probe loop.
+func (b *Backend) recordProbe(ok bool, hc HealthConfig) (bool, int64) {
+	if ok {
+		b.failures.Store(0)
+		n := b.passes.Add(1)
+		if !b.isHealthy() && n >= int64(hc.HealthyThreshold) {
+			b.setAlive(true)
+			return true, n
+		}
+		return false, n
+	}
+	b.passes.Store(0)
+	n := b.failures.Add(1)
+	if b.isHealthy() && n >= int64(hc.UnhealthyThreshold) {
+		b.setAlive(false)
+		return true, n
+	}
+	return false, n
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
+
+func newProbe(b *Backend, client *fasthttp.Client, hc HealthConfig) (probeFunc, func(), error) {
+	u, err := url.Parse(b.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	switch hc.Type {
+	case "tcp":
+		return tcpProbe(addr), func() {}, nil
+	case "grpc":
+		creds := insecure.NewCredentials()
+		if u.Scheme == "https" {
+			tlsCfg := client.TLSConfig
+			if tlsCfg == nil {
+				tlsCfg = &tls.Config{}
 			}
+			creds = credentials.NewTLS(tlsCfg)
+		}
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, nil, err
+		}
+		return grpcProbe(conn, hc.GRPCService), func() { conn.Close() }, nil
+	}
+	lo, hi, err := parseStatusRange(hc.ExpectedStatus)
+	if err != nil {
+		return nil, nil, err
+	}
+	ref, err := url.Parse(hc.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return httpProbe(client, u.ResolveReference(ref).String(), hc, lo, hi), func() {}, nil
+}
+
+func httpProbe(client *fasthttp.Client, target string, hc HealthConfig, lo, hi int) probeFunc {
+	return func(ctx context.Context) error {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI(target)
+		req.Header.SetMethod(hc.Method)
+		deadline, _ := ctx.Deadline()
+		if err := client.DoDeadline(req, resp, deadline); err != nil {
+			return err
+		}
+		if code := resp.StatusCode(); code < lo || code > hi {
+			return fmt.Errorf("status %d outside %s", code, hc.ExpectedStatus)
+		}
+		if hc.BodyContains != "" && !bytes.Contains(resp.Body(), []byte(hc.BodyContains)) {
+			return fmt.Errorf("body does not contain %q", hc.BodyContains)
+		}
+		return nil
+	}
+}
+
+func tcpProbe(addr string) probeFunc {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+func grpcProbe(conn *grpc.ClientConn, service string) probeFunc {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return errors.New(resp.Status.String())
 		}
-	}()
+		return nil
+	}
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestHealthThresholds(t *testing.T) {
+	hc := HealthConfig{HealthyThreshold: 2, UnhealthyThreshold: 3}
+	b := newBackend("http://10.2.0.1:8080")
+	steps := []struct {
+		ok      bool
+		healthy bool
+		changed bool
+	}{
+		{false, true, false},
+		{false, true, false},
+		{true, true, false}, // a pass breaks the run of failures
+		{false, true, false},
+		{false, true, false},
+		{false, false, true},
+		{false, false, false},
+		{true, false, false},
+		{false, false, false}, // a failure breaks the run of passes
+		{true, false, false},
+		{true, true, true},
+		{true, true, false},
+	}
+	for i, s := range steps {
+		changed, _ := b.recordProbe(s.ok, hc)
+		if b.isHealthy() != s.healthy || changed != s.changed {
+			t.Fatalf("step %d: healthy %v changed %v, want %v and %v", i+1, b.isHealthy(), changed, s.healthy, s.changed)
+		}
+	}
+}
+
+func TestHealthCountsSurviveReload(t *testing.T) {
+	var probes atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	hc := HealthConfig{Type: "http", Path: "/health", Method: "GET", ExpectedStatus: "200-399",
+		Interval: time.Millisecond, Timeout: time.Millisecond * 500, HealthyThreshold: 1, UnhealthyThreshold: 4}
+	lb := NewLoadBalancer("test", BalanceConfig{Strategy: StrategyRoundRobin}, []string{srv.URL})
+	b := lb.Backends()[0]
+	client := &fasthttp.Client{}
+
+	// Restart the checker after every probe, as back-to-back reloads would.
+	for range 4 {
+		n := b.failures.Load()
+		h := startHealthChecks(lb, client, hc)
+		for b.failures.Load() == n {
+			time.Sleep(time.Millisecond)
+		}
+		h.Stop()
+	}
+	if b.isHealthy() {
+		t.Fatalf("backend still up after %d failed probes across reloads", probes.Load())
+	}
+}
+
+func TestHTTPProbeTarget(t *testing.T) {
+	paths := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case paths <- r.URL.RequestURI():
+		default:
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	for _, tt := range []struct{ url, path, want string }{
+		{srv.URL, "/health", "/health"},
+		{srv.URL + "/", "/health", "/health"},
+		{srv.URL + "/", "/ready?full=1", "/ready?full=1"},
+	} {
+		hc := HealthConfig{Type: "http", Path: tt.path, Method: "GET", ExpectedStatus: "200"}
+		probe, closeProbe, err := newProbe(&Backend{URL: tt.url}, &fasthttp.Client{}, hc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err = probe(ctx)
+		cancel()
+		closeProbe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := <-paths; got != tt.want {
+			t.Fatalf("%s + %s requested %s, want %s", tt.url, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseStatusRange(t *testing.T) {
+	for _, tt := range []struct {
+		in     string
+		lo, hi int
+	}{
+		{"200-399", 200, 399},
+		{"204", 204, 204},
+		{" 200 - 299 ", 200, 299},
+		{"100-599", 100, 599},
+	} {
+		lo, hi, err := parseStatusRange(tt.in)
+		if err != nil || lo != tt.lo || hi != tt.hi {
+			t.Fatalf("%q: got %d-%d, %v, want %d-%d", tt.in, lo, hi, err, tt.lo, tt.hi)
+		}
+	}
+	for _, in := range []string{"", "2xx", "399-200", "99", "600", "200-600", "200-", "-399", "200-300-400"} {
+		if _, _, err := parseStatusRange(in); err == nil {
+			t.Fatalf("%q was accepted", in)
+		}
+	}
+}
+
+func TestHTTPProbeStatusAndBody(t *testing.T) {
+	status, body := http.StatusOK, "ok"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	hc := HealthConfig{Type: "http", Path: "/health", Method: "GET", ExpectedStatus: "200-299", BodyContains: "ok"}
+	probe, closeProbe, err := newProbe(&Backend{URL: srv.URL}, &fasthttp.Client{}, hc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(closeProbe)
+
+	for _, tt := range []struct {
+		status int
+		body   string
+		ok     bool
+	}{
+		{200, "ok", true},
+		{204, "", false},
+		{299, "all ok", true},
+		{301, "ok", false},
+		{503, "ok", false},
+		{200, "degraded", false},
+	} {
+		status, body = tt.status, tt.body
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := probe(ctx)
+		cancel()
+		if (err == nil) != tt.ok {
+			t.Fatalf("status %d body %q: got %v, want ok %v", tt.status, tt.body, err, tt.ok)
+		}
+	}
+}
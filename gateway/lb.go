@@ -1,61 +1,182 @@
 package main
 
 import (
-	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// latencyDecay weights each new sample at 1/latencyDecay of the moving
+// average.
+const latencyDecay = 8
+
+// Backend state is read on every request, so it is kept in atomics rather
+// than behind a lock.
 type Backend struct {
-	URL       string
-	Alive     bool
-	lastError time.Time
-	mutex     sync.RWMutex
+	URL    string
+	Weight int
+
+	alive     atomic.Bool
+	passes    atomic.Int64 // active probes passed in a row
+	failures  atomic.Int64 // active probes failed in a row
+	lastError atomic.Int64 // unix ns of the last failed request
+	inflight  atomic.Int64
+	latency   atomic.Int64 // moving average in ns, 0 before the first response
+
+	ejected   atomic.Bool
+	rampStart atomic.Int64 // unix ns an ejection ended, 0 once fully back
+	rampFor   atomic.Int64
+	outlier   outlierStats
+	breaker   breaker
+}
+
+func newBackend(url string) *Backend {
+	b := &Backend{URL: url}
+	b.alive.Store(true)
+	return b
 }
 
 func (b *Backend) setAlive(alive bool) {
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
-	b.Alive = alive
+	b.alive.Store(alive)
+}
+
+// isHealthy reports the active health check result alone.
+func (b *Backend) isHealthy() bool {
+	return b.alive.Load()
 }
 
+// isAlive reports whether the backend may take this request: it passes
+// active health checks, is not ejected, its circuit is not open, and, while
+// ramping up after an ejection, wins the draw for its current share of
+// traffic.
 func (b *Backend) isAlive() bool {
-	b.mutex.RLock()
-	defer b.mutex.RUnlock()
-	return b.Alive
+	if !b.alive.Load() || b.ejected.Load() || !b.breaker.available() {
+		return false
+	}
+	if start := b.rampStart.Load(); start != 0 {
+		return b.admitRamping(start)
+	}
+	return true
+}
+
+// acquire and release bracket every proxied request so strategies can see
+// the backend's load and response time.
+func (b *Backend) acquire() {
+	b.inflight.Add(1)
 }
 
+func (b *Backend) release(elapsed time.Duration) {
+	b.inflight.Add(-1)
+	for {
+		old := b.latency.Load()
+		next := int64(elapsed)
+		if old != 0 {
+			next = old + (next-old)/latencyDecay
+		}
+		if b.latency.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// LoadBalancer publishes its backend list and strategy together as an
+// immutable pool. Selection only loads the pointer; Update builds a new pool
+// and swaps it in.
 type LoadBalancer struct {
+	Name    string
+	pool    atomic.Pointer[pool]
+	outlier atomic.Pointer[OutlierConfig] // nil when disabled
+	breaker atomic.Pointer[BreakerConfig] // nil when disabled
+	mu      sync.Mutex                    // serialises Update and ejections
+}
+
+type pool struct {
 	backends []*Backend
-	idx      int
-	mutex    sync.Mutex
+	strategy Strategy
+}
+
+func NewLoadBalancer(name string, bc BalanceConfig, urls []string) *LoadBalancer {
+	lb := &LoadBalancer{Name: name}
+	lb.Update(bc, urls)
+	return lb
 }
 
-func NewLoadBalancer() *LoadBalancer {
-	b := &LoadBalancer{backends: make([]*Backend, 0, BackendCount)}
-	for i := 0; i < BackendCount; i++ {
-		port := BackendBasePort + i
-		url := fmt.Sprintf("http://127.0.0.1:%d", port)
-		backend := &Backend{URL: url, Alive: true}
-		b.backends = append(b.backends, backend)
+// Update replaces the backend list and strategy in one step. Backends whose
+// URL is kept retain their health and load state; requests already sent to
+// a removed backend finish normally.
+func (lb *LoadBalancer) Update(bc BalanceConfig, urls []string) {
+	strategy, err := newStrategy(bc.Strategy)
+	if err != nil {
+		// Unreachable for a validated config.
+		strategy = &roundRobin{}
 	}
-	return b
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	existing := map[string]*Backend{}
+	if old := lb.pool.Load(); old != nil {
+		for _, b := range old.backends {
+			existing[b.URL] = b
+		}
+	}
+	backends := make([]*Backend, 0, len(urls))
+	for _, raw := range urls {
+		url := strings.TrimRight(raw, "/")
+		b, ok := existing[url]
+		if !ok {
+			b = newBackend(url)
+		}
+		b.Weight = max(bc.Weights[raw], 1)
+		backends = append(backends, b)
+	}
+	strategy.Update(backends)
+	lb.pool.Store(&pool{backends: backends, strategy: strategy})
 }
 
-func (lb *LoadBalancer) NextBackend() *Backend {
-	lb.mutex.Lock()
-	defer lb.mutex.Unlock()
-	n := len(lb.backends)
-	for i := 0; i < n; i++ {
-		lb.idx = (lb.idx + 1) % n
-		candidate := lb.backends[lb.idx]
-		if candidate.isAlive() {
-			return candidate
+// NextBackend returns a live backend, or nil when none is. key is only used
+// by the consistent_hash strategy.
+func (lb *LoadBalancer) NextBackend(key string) *Backend {
+	p := lb.pool.Load()
+	if b := p.strategy.Next(key); b != nil {
+		return b
+	}
+	// Every candidate was down or lost its ramp-up draw; a ramping backend
+	// is still better than failing the request.
+	for _, b := range p.backends {
+		if b.isHealthy() && !b.ejected.Load() {
+			return b
 		}
 	}
 	return nil
 }
 
+// Acquire picks a backend for a request, avoiding those already tried and
+// any whose circuit breaker turns the request away.
+func (lb *LoadBalancer) Acquire(key string, tried []*Backend) *Backend {
+	skip := tried
+	for range len(lb.Backends()) {
+		var b *Backend
+		if len(skip) == 0 {
+			b = lb.NextBackend(key)
+		} else {
+			b = lb.NextBackendExcluding(key, skip)
+		}
+		if b == nil || lb.admit(b) {
+			return b
+		}
+		skip = append(skip[:len(skip):len(skip)], b)
+	}
+	return nil
+}
+
+// Report records the outcome of a proxied request: ok is false for a
+// transport error or a 5xx response.
+func (lb *LoadBalancer) Report(b *Backend, ok bool, elapsed time.Duration) {
+	lb.recordBreaker(b, ok, elapsed)
+	lb.recordOutlier(b, ok)
+}
+
+// Backends returns the current list; callers must not modify it.
 func (lb *LoadBalancer) Backends() []*Backend {
-	return lb.backends
+	return lb.pool.Load().backends
 }
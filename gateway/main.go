@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,34 +16,105 @@ import (
 )
 
 func main() {
-	app := fiber.New(fiber.Config{IdleTimeout: 10 * time.Second})
-	lb := NewLoadBalancer()
-	startHealthChecks(lb)
-	proxy := NewProxy(lb)
-	ipLimiter := NewIPRateLimiter(RateLimitRPS, RateLimitBurst)
-	app.Use(func(c *fiber.Ctx) error {
-		ip := c.IP()
-		if ip == "" {
-			ip = "unknown"
-		}
-		if !ipLimiter.Allow(ip) {
-			return c.Status(fiber.StatusTooManyRequests).SendString("rate limit exceeded")
-		}
-		return c.Next()
+	configPath := flag.String("config", os.Getenv("GATEWAY_CONFIG"), "path to a YAML or JSON config file")
+	flag.Parse()
+
+	gw, err := NewGateway(*configPath)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	cfg := gw.Config()
+	app := fiber.New(fiber.Config{
+		IdleTimeout:                  cfg.Listen.IdleTimeout,
+		BodyLimit:                    cfg.Listen.MaxBufferedBody,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
+	proxy := NewProxy(gw)
 	app.All("/*", func(c *fiber.Ctx) error {
 		return proxy.Handle(c)
 	})
+
+	ctx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	reload := make(chan struct{}, 1)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			select {
+			case reload <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	go gw.Watch(ctx, reload)
+
+	var admin *fiber.App
+	if cfg.Admin.Addr != "" {
+		admin = NewAdminApp(gw)
+		go func() {
+			log.Printf("Admin listening on %s", cfg.Admin.Addr)
+			if err := admin.Listen(cfg.Admin.Addr); err != nil {
+				log.Fatalf("admin start failed: %v", err)
+			}
+		}()
+	}
+
+	var grpcSrv *http.Server
+	if cfg.Listen.GRPCAddr != "" {
+		grpcSrv = NewGRPCServer(proxy)
+		go func() {
+			log.Printf("gRPC listening on %s", cfg.Listen.GRPCAddr)
+			ln, err := net.Listen("tcp", cfg.Listen.GRPCAddr)
+			if err != nil {
+				log.Fatalf("grpc start failed: %v", err)
+			}
+			if cfg.Listen.TLS.Cert != "" {
+				tlsCfg, err := listenerTLSConfig(cfg.Listen.TLS.Cert, cfg.Listen.TLS.Key, cfg.Listen.TLS.ClientCA)
+				if err != nil {
+					log.Fatalf("grpc listener tls: %v", err)
+				}
+				tlsCfg.NextProtos = []string{"h2"}
+				ln = tls.NewListener(ln, tlsCfg)
+			}
+			if err := grpcSrv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("grpc start failed: %v", err)
+			}
+		}()
+	}
+
 	go func() {
-		log.Printf("Proxy listening on %s -> %d-%d", ListenAddr, BackendBasePort, BackendBasePort+BackendCount-1)
-		if err := app.Listen(ListenAddr); err != nil {
+		log.Printf("Proxy listening on %s: %d routes, %d upstreams", cfg.Listen.Addr, len(cfg.Routes), len(cfg.Upstreams))
+		if cfg.Listen.TLS.Cert == "" {
+			if err := app.Listen(cfg.Listen.Addr); err != nil {
+				log.Fatalf("start failed: %v", err)
+			}
+			return
+		}
+		tlsCfg, err := listenerTLSConfig(cfg.Listen.TLS.Cert, cfg.Listen.TLS.Key, cfg.Listen.TLS.ClientCA)
+		if err != nil {
+			log.Fatalf("listener tls: %v", err)
+		}
+		ln, err := tls.Listen("tcp", cfg.Listen.Addr, tlsCfg)
+		if err != nil {
+			log.Fatalf("start failed: %v", err)
+		}
+		if err := app.Listener(ln); err != nil {
 			log.Fatalf("start failed: %v", err)
 		}
 	}()
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 	<-sig
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	app.ShutdownWithContext(ctx)
+	app.ShutdownWithContext(shutdownCtx)
+	if admin != nil {
+		admin.ShutdownWithContext(shutdownCtx)
+	}
+	if grpcSrv != nil {
+		grpcSrv.Shutdown(shutdownCtx)
+	}
+	gw.Stop()
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// minRampShare is the share of traffic a backend gets as soon as it returns
+// from an ejection.
+const minRampShare = 0.1
+
+type outlierStats struct {
+	consecutive atomic.Int64
+	requests    atomic.Int64
+	failures    atomic.Int64
+	windowStart atomic.Int64
+
+	// Guarded by LoadBalancer.mu.
+	ejections  int
+	returnedAt time.Time
+}
+
+func (lb *LoadBalancer) SetOutlierDetection(oc OutlierConfig) {
+	if oc.Disabled {
+		lb.outlier.Store(nil)
+		return
+	}
+	lb.outlier.Store(&oc)
+}
+
+func (lb *LoadBalancer) recordOutlier(b *Backend, ok bool) {
+	oc := lb.outlier.Load()
+	if oc == nil {
+		return
+	}
+	s := &b.outlier
+	now := time.Now().UnixNano()
+	if start := s.windowStart.Load(); now-start >= int64(oc.Window) && s.windowStart.CompareAndSwap(start, now) {
+		s.requests.Store(0)
+		s.failures.Store(0)
+	}
+	requests := s.requests.Add(1)
+	if ok {
+		s.consecutive.Store(0)
+		return
+	}
+	b.lastError.Store(now)
+	consecutive := s.consecutive.Add(1)
+	failures := s.failures.Add(1)
+	if b.ejected.Load() {
+		return
+	}
+	switch {
+	case consecutive >= int64(oc.ConsecutiveFailures):
+		lb.eject(b, oc, fmt.Sprintf("%d consecutive failures", consecutive))
+	case requests >= int64(oc.MinRequests) && float64(failures) >= oc.ErrorRate*float64(requests):
+		lb.eject(b, oc, fmt.Sprintf("%d of %d requests failed", failures, requests))
+	}
+}
+
+func (lb *LoadBalancer) eject(b *Backend, oc *OutlierConfig, reason string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if b.ejected.Load() {
+		return
+	}
+	backends := lb.pool.Load().backends
+	ejected := 1
+	for _, o := range backends {
+		if o.ejected.Load() {
+			ejected++
+		}
+	}
+	if ejected*100 > oc.MaxEjectionPercent*len(backends) {
+		log.Printf("[outlier] %s %s not ejected (%s): %d%% of the pool is the limit", lb.Name, b.URL, reason, oc.MaxEjectionPercent)
+		return
+	}
+	s := &b.outlier
+	// A backend that has stayed in service for a full MaxEjection starts
+	// again from BaseEjection.
+	if !s.returnedAt.IsZero() && time.Since(s.returnedAt) > oc.MaxEjection {
+		s.ejections = 0
+	}
+	d := min(oc.BaseEjection<<s.ejections, oc.MaxEjection)
+	if d < oc.MaxEjection {
+		s.ejections++
+	}
+	b.ejected.Store(true)
+	b.rampStart.Store(0)
+	log.Printf("[outlier] %s %s ejected for %s: %s", lb.Name, b.URL, d, reason)
+	time.AfterFunc(d, func() { lb.reinstate(b) })
+}
+
+func (lb *LoadBalancer) reinstate(b *Backend) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	s := &b.outlier
+	now := time.Now()
+	s.consecutive.Store(0)
+	s.requests.Store(0)
+	s.failures.Store(0)
+	s.windowStart.Store(now.UnixNano())
+	s.returnedAt = now
+	if oc := lb.outlier.Load(); oc != nil && oc.RampUp > 0 {
+		b.rampFor.Store(int64(oc.RampUp))
+		b.rampStart.Store(now.UnixNano())
+	}
+	b.ejected.Store(false)
+	log.Printf("[outlier] %s %s returned to service", lb.Name, b.URL)
+}
+
+// admitRamping gives a returning backend a share of requests that grows
+// from minRampShare to all of them over its ramp-up period.
+func (b *Backend) admitRamping(start int64) bool {
+	elapsed := time.Now().UnixNano() - start
+	ramp := b.rampFor.Load()
+	if elapsed >= ramp {
+		b.rampStart.CompareAndSwap(start, 0)
+		return true
+	}
+	return rand.Float64() < max(float64(elapsed)/float64(ramp), minRampShare)
+}
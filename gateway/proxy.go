@@ -1,9 +1,10 @@
 package main
 
 import (
-	"fmt"
-	"io"
+	"errors"
 	"log"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,32 +12,55 @@ import (
 )
 
 type Proxy struct {
-	lb     *LoadBalancer
-	client *fasthttp.Client
+	gw *Gateway
 }
 
-func NewProxy(lb *LoadBalancer) *Proxy {
-	c := &fasthttp.Client{ReadTimeout: BackendTimeout, WriteTimeout: BackendTimeout}
-	return &Proxy{lb: lb, client: c}
+func NewProxy(gw *Gateway) *Proxy {
+	return &Proxy{gw: gw}
 }
 
 func copyHeaders(src *fasthttp.RequestHeader, dst *fasthttp.RequestHeader) {
 	src.VisitAll(func(k, v []byte) {
-		dst.SetCanonical(k, v)
+		if !hopHeaders[string(k)] {
+			dst.SetCanonical(k, v)
+		}
 	})
 }
 
 func (p *Proxy) Handle(c *fiber.Ctx) error {
-	backend := p.lb.NextBackend()
-	if backend == nil {
-		return c.Status(fiber.StatusServiceUnavailable).SendString("no backend available")
+	st := p.gw.current()
+	uri := c.Request().URI()
+	// Route on the normalized path: matching the raw one would let
+	// /api/public/../admin through a /api/public route.
+	up, path, policy := st.pick(string(c.Request().Host()), c.Method(), string(uri.Path()), func(name string) string { return c.Get(name) }, false)
+	if !p.gw.limiter.Allow(policy, func(k hashKey) string { return k.value(c) }) {
+		return c.Status(fiber.StatusTooManyRequests).SendString("rate limit exceeded")
+	}
+	if up == nil {
+		return c.Status(fiber.StatusNotFound).SendString("no route")
 	}
+	path = escapePath(path)
+	if isWebSocket(c) {
+		return p.tunnel(c, up, path, up.key.value(c))
+	}
+	rc := up.cfg.Retry
+	deadline := time.Now().Add(rc.Deadline)
+	key := up.key.value(c)
+	canRetry := retryable(c, rc)
+	up.budget.request()
+
 	req := fasthttp.AcquireRequest()
 	resp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseRequest(req)
-	defer fasthttp.ReleaseResponse(resp)
-	target := fmt.Sprintf("%s%s", backend.URL, c.OriginalURL())
-	req.SetRequestURI(target)
+	// Once the response body is handed to the client stream, that stream
+	// owns resp and releases it.
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			resp.CloseBodyStream()
+			fasthttp.ReleaseResponse(resp)
+		}
+	}()
 	req.Header.SetMethod(string(c.Context().Method()))
 	copyHeaders(&c.Context().Request.Header, &req.Header)
 	clientIP := c.IP()
@@ -44,31 +68,123 @@ func (p *Proxy) Handle(c *fiber.Ctx) error {
 		clientIP = "unknown"
 	}
 	req.Header.Add("X-Forwarded-For", clientIP)
-	if c.Request().Body() != nil && len(c.Request().Body()) > 0 {
-		req.SetBody(c.Request().Body())
+	limit := up.cfg.MaxRequestBody
+	if size := int64(c.Request().Header.ContentLength()); limit > 0 && size > limit {
+		return c.Status(fiber.StatusRequestEntityTooLarge).SendString("request body too large")
+	}
+	var bodyStream *limitReader
+	if c.Request().IsBodyStream() {
+		// Too large to have been buffered: stream it, which also means it
+		// cannot be sent twice.
+		bodyStream = newLimitReader(c.Context().RequestBodyStream(), limit)
+		req.SetBodyStream(bodyStream, c.Request().Header.ContentLength())
+		canRetry = false
+	} else if body := c.Request().Body(); len(body) > 0 {
+		if limit > 0 && int64(len(body)) > limit {
+			return c.Status(fiber.StatusRequestEntityTooLarge).SendString("request body too large")
+		}
+		req.SetBody(body)
+	}
+	query := uri.QueryString()
+
+	var backend *Backend
+	var tried []*Backend
+	var err error
+	var latency time.Duration
+	var clientFault bool
+	for attempt := 1; ; attempt++ {
+		next := up.lb.Acquire(key, tried)
+		if next == nil {
+			break
+		}
+		backend = next
+		tried = append(tried, backend)
+		target := backend.URL + path
+		if len(query) > 0 {
+			target += "?" + string(query)
+		}
+		req.SetRequestURI(target)
+		resp.CloseBodyStream()
+		resp.Reset()
+		start := time.Now()
+		attemptDeadline := start.Add(up.cfg.Timeout)
+		if attemptDeadline.After(deadline) {
+			attemptDeadline = deadline
+		}
+		backend.acquire()
+		err = up.client.DoDeadline(req, resp, attemptDeadline)
+		latency = time.Since(start)
+		backend.release(latency)
+		if err != nil && bodyStream != nil && bodyStream.broken(c.Request().Header.ContentLength()) {
+			// The client's body failed (too large or cut off), which says
+			// nothing about the backend.
+			if bodyStream.err != nil {
+				err = bodyStream.err
+			}
+			clientFault = true
+			up.lb.Release(backend)
+			break
+		}
+		up.lb.Report(backend, err == nil && resp.StatusCode() < 500, latency)
+		if err != nil {
+			log.Printf("[proxy] error %s (attempt %d): %v", backend.URL, attempt, err)
+		} else if !slices.Contains(rc.OnStatus, resp.StatusCode()) {
+			break
+		}
+		if !canRetry || attempt >= rc.Attempts {
+			break
+		}
+		pause := retryBackoff(rc.Backoff, attempt)
+		if time.Until(deadline) <= pause || !up.budget.allow(rc) {
+			break
+		}
+		time.Sleep(pause)
 	}
-	start := time.Now()
-	if err := p.client.Do(req, resp); err != nil {
-		backend.setAlive(false)
-		backend.lastError = time.Now()
-		log.Printf("[proxy] error %s: %v", backend.URL, err)
+	if backend == nil {
+		return c.Status(fiber.StatusServiceUnavailable).SendString("no backend available")
+	}
+	c.Set("X-Proxy-Attempts", strconv.Itoa(len(tried)))
+	switch {
+	case errors.Is(err, errBodyTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).SendString("request body too large")
+	case clientFault:
+		log.Printf("[proxy] request body from %s failed: %v", clientIP, err)
+		return c.Status(fiber.StatusBadRequest).SendString("request body incomplete")
+	case errors.Is(err, fasthttp.ErrTimeout):
+		return c.Status(fiber.StatusGatewayTimeout).SendString("backend timeout")
+	case err != nil:
 		return c.Status(fiber.StatusBadGateway).SendString("backend error")
 	}
-	latency := time.Since(start)
+	size := resp.Header.ContentLength()
+	if limit := up.cfg.MaxResponseBody; limit > 0 && int64(size) > limit {
+		log.Printf("[proxy] response from %s is %d bytes, over max_response_body", backend.URL, size)
+		return c.Status(fiber.StatusBadGateway).SendString("backend response too large")
+	}
 	c.Status(resp.StatusCode())
 	resp.Header.VisitAll(func(k, v []byte) {
-		c.Set(string(k), string(v))
-	})
-	body := resp.Body()
-	if len(body) > 0 {
-		_, err := c.Response().BodyWriter().Write(body)
-		if err != nil {
-			return err
+		if !hopHeaders[string(k)] {
+			c.Set(string(k), string(v))
 		}
-	} else {
-		io.Copy(c.Response().BodyWriter(), resp.BodyStream())
-	}
+	})
 	c.Set("X-Proxy-Latency", latency.String())
 	c.Set("X-Proxy-Backend", backend.URL)
+	if size < 0 {
+		// Chunked or read-until-close: send chunked, which fasthttp flushes
+		// chunk by chunk, so server-sent events arrive as they are written.
+		size = -1
+	}
+	c.Response().SetBodyStream(&responseBody{
+		limitReader: newLimitReader(resp.BodyStream(), up.cfg.MaxResponseBody),
+		resp:        resp,
+		backend:     backend.URL,
+	}, size)
+	handedOff = true
 	return nil
 }
+
+// retryBackoff doubles base for each attempt and picks a random point in
+// the upper half, so clients retrying together spread out.
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	d := base << (attempt - 1)
+	return d/2 + jitter(d/2)
+}
@@ -8,35 +8,118 @@ import (
 	"golang.org/x/time/rate"
 )
 
-type IPRateLimiter struct {
-	clients sync.Map
-	rps     int
-	burst   int
+const rateLimitCleanupInterval = time.Minute
+
+// RateLimiter enforces the rate limit policies of the config: the default
+// one from rate_limit, used by requests whose route names none, and the
+// named ones from rate_limits. Each policy keeps a token bucket per client
+// key. A bucket that has refilled completely admits exactly what a new one
+// would, so the cleanup loop drops those and idle clients cost nothing.
+type RateLimiter struct {
+	mu       sync.RWMutex
+	policies map[string]*ratePolicy
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// ratePolicy is replaced, not changed, on reload; clients is carried over
+// while the key stays the same, so buckets survive a change of limits.
+type ratePolicy struct {
+	cfg     RateLimitConfig
+	key     hashKey
+	clients *sync.Map // client key -> *rate.Limiter
 }
 
-func NewIPRateLimiter(rps, burst int) *IPRateLimiter {
-	rl := &IPRateLimiter{clients: sync.Map{}, rps: rps, burst: burst}
-	go rl.cleanupLoop()
-	return rl
+func NewRateLimiter(cfg *Config) *RateLimiter {
+	r := &RateLimiter{stop: make(chan struct{})}
+	r.Update(cfg)
+	go r.cleanupLoop(rateLimitCleanupInterval)
+	return r
+}
+
+// Allow takes a token from the client's bucket in the named policy, or the
+// default policy when name is empty. clientKey extracts the policy's key
+// from the request. A policy with rps 0 admits everything.
+func (r *RateLimiter) Allow(name string, clientKey func(hashKey) string) bool {
+	r.mu.RLock()
+	p := r.policies[name]
+	if p == nil || p.cfg.RPS == 0 {
+		r.mu.RUnlock()
+		return true
+	}
+	k := clientKey(p.key)
+	if k == "" {
+		k = "unknown"
+	}
+	l := p.limiter(k)
+	r.mu.RUnlock()
+	return l.Allow()
 }
 
-func (r *IPRateLimiter) getLimiter(ip string) *rate.Limiter {
-	if v, ok := r.clients.Load(ip); ok {
+func (p *ratePolicy) limiter(k string) *rate.Limiter {
+	if v, ok := p.clients.Load(k); ok {
 		return v.(*rate.Limiter)
 	}
-	limiter := rate.NewLimiter(rate.Limit(r.rps), r.burst)
-	r.clients.Store(ip, limiter)
-	return limiter
+	v, _ := p.clients.LoadOrStore(k, rate.NewLimiter(rate.Limit(p.cfg.RPS), p.cfg.Burst))
+	return v.(*rate.Limiter)
 }
 
-func (r *IPRateLimiter) Allow(ip string) bool {
-	limiter := r.getLimiter(ip)
-	return limiter.Allow()
+// Update installs the policies of cfg. Buckets of a policy whose key is
+// unchanged keep their tokens and take the new limits; a new key starts
+// every client afresh.
+func (r *RateLimiter) Update(cfg *Config) {
+	next := map[string]RateLimitConfig{"": cfg.RateLimit}
+	for name, pc := range cfg.RateLimits {
+		next[name] = pc
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	policies := make(map[string]*ratePolicy, len(next))
+	for name, pc := range next {
+		p := &ratePolicy{cfg: pc, clients: &sync.Map{}}
+		p.key, _ = parseHashKey(pc.Key)
+		if old := r.policies[name]; old != nil && old.cfg.Key == pc.Key {
+			p.clients = old.clients
+			if old.cfg != pc {
+				p.clients.Range(func(_, v any) bool {
+					l := v.(*rate.Limiter)
+					l.SetLimit(rate.Limit(pc.RPS))
+					l.SetBurst(pc.Burst)
+					return true
+				})
+			}
+		}
+		policies[name] = p
+	}
+	r.policies = policies
+}
+
+func (r *RateLimiter) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+func (r *RateLimiter) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.evictIdle()
+		}
+	}
 }
 
-func (r *IPRateLimiter) cleanupLoop() {
-	for range time.Tick(5 * time.Minute) {
-		r.clients.Range(func(k, v interface{}) bool {
+// evictIdle drops the buckets that are full again.
+func (r *RateLimiter) evictIdle() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.policies {
+		p.clients.Range(func(k, v any) bool {
+			if l := v.(*rate.Limiter); l.Tokens() >= float64(l.Burst()) {
+				p.clients.CompareAndDelete(k, v)
+			}
 			return true
 		})
 	}
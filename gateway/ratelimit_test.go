@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func keyOf(ip, token string) func(hashKey) string {
+	return func(k hashKey) string {
+		return k.resolve(func(string) string { return token }, func(string) string { return "" }, ip)
+	}
+}
+
+func countClients(r *RateLimiter, name string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := 0
+	r.policies[name].clients.Range(func(_, _ any) bool { n++; return true })
+	return n
+}
+
+func limitConfig(def RateLimitConfig, named map[string]RateLimitConfig) *Config {
+	cfg := &Config{RateLimit: def, RateLimits: named}
+	cfg.applyDefaults()
+	return cfg
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	r := NewRateLimiter(limitConfig(
+		RateLimitConfig{RPS: 1, Burst: 2},
+		map[string]RateLimitConfig{
+			"per-token": {RPS: 1, Burst: 1, Key: "header:Authorization"},
+			"open":      {RPS: 0},
+		},
+	))
+	t.Cleanup(r.Stop)
+
+	// The default policy counts per IP.
+	for i, want := range []bool{true, true, false} {
+		if got := r.Allow("", keyOf("10.0.0.1", "")); got != want {
+			t.Fatalf("default request %d: allowed %v, want %v", i+1, got, want)
+		}
+	}
+	if !r.Allow("", keyOf("10.0.0.2", "")) {
+		t.Fatal("another IP shares the first one's bucket")
+	}
+
+	// The named policy counts per token, whatever the IP.
+	if !r.Allow("per-token", keyOf("10.0.0.1", "a")) {
+		t.Fatal("first request for token a refused")
+	}
+	if r.Allow("per-token", keyOf("10.0.0.9", "a")) {
+		t.Fatal("token a was allowed again from another IP")
+	}
+	if !r.Allow("per-token", keyOf("10.0.0.1", "b")) {
+		t.Fatal("token b shares token a's bucket")
+	}
+	// Without the header the client is its IP.
+	if !r.Allow("per-token", keyOf("10.0.0.3", "")) || r.Allow("per-token", keyOf("10.0.0.3", "")) {
+		t.Fatal("requests without a token are not limited per IP")
+	}
+
+	for range 10 {
+		if !r.Allow("open", keyOf("10.0.0.1", "")) {
+			t.Fatal("a policy with rps 0 refused a request")
+		}
+	}
+}
+
+func TestRateLimitUpdate(t *testing.T) {
+	r := NewRateLimiter(limitConfig(RateLimitConfig{RPS: 1, Burst: 1}, map[string]RateLimitConfig{"api": {RPS: 1, Burst: 1}}))
+	t.Cleanup(r.Stop)
+	client := keyOf("10.0.0.1", "tok")
+	r.Allow("", client)
+	r.Allow("api", client)
+
+	// Raising the burst keeps the empty buckets empty but lets them hold more.
+	r.Update(limitConfig(RateLimitConfig{RPS: 1, Burst: 5}, map[string]RateLimitConfig{"api": {RPS: 1, Burst: 1, Key: "header:Authorization"}}))
+	if r.Allow("", client) {
+		t.Fatal("an update refilled the default policy's bucket")
+	}
+	if countClients(r, "api") != 0 {
+		t.Fatal("buckets survived a change of key")
+	}
+	if !r.Allow("api", client) {
+		t.Fatal("a new key did not start afresh")
+	}
+
+	r.Update(limitConfig(RateLimitConfig{RPS: 1, Burst: 5}, nil))
+	if !r.Allow("api", client) {
+		t.Fatal("a removed policy still limits")
+	}
+}
+
+func TestRateLimitEvictsFullBuckets(t *testing.T) {
+	r := NewRateLimiter(limitConfig(RateLimitConfig{RPS: 1, Burst: 5}, map[string]RateLimitConfig{"fast": {RPS: 1000, Burst: 1}}))
+	t.Cleanup(r.Stop)
+	for i := range 100 {
+		r.Allow("fast", keyOf(fmt.Sprintf("10.0.1.%d", i), ""))
+	}
+	r.Allow("", keyOf("10.0.0.1", ""))
+	time.Sleep(5 * time.Millisecond)
+
+	r.evictIdle()
+	if n := countClients(r, "fast"); n != 0 {
+		t.Fatalf("%d refilled buckets were kept", n)
+	}
+	if n := countClients(r, ""); n != 1 {
+		t.Fatalf("%d buckets left in the default policy, want the one still refilling", n)
+	}
+	// An evicted client starts with a full bucket, as it would have had.
+	if !r.Allow("fast", keyOf("10.0.1.0", "")) {
+		t.Fatal("an evicted client was refused")
+	}
+}
+
+func TestRateLimitConcurrentUpdate(t *testing.T) {
+	r := NewRateLimiter(limitConfig(RateLimitConfig{RPS: 100, Burst: 100}, nil))
+	t.Cleanup(r.Stop)
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				r.Allow("", keyOf(fmt.Sprintf("10.0.0.%d", i), ""))
+			}
+		}()
+	}
+	for i := range 20 {
+		r.Update(limitConfig(RateLimitConfig{RPS: 100 + i, Burst: 100}, nil))
+		r.evictIdle()
+	}
+	wg.Wait()
+}
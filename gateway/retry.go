@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const retryBudgetWindow = 10 * time.Second
+
+// retryBudget counts requests and retries to an upstream over a fixed
+// window. It outlives reloads, so a config change does not reset it.
+type retryBudget struct {
+	windowStart atomic.Int64
+	requests    atomic.Int64
+	retries     atomic.Int64
+}
+
+func (rb *retryBudget) rotate() {
+	now := time.Now().UnixNano()
+	if start := rb.windowStart.Load(); now-start >= int64(retryBudgetWindow) && rb.windowStart.CompareAndSwap(start, now) {
+		rb.requests.Store(0)
+		rb.retries.Store(0)
+	}
+}
+
+func (rb *retryBudget) request() {
+	rb.rotate()
+	rb.requests.Add(1)
+}
+
+// allow takes one retry from the budget if there is one left.
+func (rb *retryBudget) allow(rc RetryConfig) bool {
+	rb.rotate()
+	limit := rb.requests.Load()*int64(rc.BudgetPercent)/100 + int64(rc.MinRetriesPerSecond)*int64(retryBudgetWindow/time.Second)
+	if rb.retries.Add(1) > limit {
+		rb.retries.Add(-1)
+		return false
+	}
+	return true
+}
+
+var idempotentMethods = []string{fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace, fiber.MethodPut, fiber.MethodDelete}
+
+func retryable(c *fiber.Ctx, rc RetryConfig) bool {
+	return rc.Attempts > 1 && (slices.Contains(idempotentMethods, c.Method()) || c.Get(rc.IdempotencyHeader) != "")
+}
+
+// NextBackendExcluding is NextBackend for a retry: it avoids the backends
+// already tried, falling back to any live one left in the pool.
+func (lb *LoadBalancer) NextBackendExcluding(key string, tried []*Backend) *Backend {
+	p := lb.pool.Load()
+	for range len(p.backends) {
+		b := p.strategy.Next(key)
+		if b == nil {
+			break
+		}
+		if !slices.Contains(tried, b) {
+			return b
+		}
+	}
+	for _, b := range p.backends {
+		if b.isHealthy() && !b.ejected.Load() && !slices.Contains(tried, b) {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+type route struct {
+	cfg     RouteConfig
+	prefix  string
+	methods map[string]bool
+	up      *upstream
+}
+
+func compileRoutes(cfgs []RouteConfig, upstreams map[string]*upstream) []*route {
+	routes := make([]*route, 0, len(cfgs))
+	for _, rc := range cfgs {
+		r := &route{cfg: rc, prefix: strings.TrimRight(rc.Match.PathPrefix, "/"), up: upstreams[rc.Upstream]}
+		if len(rc.Match.Methods) > 0 {
+			r.methods = make(map[string]bool, len(rc.Match.Methods))
+			for _, m := range rc.Match.Methods {
+				r.methods[m] = true
+			}
+		}
+		routes = append(routes, r)
+	}
+	return routes
+}
+
+func (r *route) matches(host, method, path string, header func(string) string) bool {
+	if !hasPathPrefix(path, r.prefix) {
+		return false
+	}
+	if r.methods != nil && !r.methods[method] {
+		return false
+	}
+	if r.cfg.Match.Host != "" && !hostMatches(r.cfg.Match.Host, host) {
+		return false
+	}
+	for name, want := range r.cfg.Match.Headers {
+		got := header(name)
+		if got == "" || (want != "*" && got != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// rewrite maps a matched request path to the upstream path.
+func (r *route) rewrite(path string) string {
+	if !r.cfg.StripPrefix && r.cfg.RewritePrefix == "" {
+		return path
+	}
+	rest := path[len(r.prefix):]
+	base := strings.TrimRight(r.cfg.RewritePrefix, "/")
+	if out := base + rest; out != "" {
+		return out
+	}
+	return "/"
+}
+
+// hasPathPrefix matches whole path segments; an empty prefix matches all.
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || prefix == "" || path[len(prefix)] == '/'
+}
+
+func hostMatches(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	pattern = strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// cleanPath resolves dot segments and repeated slashes as fasthttp's
+// URI.Path does, so a route matches the path the backend will serve.
+func cleanPath(p string) string {
+	c := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && c != "/" {
+		c += "/"
+	}
+	return c
+}
+
+// escapePath turns a decoded path back into its request-line form.
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// pick returns the upstream for a request, the path to send it and the
+// rate limit policy that applies, or a nil upstream when nothing matches and
+// there is no default. path must already be decoded and normalized, and so
+// is the returned path. Only routes whose grpc flag equals grpc are
+// considered, and gRPC requests never fall back to the default upstream.
+// Requests without a route get the default policy.
+func (st *gatewayState) pick(host, method, path string, header func(string) string, grpc bool) (up *upstream, upPath, limit string) {
+	for _, r := range st.routes {
+		if r.cfg.GRPC == grpc && r.matches(host, method, path, header) {
+			return r.up, r.rewrite(path), r.cfg.RateLimit
+		}
+	}
+	if st.cfg.DefaultUpstream != "" && !grpc {
+		return st.upstreams[st.cfg.DefaultUpstream], path, ""
+	}
+	return nil, path, ""
+}
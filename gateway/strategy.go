@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	StrategyRoundRobin         = "round_robin"
+	StrategyWeightedRoundRobin = "weighted_round_robin"
+	StrategyLeastInflight      = "least_inflight"
+	StrategyP2CLatency         = "p2c_latency"
+	StrategyConsistentHash     = "consistent_hash"
+)
+
+const (
+	maxWeight = 100
+	// ringReplicas is the number of ring points per unit of weight.
+	ringReplicas = 64
+)
+
+// Strategy picks a live backend for a request, or returns nil when none is
+// alive. Each backend list gets a fresh Strategy: Update is called once,
+// before it is published, and Next is then called concurrently without any
+// lock, so it must only use atomics and state fixed by Update.
+type Strategy interface {
+	Update(backends []*Backend)
+	Next(key string) *Backend
+}
+
+func newStrategy(name string) (Strategy, error) {
+	switch name {
+	case StrategyRoundRobin:
+		return &roundRobin{}, nil
+	case StrategyWeightedRoundRobin:
+		return &weightedRoundRobin{}, nil
+	case StrategyLeastInflight:
+		return &leastInflight{}, nil
+	case StrategyP2CLatency:
+		return &p2cLatency{}, nil
+	case StrategyConsistentHash:
+		return &consistentHash{}, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q", name)
+}
+
+type roundRobin struct {
+	backends []*Backend
+	idx      atomic.Uint64
+}
+
+func (s *roundRobin) Update(backends []*Backend) {
+	s.backends = backends
+}
+
+func (s *roundRobin) Next(string) *Backend {
+	return nextAlive(s.backends, s.idx.Add(1))
+}
+
+// nextAlive returns the first live backend at or after position start,
+// wrapping around.
+func nextAlive(backends []*Backend, start uint64) *Backend {
+	n := uint64(len(backends))
+	for i := uint64(0); i < n; i++ {
+		if b := backends[(start+i)%n]; b.isAlive() {
+			return b
+		}
+	}
+	return nil
+}
+
+// weightedRoundRobin walks a schedule built once by the smooth weighted
+// round-robin algorithm, so a backend with weight 3 next to one with weight
+// 1 is picked a,a,b,a rather than a,a,a,b. A dead backend's slots pass to
+// the next live entry in the schedule.
+type weightedRoundRobin struct {
+	schedule []*Backend
+	idx      atomic.Uint64
+}
+
+func (s *weightedRoundRobin) Update(backends []*Backend) {
+	total := 0
+	for _, b := range backends {
+		total += b.Weight
+	}
+	current := make([]int, len(backends))
+	s.schedule = make([]*Backend, 0, total)
+	for range total {
+		best := 0
+		for i, b := range backends {
+			current[i] += b.Weight
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		s.schedule = append(s.schedule, backends[best])
+	}
+}
+
+func (s *weightedRoundRobin) Next(string) *Backend {
+	return nextAlive(s.schedule, s.idx.Add(1))
+}
+
+// leastInflight picks the live backend with the fewest requests in flight.
+// The scan starts at a rotating offset so ties are spread evenly.
+type leastInflight struct {
+	backends []*Backend
+	start    atomic.Uint64
+}
+
+func (s *leastInflight) Update(backends []*Backend) {
+	s.backends = backends
+}
+
+func (s *leastInflight) Next(string) *Backend {
+	n := uint64(len(s.backends))
+	if n == 0 {
+		return nil
+	}
+	start := s.start.Add(1)
+	var best *Backend
+	var bestLoad int64
+	for i := uint64(0); i < n; i++ {
+		b := s.backends[(start+i)%n]
+		if !b.isAlive() {
+			continue
+		}
+		if load := b.inflight.Load(); best == nil || load < bestLoad {
+			best, bestLoad = b, load
+		}
+	}
+	return best
+}
+
+// p2cLatency samples two backends at random and keeps the one with the
+// lower latency × (in-flight + 1). Backends without a latency sample yet
+// cost nothing, so new backends are tried straight away.
+type p2cLatency struct {
+	backends []*Backend
+}
+
+func (s *p2cLatency) Update(backends []*Backend) {
+	s.backends = backends
+}
+
+func (s *p2cLatency) Next(string) *Backend {
+	n := len(s.backends)
+	switch n {
+	case 0:
+		return nil
+	case 1:
+		if b := s.backends[0]; b.isAlive() {
+			return b
+		}
+		return nil
+	}
+	i := rand.IntN(n)
+	j := rand.IntN(n - 1)
+	if j >= i {
+		j++
+	}
+	a, b := s.backends[i], s.backends[j]
+	aliveA, aliveB := a.isAlive(), b.isAlive()
+	switch {
+	case aliveA && aliveB:
+		if p2cCost(b) < p2cCost(a) {
+			return b
+		}
+		return a
+	case aliveA:
+		return a
+	case aliveB:
+		return b
+	}
+	// Both samples are down: fall back to the cheapest live backend.
+	var best *Backend
+	for _, c := range s.backends {
+		if c.isAlive() && (best == nil || p2cCost(c) < p2cCost(best)) {
+			best = c
+		}
+	}
+	return best
+}
+
+func p2cCost(b *Backend) int64 {
+	return b.latency.Load() * (b.inflight.Load() + 1)
+}
+
+// consistentHash maps a key onto a ring of ringReplicas×weight points per
+// backend. Adding or removing a backend only moves the keys on its own
+// points, and a key whose backend is down moves to the next live one on the
+// ring until it recovers.
+type consistentHash struct {
+	ring []ringPoint
+}
+
+type ringPoint struct {
+	hash    uint64
+	backend *Backend
+}
+
+func (s *consistentHash) Update(backends []*Backend) {
+	s.ring = s.ring[:0]
+	for _, b := range backends {
+		for i := 0; i < b.Weight*ringReplicas; i++ {
+			s.ring = append(s.ring, ringPoint{hash: hashString(b.URL + "#" + strconv.Itoa(i)), backend: b})
+		}
+	}
+	slices.SortFunc(s.ring, func(a, b ringPoint) int {
+		switch {
+		case a.hash < b.hash:
+			return -1
+		case a.hash > b.hash:
+			return 1
+		}
+		return strings.Compare(a.backend.URL, b.backend.URL)
+	})
+}
+
+func (s *consistentHash) Next(key string) *Backend {
+	n := len(s.ring)
+	if n == 0 {
+		return nil
+	}
+	h := hashString(key)
+	start, _ := slices.BinarySearchFunc(s.ring, h, func(p ringPoint, h uint64) int {
+		switch {
+		case p.hash < h:
+			return -1
+		case p.hash > h:
+			return 1
+		}
+		return 0
+	})
+	for i := 0; i < n; i++ {
+		if b := s.ring[(start+i)%n].backend; b.isAlive() {
+			return b
+		}
+	}
+	return nil
+}
+
+// hashString is FNV-1a with a final mix, so the ring is laid out the same
+// way on every gateway instance and keys stay sticky across them.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// hashKey says which part of a request consistent_hash hashes on.
+type hashKey struct {
+	source string // "ip", "header" or "cookie"; empty when unused
+	name   string
+}
+
+func parseHashKey(s string) (hashKey, error) {
+	if s == "ip" {
+		return hashKey{source: "ip"}, nil
+	}
+	source, name, ok := strings.Cut(s, ":")
+	if !ok || name == "" || (source != "header" && source != "cookie") {
+		return hashKey{}, fmt.Errorf("%q must be ip, header:<name> or cookie:<name>", s)
+	}
+	return hashKey{source: source, name: name}, nil
+}
+
+func (k hashKey) value(c *fiber.Ctx) string {
+	return k.resolve(func(name string) string { return c.Get(name) }, func(name string) string { return c.Cookies(name) }, c.IP())
+}
+
+func (k hashKey) valueHTTP(r *http.Request) string {
+	cookie := func(name string) string {
+		if ck, err := r.Cookie(name); err == nil {
+			return ck.Value
+		}
+		return ""
+	}
+	return k.resolve(r.Header.Get, cookie, remoteIP(r))
+}
+
+func (k hashKey) resolve(header, cookie func(string) string, ip string) string {
+	var v string
+	switch k.source {
+	case "":
+		return ""
+	case "header":
+		v = header(k.name)
+	case "cookie":
+		v = cookie(k.name)
+	}
+	if v == "" {
+		v = ip
+	}
+	return v
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+var benchStrategies = []string{StrategyRoundRobin, StrategyWeightedRoundRobin, StrategyLeastInflight, StrategyP2CLatency, StrategyConsistentHash}
+
+func testPool(strategy string, weights ...int) (*LoadBalancer, []string) {
+	bc := BalanceConfig{Strategy: strategy, Weights: map[string]int{}}
+	urls := make([]string, len(weights))
+	for i, w := range weights {
+		urls[i] = fmt.Sprintf("http://10.2.0.%d:8080", i+1)
+		bc.Weights[urls[i]] = w
+	}
+	return NewLoadBalancer("test", bc, urls), urls
+}
+
+func pickCounts(lb *LoadBalancer, n int) map[string]int {
+	counts := map[string]int{}
+	for range n {
+		counts[lb.NextBackend("").URL]++
+	}
+	return counts
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	lb, urls := testPool(StrategyWeightedRoundRobin, 3, 1, 2)
+	counts := pickCounts(lb, 600)
+	for i, want := range []int{300, 100, 200} {
+		if counts[urls[i]] != want {
+			t.Fatalf("picks %v, want 300/100/200 in weight order", counts)
+		}
+	}
+
+	// Smooth: the heavy backend is never picked more than twice in a row.
+	run, last := 0, ""
+	for range 60 {
+		u := lb.NextBackend("").URL
+		if u == last {
+			run++
+		} else {
+			run, last = 1, u
+		}
+		if run > 2 {
+			t.Fatalf("%s picked %d times in a row", u, run)
+		}
+	}
+
+	// A dead backend's share goes to the others.
+	lb.Backends()[0].setAlive(false)
+	counts = pickCounts(lb, 600)
+	if counts[urls[0]] != 0 || counts[urls[1]]+counts[urls[2]] != 600 {
+		t.Fatalf("picks %v with the first backend down", counts)
+	}
+}
+
+func TestConsistentHashSticky(t *testing.T) {
+	lb, _ := testPool(StrategyConsistentHash, 1, 1, 1, 1, 1)
+	other, _ := testPool(StrategyConsistentHash, 1, 1, 1, 1, 1)
+	for _, key := range benchKeys {
+		first := lb.NextBackend(key)
+		for range 3 {
+			if lb.NextBackend(key) != first {
+				t.Fatalf("key %s moved between requests", key)
+			}
+		}
+		// Another gateway instance with the same pool agrees.
+		if other.NextBackend(key).URL != first.URL {
+			t.Fatalf("key %s maps to %s on one instance and %s on another", key, first.URL, other.NextBackend(key).URL)
+		}
+	}
+}
+
+func TestConsistentHashRemapsMinimally(t *testing.T) {
+	bc := BalanceConfig{Strategy: StrategyConsistentHash}
+	urls := make([]string, 10)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("http://10.2.0.%d:8080", i+1)
+	}
+	lb := NewLoadBalancer("test", bc, urls)
+	owner := func() map[string]string {
+		m := map[string]string{}
+		for _, key := range benchKeys {
+			m[key] = lb.NextBackend(key).URL
+		}
+		return m
+	}
+	before := owner()
+
+	// Adding a backend only moves keys onto it, about 1/11 of them.
+	added := "http://10.2.0.11:8080"
+	lb.Update(bc, append(urls[:len(urls):len(urls)], added))
+	after := owner()
+	moved := 0
+	for key, u := range after {
+		if u != before[key] {
+			moved++
+			if u != added {
+				t.Fatalf("key %s moved from %s to %s, not to the new backend", key, before[key], u)
+			}
+		}
+	}
+	if share := float64(moved) / float64(len(benchKeys)); share == 0 || share > 2.0/11 {
+		t.Fatalf("adding one backend of 11 moved %.1f%% of keys", share*100)
+	}
+
+	// Removing one only moves the keys it owned.
+	removed := urls[3]
+	lb.Update(bc, slices.Delete(slices.Clone(urls), 3, 4))
+	for key, u := range owner() {
+		if before[key] != removed && u != before[key] {
+			t.Fatalf("key %s moved from %s to %s though its backend stayed", key, before[key], u)
+		}
+		if u == removed {
+			t.Fatalf("key %s still maps to the removed backend", key)
+		}
+	}
+
+	// A backend that goes down and comes back gets its keys back.
+	lb.Update(bc, urls)
+	down := lb.Backends()[5]
+	down.setAlive(false)
+	for key, u := range owner() {
+		if before[key] != down.URL && u != before[key] {
+			t.Fatalf("key %s moved while an unrelated backend was down", key)
+		}
+	}
+	down.setAlive(true)
+	if after := owner(); !maps.Equal(after, before) {
+		t.Fatal("keys did not return to a recovered backend")
+	}
+}
+
+func TestConsistentHashHonoursWeights(t *testing.T) {
+	lb, urls := testPool(StrategyConsistentHash, 3, 1)
+	heavy := 0
+	const n = 20000
+	for i := range n {
+		if lb.NextBackend("client-"+strconv.Itoa(i)).URL == urls[0] {
+			heavy++
+		}
+	}
+	if share := float64(heavy) / n; share < 0.68 || share > 0.82 {
+		t.Fatalf("weight 3 of 4 got %.1f%% of keys", share*100)
+	}
+}
+
+func TestLeastInflight(t *testing.T) {
+	lb, urls := testPool(StrategyLeastInflight, 1, 1, 1)
+	bs := lb.Backends()
+	for i, n := range []int{3, 1, 2} {
+		for range n {
+			bs[i].acquire()
+		}
+	}
+	for range 10 {
+		if got := lb.NextBackend("").URL; got != urls[1] {
+			t.Fatalf("picked %s, want the least loaded %s", got, urls[1])
+		}
+	}
+
+	// Ties are spread over the tied backends.
+	bs[1].acquire()
+	counts := pickCounts(lb, 100)
+	if counts[urls[0]] != 0 || counts[urls[1]] == 0 || counts[urls[2]] == 0 {
+		t.Fatalf("picks %v, want only the two backends with 2 in flight", counts)
+	}
+
+	// Load changes are seen by the next pick, and dead backends are skipped.
+	bs[0].release(time.Millisecond)
+	bs[0].release(time.Millisecond)
+	bs[0].release(time.Millisecond)
+	if got := lb.NextBackend(""); got != bs[0] {
+		t.Fatalf("picked %s after the first backend drained", got.URL)
+	}
+	bs[0].setAlive(false)
+	if got := lb.NextBackend(""); got == bs[0] {
+		t.Fatal("picked a dead backend")
+	}
+}
+
+// BenchmarkStrategy measures NextBackend for every strategy, from one
+// goroutine and from GOMAXPROCS goroutines sharing a load balancer.
+func BenchmarkStrategy(b *testing.B) {
+	for _, n := range []int{3, 10, 100} {
+		for _, name := range benchStrategies {
+			bc, urls := benchPool(name, n)
+			b.Run(fmt.Sprintf("%s/backends=%d/serial", name, n), func(b *testing.B) {
+				lb := newBenchBalancer(bc, urls)
+				b.ReportAllocs()
+				for i := 0; b.Loop(); i++ {
+					benchRequest(b, lb, i)
+				}
+			})
+			b.Run(fmt.Sprintf("%s/backends=%d/parallel", name, n), func(b *testing.B) {
+				lb := newBenchBalancer(bc, urls)
+				b.ReportAllocs()
+				b.RunParallel(func(pb *testing.PB) {
+					for i := 0; pb.Next(); i++ {
+						benchRequest(b, lb, i)
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkRoundRobinContention compares round robin with the earlier
+// mutex-based balancer under growing concurrency.
+func BenchmarkRoundRobinContention(b *testing.B) {
+	bc, urls := benchPool(StrategyRoundRobin, 10)
+	for _, p := range []int{1, 8, 64} {
+		b.Run(fmt.Sprintf("goroutines=%dxGOMAXPROCS/lock-free", p), func(b *testing.B) {
+			lb := newBenchBalancer(bc, urls)
+			b.SetParallelism(p)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if lb.NextBackend("") == nil {
+						b.Error("no backend")
+						return
+					}
+				}
+			})
+		})
+		b.Run(fmt.Sprintf("goroutines=%dxGOMAXPROCS/mutex", p), func(b *testing.B) {
+			lb := newMutexBalancer(urls)
+			b.SetParallelism(p)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if lb.next() == nil {
+						b.Error("no backend")
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+var benchKeys = func() []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+	}
+	return keys
+}()
+
+func benchRequest(b *testing.B, lb *LoadBalancer, i int) {
+	be := lb.NextBackend(benchKeys[i%len(benchKeys)])
+	if be == nil {
+		b.Fatal("no backend")
+	}
+	be.acquire()
+	be.release(time.Duration(1+i%7) * time.Millisecond)
+}
+
+// benchPool builds n backends with weights 1-3; newBenchBalancer marks one
+// in ten of them down.
+func benchPool(strategy string, n int) (BalanceConfig, []string) {
+	bc := BalanceConfig{Strategy: strategy, Weights: map[string]int{}}
+	if strategy == StrategyConsistentHash {
+		bc.HashKey = "ip"
+	}
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("http://10.1.%d.%d:8080", i/256, i%256)
+		bc.Weights[urls[i]] = 1 + i%3
+	}
+	return bc, urls
+}
+
+func newBenchBalancer(bc BalanceConfig, urls []string) *LoadBalancer {
+	lb := NewLoadBalancer("bench", bc, urls)
+	for i, b := range lb.Backends() {
+		if i%10 == 9 {
+			b.setAlive(false)
+		}
+	}
+	return lb
+}
+
+// mutexBalancer is the round robin balancer as it was before selection
+// became lock-free: one mutex around the index and a RWMutex per backend.
+// It is kept only as a benchmark baseline.
+type mutexBalancer struct {
+	backends []*mutexBackend
+	idx      int
+	mutex    sync.Mutex
+}
+
+type mutexBackend struct {
+	alive bool
+	mutex sync.RWMutex
+}
+
+func (b *mutexBackend) isAlive() bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.alive
+}
+
+func newMutexBalancer(urls []string) *mutexBalancer {
+	lb := &mutexBalancer{}
+	for i := range urls {
+		lb.backends = append(lb.backends, &mutexBackend{alive: i%10 != 9})
+	}
+	return lb
+}
+
+func (lb *mutexBalancer) next() *mutexBackend {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	n := len(lb.backends)
+	for i := 0; i < n; i++ {
+		lb.idx = (lb.idx + 1) % n
+		if b := lb.backends[lb.idx]; b.isAlive() {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"crypto/tls"
+
+	"payments-core/tlsreload"
+)
+
+// listenerTLSConfig terminates TLS for clients of the gateway and, when a
+// client CA is configured, requires them to present a certificate.
+func listenerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	return tlsreload.ServerConfig(certFile, keyFile, clientCAFile)
+}
+
+// backendTLSConfig verifies backends against caFile and presents the
+// gateway's client certificate when one is configured. Returns nil when no
+// backend TLS is configured.
+func backendTLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" {
+		return nil, nil
+	}
+	return tlsreload.ClientConfig(caFile, certFile, keyFile, serverName)
+}
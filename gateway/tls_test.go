@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"payments-core/tlsreload/tlstest"
+)
+
+// serveTLS accepts connections with cfg and completes their handshakes
+// until the test ends.
+func serveTLS(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Write([]byte("x"))
+				conn.Close()
+			}()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// handshake dials addr and reads a byte, which surfaces a server-side
+// rejection of the client certificate under TLS 1.3.
+func handshake(addr string, cfg *tls.Config) error {
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	return err
+}
+
+func TestTLSConfigs(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	ca := tlstest.NewAuthority(t, "test-ca")
+	rogue := tlstest.NewAuthority(t, "rogue-ca")
+	srvCert, srvKey := ca.Issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	cliCert, cliKey := ca.Issue(t, "gateway", x509.ExtKeyUsageClientAuth)
+	rogueCert, rogueKey := rogue.Issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	for name, data := range map[string][]byte{
+		"ca.pem": ca.PEM, "server.pem": srvCert, "server.key": srvKey,
+		"client.pem": cliCert, "client.key": cliKey, "rogue.pem": rogueCert, "rogue.key": rogueKey,
+	} {
+		if err := os.WriteFile(path(name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("no backend TLS", func(t *testing.T) {
+		cfg, err := backendTLSConfig("", "", "", "")
+		if cfg != nil || err != nil {
+			t.Fatalf("got %v, %v; want nil config", cfg, err)
+		}
+	})
+
+	t.Run("listener requires a client certificate with a client CA", func(t *testing.T) {
+		srv, err := listenerTLSConfig(path("server.pem"), path("server.key"), path("ca.pem"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := serveTLS(t, srv)
+		withCert, err := backendTLSConfig(path("ca.pem"), path("client.pem"), path("client.key"), "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := handshake(addr, withCert); err != nil {
+			t.Fatalf("trusted client refused: %v", err)
+		}
+		without, err := backendTLSConfig(path("ca.pem"), "", "", "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := handshake(addr, without); err == nil {
+			t.Fatal("client without a certificate was accepted")
+		}
+	})
+
+	t.Run("listener without a client CA accepts any client", func(t *testing.T) {
+		srv, err := listenerTLSConfig(path("server.pem"), path("server.key"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := backendTLSConfig(path("ca.pem"), "", "", "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := handshake(serveTLS(t, srv), cfg); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("backend CA bundle reloads", func(t *testing.T) {
+		srv, err := listenerTLSConfig(path("rogue.pem"), path("rogue.key"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := serveTLS(t, srv)
+		cfg, err := backendTLSConfig(path("ca.pem"), "", "", "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := handshake(addr, cfg); err == nil {
+			t.Fatal("backend signed by another CA was trusted")
+		}
+		tlstest.Replace(t, path("ca.pem"), append(append([]byte{}, ca.PEM...), rogue.PEM...))
+		time.Sleep(1100 * time.Millisecond)
+		if err := handshake(addr, cfg); err != nil {
+			t.Fatalf("reloaded bundle not used: %v", err)
+		}
+	})
+}
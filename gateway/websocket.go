@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func isWebSocket(c *fiber.Ctx) bool {
+	return bytes.EqualFold(c.Request().Header.Peek(fiber.HeaderUpgrade), []byte("websocket")) &&
+		c.Request().Header.ConnectionUpgrade()
+}
+
+// tunnel forwards a WebSocket upgrade with its Connection and Upgrade
+// headers intact. If the backend switches protocols, the client connection
+// is hijacked and bytes are copied both ways until either side closes;
+// any other answer is relayed as an ordinary response.
+func (p *Proxy) tunnel(c *fiber.Ctx, up *upstream, path, key string) error {
+	backend := up.lb.Acquire(key, nil)
+	if backend == nil {
+		return c.Status(fiber.StatusServiceUnavailable).SendString("no backend available")
+	}
+	start := time.Now()
+	conn, err := dialBackend(backend.URL, up.client.TLSConfig, up.cfg.Timeout)
+	if err != nil {
+		up.lb.Report(backend, false, time.Since(start))
+		log.Printf("[websocket] dial %s: %v", backend.URL, err)
+		return c.Status(fiber.StatusBadGateway).SendString("backend error")
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	c.Request().Header.CopyTo(&req.Header)
+	uri := path
+	if q := c.Request().URI().QueryString(); len(q) > 0 {
+		uri += "?" + string(q)
+	}
+	req.SetRequestURI(uri)
+	req.Header.Add("X-Forwarded-For", c.IP())
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	br := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(up.cfg.Timeout))
+	bw := bufio.NewWriter(conn)
+	if err = req.Write(bw); err == nil {
+		if err = bw.Flush(); err == nil {
+			err = resp.Header.Read(br)
+		}
+	}
+	latency := time.Since(start)
+	if err == nil && resp.StatusCode() != fiber.StatusSwitchingProtocols {
+		err = resp.ReadBody(br, responseBufferSize)
+	}
+	up.lb.Report(backend, err == nil && resp.StatusCode() < 500, latency)
+	if err != nil {
+		conn.Close()
+		log.Printf("[websocket] upgrade %s: %v", backend.URL, err)
+		return c.Status(fiber.StatusBadGateway).SendString("backend error")
+	}
+	if resp.StatusCode() != fiber.StatusSwitchingProtocols {
+		conn.Close()
+		c.Status(resp.StatusCode())
+		resp.Header.VisitAll(func(k, v []byte) {
+			if !hopHeaders[string(k)] {
+				c.Set(string(k), string(v))
+			}
+		})
+		return c.Send(resp.Body())
+	}
+	conn.SetDeadline(time.Time{})
+	head := bytes.Clone(resp.Header.Header())
+	backend.inflight.Add(1)
+	c.Context().HijackSetNoResponse(true)
+	c.Context().Hijack(func(client net.Conn) {
+		// A connection's lifetime says nothing about backend latency, so
+		// only the in-flight count is tracked.
+		defer backend.inflight.Add(-1)
+		if _, err := client.Write(head); err != nil {
+			conn.Close()
+			return
+		}
+		pipe(client, conn, br)
+	})
+	return nil
+}
+
+// pipe copies client to backend and backendR to client, closing both
+// connections once either direction ends.
+func pipe(client, backend net.Conn, backendR io.Reader) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(backend, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, backendR)
+		done <- struct{}{}
+	}()
+	<-done
+	client.Close()
+	backend.Close()
+	<-done
+}
+
+func dialBackend(rawURL string, tlsCfg *tls.Config, timeout time.Duration) (net.Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: timeout}
+	if u.Scheme != "https" {
+		return d.Dial("tcp", hostPort(u, "80"))
+	}
+	if tlsCfg == nil {
+		tlsCfg = &tls.Config{}
+	}
+	return tls.DialWithDialer(d, "tcp", hostPort(u, "443"), tlsCfg)
+}
+
+func hostPort(u *url.URL, port string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}